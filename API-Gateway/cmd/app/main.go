@@ -2,11 +2,16 @@ package main
 
 import (
 	"api-gateway/internal/app"
+	healthhandler "api-gateway/internal/handlers/health"
+	userscashservice "api-gateway/internal/service/redis/users"
 	grpcauthserver "api-gateway/internal/storage/grpc/auth"
 	grpcusersstorage "api-gateway/internal/storage/grpc/users"
+	userslrustorage "api-gateway/internal/storage/lru/users"
 	userscashstorage "api-gateway/internal/storage/redis/users"
+	userstieredstorage "api-gateway/internal/storage/tiered/users"
 	"api-gateway/pkg/config"
 	"api-gateway/pkg/lib/logger"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -24,10 +29,16 @@ func main() {
 	log.Info("connection to usersService done")
 	grpcAuthApiConnection := grpcauthserver.New(log, cfg.GrpcAuthAPIHost, cfg.GrpcAuthAPIPort)
 	log.Info("connection to authService done")
-	redisConnection := userscashstorage.New(log, cfg.RedisHost, cfg.RedisPort, cfg.ExpirationTime)
-	log.Info("connection to redis done")
+	cacheStorage, cacheHealth, closeCache := mustSetupCache(log, cfg)
+	log.Info("users cache done", slog.String("backend", cfg.CacheBackend))
 
-	application := app.New(cfg, log, grpcUsersApiConnection, grpcAuthApiConnection, redisConnection)
+	// the cache is optional, the gateway serves from UsersService without it
+	dependencies := []healthhandler.Dependency{}
+	if cacheHealth != nil {
+		dependencies = append(dependencies, healthhandler.Dependency{Reporter: cacheHealth})
+	}
+
+	application := app.New(cfg, log, grpcUsersApiConnection, grpcAuthApiConnection, cacheStorage, dependencies)
 
 	go func() {
 		application.MustRun()
@@ -41,8 +52,33 @@ func main() {
 	grpcUsersApiConnection.Close()
 	log.Info("grpcUsersApiConnection closed")
 
-	redisConnection.Close()
-	log.Info("redisConnection closed")
+	closeCache()
+	log.Info("users cache closed")
 
 	log.Info("application stoped")
 }
+
+// mustSetupCache builds the users cache backend chosen by cfg.CacheBackend
+// and returns it with its health, nil for the in-process backend, and the
+// function closing it.
+func mustSetupCache(log *slog.Logger, cfg *config.Config) (userscashservice.UsersCashStorage, healthhandler.IHealthReporter, func()) {
+	localExpirationTime := cfg.LocalExpirationTime
+	if localExpirationTime <= 0 {
+		localExpirationTime = cfg.ExpirationTime
+	}
+
+	switch cfg.CacheBackend {
+	case config.CacheBackendRedis:
+		redisConnection := userscashstorage.New(log, cfg.RedisHost, cfg.RedisPort, cfg.ExpirationTime, cfg.NotFoundExpirationTime)
+		return redisConnection, redisConnection, redisConnection.Close
+	case config.CacheBackendMemory:
+		lruCache := userslrustorage.New(log, cfg.LocalCacheSize, cfg.ExpirationTime, cfg.NotFoundExpirationTime)
+		return lruCache, nil, lruCache.Close
+	case config.CacheBackendTiered:
+		lruCache := userslrustorage.New(log, cfg.LocalCacheSize, localExpirationTime, min(cfg.NotFoundExpirationTime, localExpirationTime))
+		redisConnection := userscashstorage.New(log, cfg.RedisHost, cfg.RedisPort, cfg.ExpirationTime, cfg.NotFoundExpirationTime)
+		return userstieredstorage.New(log, lruCache, redisConnection), redisConnection, redisConnection.Close
+	default:
+		panic(fmt.Sprintf("unknown cache backend %q", cfg.CacheBackend))
+	}
+}
@@ -3,7 +3,11 @@ package app
 import (
 	"api-gateway/internal/domain/models"
 	authhandler "api-gateway/internal/handlers/auth"
+	healthhandler "api-gateway/internal/handlers/health"
 	usershandler "api-gateway/internal/handlers/users"
+	"api-gateway/internal/lib/admission"
+	"api-gateway/internal/lib/jwt"
+	authmiddleware "api-gateway/internal/middleware/auth"
 	authservice "api-gateway/internal/service/auth"
 	userscashservice "api-gateway/internal/service/redis/users"
 	usersservice "api-gateway/internal/service/users"
@@ -13,23 +17,31 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
-	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Insert(ctx context.Context, user models.User, isImport bool) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error)
 }
 
 type IAuthServer interface {
 	Login(ctx context.Context, login string, password string) (string, string, error)
 	Register(ctx context.Context, user models.User) (models.User, error)
 	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+	Refresh(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	GetJWKS(ctx context.Context) ([]models.JSONWebKey, error)
+	ValidateToken(ctx context.Context, accessToken string) (models.TokenIntrospection, error)
 }
 
 type App struct {
@@ -37,16 +49,18 @@ type App struct {
 	log          *slog.Logger
 	psqlStorage  IUsersStorage
 	authServer   IAuthServer
-	redisStorage userscashservice.UsersCashStorage
+	cacheStorage userscashservice.UsersCashStorage
+	dependencies []healthhandler.Dependency
 }
 
-func New(cfg *config.Config, log *slog.Logger, storage *grpcstorage.GRPCUsersStorage, authServer IAuthServer, redisStorage userscashservice.UsersCashStorage) *App {
+func New(cfg *config.Config, log *slog.Logger, storage *grpcstorage.GRPCUsersStorage, authServer IAuthServer, cacheStorage userscashservice.UsersCashStorage, dependencies []healthhandler.Dependency) *App {
 	return &App{
 		cfg:          cfg,
 		log:          log,
 		psqlStorage:  storage,
 		authServer:   authServer,
-		redisStorage: redisStorage,
+		cacheStorage: cacheStorage,
+		dependencies: dependencies,
 	}
 }
 
@@ -59,29 +73,68 @@ func (a *App) MustRun() {
 func (a *App) Run() error {
 	r := mux.NewRouter()
 
-	redisService := userscashservice.New(a.log, a.redisStorage)
+	cacheService := userscashservice.New(a.log, a.cacheStorage)
 
-	usersService := usersservice.New(a.log, a.psqlStorage)
-	usersHandler := usershandler.New(a.log, usersService, redisService, a.cfg.MaxRequestsPerUser)
+	admissionPolicy := admission.New(admission.Config{
+		Threshold:  a.cfg.MaxRequestsPerUser,
+		Width:      a.cfg.AdmissionWidth,
+		ResetAfter: a.cfg.AdmissionResetAfter,
+	})
+	usersService := usersservice.New(a.log, a.psqlStorage, cacheService, admissionPolicy)
+	usersHandler := usershandler.New(a.log, usersService)
 	a.log.Info("usersHandler done")
 
-	authService := authservice.New(a.log, a.authServer)
+	authService := authservice.New(a.log, a.authServer, time.Duration(a.cfg.TokenStatusCacheTime)*time.Second)
 	authHandler := authhandler.New(a.log, authService)
 	a.log.Info("authHandler done")
 
+	healthHandler := healthhandler.New(a.log, a.dependencies)
+
+	secrets := map[string][]byte{}
+	if a.cfg.AccessTokenSecretsDir != "" {
+		loaded, err := jwt.LoadSecrets(a.cfg.AccessTokenSecretsDir)
+		if err != nil {
+			return fmt.Errorf("cannot load access token secrets: %w", err)
+		}
+		secrets = loaded
+	}
+	if a.cfg.AccessTokenSecret != "" {
+		secrets[a.cfg.AccessTokenSecretId] = []byte(a.cfg.AccessTokenSecret)
+	}
+
+	verifier := jwt.NewVerifier(a.cfg.JWTIssuer, a.cfg.JWTAudience, secrets, authService)
+	authMiddleware := authmiddleware.New(a.log, verifier, authService, authService, a.cfg.IntrospectionClients)
+	adminOnly := func(h http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(authMiddleware.RequireAdmin(h))
+	}
+	selfOrAdmin := func(h http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(authMiddleware.RequireSelfOrAdmin(h))
+	}
+
 	r.HandleFunc("/api/v1/health-check", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("200 OK"))
 	})
+	r.HandleFunc("/api/v1/readiness", healthHandler.ReadinessHandler).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/jwks.json", authHandler.JWKSHandler).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/login", authHandler.LoginHandler).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/register", authHandler.RegisterHandler).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/refresh", authHandler.RefreshTokenHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/v1/logout", authHandler.LoginHandler).Methods(http.MethodPost)
-
-	r.HandleFunc("/api/v1/users", usersHandler.GetUsersHandler).Methods(http.MethodGet)
-	r.HandleFunc("/api/v1/users/{id}", usersHandler.GetUserByIdHandler).Methods(http.MethodGet)
-	r.HandleFunc("/api/v1/users", usersHandler.InsertHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/v1/users/{id}", usersHandler.UpdateHandler).Methods(http.MethodPut)
-	r.HandleFunc("/api/v1/users/{id}", usersHandler.DeleteHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/logout", authHandler.LogoutHandler).Methods(http.MethodPost)
+	if len(a.cfg.IntrospectionClients) == 0 {
+		a.log.Warn("No introspection clients configured, token introspection is closed")
+	}
+	r.Handle("/api/v1/introspect", authMiddleware.AuthenticateClient(http.HandlerFunc(authHandler.IntrospectHandler))).Methods(http.MethodPost)
+
+	r.Handle("/api/v1/users", adminOnly(usersHandler.GetUsersHandler)).Methods(http.MethodGet)
+	r.Handle("/api/v1/users/{id}", selfOrAdmin(usersHandler.GetUserByIdHandler)).Methods(http.MethodGet)
+	r.Handle("/api/v1/users", adminOnly(usersHandler.InsertHandler)).Methods(http.MethodPost)
+	r.Handle("/api/v1/users/{id}", adminOnly(usersHandler.UpdateHandler)).Methods(http.MethodPut)
+	r.Handle("/api/v1/users/{id}", adminOnly(usersHandler.PatchHandler)).Methods(http.MethodPatch)
+	r.Handle("/api/v1/users/{id}", adminOnly(usersHandler.DeleteHandler)).Methods(http.MethodDelete)
+	r.Handle("/api/v1/users/{id}/restore", adminOnly(usersHandler.RestoreHandler)).Methods(http.MethodPost)
+	r.Handle("/api/v1/users/{id}/purge", adminOnly(usersHandler.PurgeHandler)).Methods(http.MethodPost)
+	r.Handle("/api/v1/users/{id}/history", adminOnly(usersHandler.GetUserHistoryHandler)).Methods(http.MethodGet)
+	r.Handle("/api/v1/cache/stats", adminOnly(usersHandler.CacheStatsHandler)).Methods(http.MethodGet)
 
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", a.cfg.Port), r); err != nil {
 		return err
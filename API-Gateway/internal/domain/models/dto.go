@@ -0,0 +1,218 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateUserRequest is the body of POST /api/v1/users. Id may only be set
+// when importing users, otherwise it is assigned by UsersService.
+type CreateUserRequest struct {
+	Id          uuid.UUID `json:"id"`
+	Login       string    `json:"login"`
+	Password    string    `json:"password"`
+	Role        string    `json:"role"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+}
+
+// UpdateUserRequest is the body of PUT /api/v1/users/{id}. An empty
+// password keeps the current one.
+type UpdateUserRequest struct {
+	Login       string `json:"login"`
+	Password    string `json:"password,omitempty"`
+	Role        string `json:"role"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+}
+
+// PatchUserRequest is the body of PATCH /api/v1/users/{id}, a JSON Merge
+// Patch (RFC 7396) of the user. null clears a profile field.
+type PatchUserRequest map[string]*string
+
+// patchableFields are the keys PatchUserRequest may have, named as in the
+// UsersService update mask.
+var patchableFields = []string{"login", "password", "role", "email", "display_name", "locale", "timezone"}
+
+// RegisterRequest is the body of POST /api/v1/register, the role is always
+// assigned by the gateway.
+type RegisterRequest struct {
+	Login       string `json:"login"`
+	Password    string `json:"password"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+}
+
+// UserResponse is the public view of a user, it never carries the password.
+// last_login_at is left out until the user has logged in.
+type UserResponse struct {
+	Id          uuid.UUID  `json:"id"`
+	Login       string     `json:"login"`
+	Role        string     `json:"role"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"display_name"`
+	Locale      string     `json:"locale"`
+	Timezone    string     `json:"timezone"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int64      `json:"version"`
+}
+
+type UsersPageResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func (r CreateUserRequest) User() User {
+	return User{
+		Id:          r.Id,
+		Login:       r.Login,
+		Password:    r.Password,
+		Role:        r.Role,
+		Email:       r.Email,
+		DisplayName: r.DisplayName,
+		Locale:      r.Locale,
+		Timezone:    r.Timezone,
+	}
+}
+
+func (r UpdateUserRequest) User() User {
+	return User{
+		Login:       r.Login,
+		Password:    r.Password,
+		Role:        r.Role,
+		Email:       r.Email,
+		DisplayName: r.DisplayName,
+		Locale:      r.Locale,
+		Timezone:    r.Timezone,
+	}
+}
+
+// User returns the patched user and the names of the fields to change.
+func (r PatchUserRequest) User() (User, []string, error) {
+	for field := range r {
+		if !slices.Contains(patchableFields, field) {
+			return User{}, nil, fmt.Errorf("field %q cannot be patched", field)
+		}
+	}
+
+	var (
+		user   User
+		fields []string
+	)
+	for _, field := range patchableFields {
+		value, ok := r[field]
+		if !ok {
+			continue
+		}
+
+		switch field {
+		case "login", "password", "role":
+			if value == nil || *value == "" {
+				return User{}, nil, fmt.Errorf("field %q cannot be empty", field)
+			}
+		}
+		if value == nil {
+			value = new(string)
+		}
+
+		switch field {
+		case "login":
+			user.Login = *value
+		case "password":
+			user.Password = *value
+		case "role":
+			user.Role = *value
+		case "email":
+			user.Email = *value
+		case "display_name":
+			user.DisplayName = *value
+		case "locale":
+			user.Locale = *value
+		case "timezone":
+			user.Timezone = *value
+		}
+		fields = append(fields, field)
+	}
+
+	return user, fields, nil
+}
+
+func (r RegisterRequest) User(role string) User {
+	return User{
+		Login:       r.Login,
+		Password:    r.Password,
+		Role:        role,
+		Email:       r.Email,
+		DisplayName: r.DisplayName,
+		Locale:      r.Locale,
+		Timezone:    r.Timezone,
+	}
+}
+
+func NewUserResponse(user User) UserResponse {
+	response := UserResponse{
+		Id:          user.Id,
+		Login:       user.Login,
+		Role:        user.Role,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Version:     user.Version,
+	}
+	if !user.LastLoginAt.IsZero() {
+		lastLoginAt := user.LastLoginAt
+		response.LastLoginAt = &lastLoginAt
+	}
+	if !user.DeletedAt.IsZero() {
+		deletedAt := user.DeletedAt
+		response.DeletedAt = &deletedAt
+	}
+
+	return response
+}
+
+func NewUsersPageResponse(page UsersPage) UsersPageResponse {
+	users := make([]UserResponse, 0, len(page.Users))
+	for _, user := range page.Users {
+		users = append(users, NewUserResponse(user))
+	}
+
+	return UsersPageResponse{
+		Users:      users,
+		NextCursor: page.NextCursor,
+	}
+}
+
+// HistoryPageResponse is the body of GET /api/v1/users/{id}/history,
+// entries come newest first.
+type HistoryPageResponse struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func NewHistoryPageResponse(page HistoryPage) HistoryPageResponse {
+	entries := page.Entries
+	if entries == nil {
+		entries = []HistoryEntry{}
+	}
+
+	return HistoryPageResponse{
+		Entries:    entries,
+		NextCursor: page.NextCursor,
+	}
+}
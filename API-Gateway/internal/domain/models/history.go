@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FieldChange is one field of a user before and after an operation,
+// passwords are redacted by UsersService.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// HistoryEntry is one recorded write of a user. Actor is the id of the
+// user that made it, empty when it was not made through the gateway.
+type HistoryEntry struct {
+	Id        uuid.UUID     `json:"id"`
+	UserId    uuid.UUID     `json:"user_id"`
+	Actor     string        `json:"actor"`
+	Operation string        `json:"operation"`
+	ChangedAt time.Time     `json:"changed_at"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+type HistoryPageRequest struct {
+	UserId   uuid.UUID
+	PageSize int
+	Cursor   string
+}
+
+type HistoryPage struct {
+	Entries    []HistoryEntry
+	NextCursor string
+}
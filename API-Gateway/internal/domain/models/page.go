@@ -0,0 +1,28 @@
+package models
+
+const (
+	SortById    = "id"
+	SortByLogin = "login"
+)
+
+type UsersFilter struct {
+	Role        string
+	LoginPrefix string
+}
+
+type UsersSort struct {
+	By   string
+	Desc bool
+}
+
+type UsersPageRequest struct {
+	Filter   UsersFilter
+	Sort     UsersSort
+	PageSize int
+	Cursor   string
+}
+
+type UsersPage struct {
+	Users      []User
+	NextCursor string
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessToken is the verified content of a bearer access token.
+type AccessToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	FamilyId  uuid.UUID
+	Login     string
+	Role      string
+	ExpiresAt time.Time
+}
+
+// JSONWebKey is a public verification key as published in the JWKS.
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// TokenIntrospection is a token introspection response (RFC 7662). An
+// inactive token carries no other fields.
+type TokenIntrospection struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Id        string   `json:"jti,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	SessionId string   `json:"sid,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+}
@@ -2,23 +2,39 @@ package authhandler
 
 import (
 	"api-gateway/internal/domain/models"
+	usershandler "api-gateway/internal/handlers/users"
+	"api-gateway/internal/lib/jwt"
+	"api-gateway/internal/lib/problem"
 	serviceerror "api-gateway/internal/service"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const userRoleTitle = "user"
 
+const (
+	refreshCookieName = "refresh_token"
+	refreshCookiePath = "/api/v1"
+	// matches the refresh token lifetime in Auth
+	refreshCookieMaxAge = 7 * 24 * time.Hour
+)
+
 type IAuthService interface {
 	Login(ctx context.Context, login string, password string) (string, string, error)
 	Register(ctx context.Context, user models.User) (models.User, error)
 	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+	Refresh(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	JWKS(ctx context.Context) ([]models.JSONWebKey, error)
+	Introspect(ctx context.Context, accessToken string) (models.TokenIntrospection, error)
 }
 
 type AuthHandler struct {
@@ -46,20 +62,25 @@ func (a *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&loginStruct); err != nil {
 		log.Error("Cannot parse request body to obj", sl.Err(err))
-		http.Error(w, "Cannot parse request body to obj", http.StatusBadRequest)
+		problem.Error(w, "Cannot parse request body to obj", http.StatusBadRequest)
 		return
 	}
 
-	accessToken, _, err := a.service.Login(r.Context(), loginStruct.Login, loginStruct.Password)
+	accessToken, refreshToken, err := a.service.Login(r.Context(), loginStruct.Login, loginStruct.Password)
 	if err != nil {
-		if errors.Is(err, serviceerror.ErrNotFound) {
-			log.Warn("User not found", sl.Err(err))
-			http.Error(w, "User not found", http.StatusNotFound)
+		switch {
+		case errors.Is(err, serviceerror.ErrInvalidCredentials):
+			log.Warn("Invalid login or password", sl.Err(err))
+			problem.FromError(w, err, "Invalid login or password", http.StatusUnauthorized)
+			return
+		case errors.Is(err, serviceerror.ErrInvalidArgument):
+			log.Warn("Invalid login request", sl.Err(err))
+			problem.FromError(w, err, "Invalid login request", http.StatusBadRequest)
 			return
 		}
 
 		log.Error("Cannot login", sl.Err(err))
-		http.Error(w, "Cannot login", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot login", http.StatusInternalServerError)
 		return
 	}
 
@@ -69,10 +90,11 @@ func (a *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		AccessToken: accessToken,
 	}
 
+	setRefreshCookie(w, refreshToken)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(tokenResponse); err != nil {
 		log.Error("Cannot write token to response", sl.Err(err))
-		http.Error(w, "Cannot write token to response", http.StatusInternalServerError)
+		problem.Error(w, "Cannot write token to response", http.StatusInternalServerError)
 		return
 	}
 }
@@ -83,34 +105,247 @@ func (a *AuthHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		"op", op,
 	)
 
-	var userForRegister models.User
-	if err := json.NewDecoder(r.Body).Decode(&userForRegister); err != nil {
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Error("Cannot read requesy body", sl.Err(err))
-		http.Error(w, "Cannot read requesy body", http.StatusBadRequest)
+		problem.Error(w, "Cannot read requesy body", http.StatusBadRequest)
 		return
 	}
-	userForRegister.Role = userRoleTitle
 
-	registeredUser, err := a.service.Register(r.Context(), userForRegister)
+	if req.Login == "" || req.Password == "" {
+		log.Warn("Login and password are required")
+		problem.Error(w, "Login and password are required", http.StatusBadRequest)
+		return
+	}
+
+	registeredUser, err := a.service.Register(r.Context(), req.User(userRoleTitle))
 	if err != nil {
 		if errors.Is(err, serviceerror.ErrAlreadyExists) {
 			log.Warn("User already registered", sl.Err(err))
-			http.Error(w, "User already registered", http.StatusConflict)
+			problem.FromError(w, err, "User already registered", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			problem.FromError(w, err, "Invalid user", http.StatusBadRequest)
 			return
 		}
 
 		log.Error("Cannot register", sl.Err(err))
-		http.Error(w, "Cannot register", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot register", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", usershandler.UserLocation(registeredUser.Id))
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(registeredUser.Id); err != nil {
-		log.Error("Cannot write id to response", sl.Err(err))
-		http.Error(w, "Cannot write id to response", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(registeredUser)); err != nil {
+		log.Error("Cannot write user to response", sl.Err(err))
 		return
 	}
 }
 
-func (AuthHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {}
-func (AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request)       {}
+// RefreshTokenHandler exchanges the refresh token for a new pair. The token
+// is taken from the refresh_token cookie, or from the JSON body for clients
+// that do not keep cookies.
+func (a *AuthHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.auth.Refresh"
+	log := a.log.With(
+		"op", op,
+	)
+
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var refreshStruct = struct {
+			RefreshToken string `json:"refresh_token"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&refreshStruct); err != nil && !errors.Is(err, io.EOF) {
+			log.Error("Cannot parse request body to obj", sl.Err(err))
+			problem.Error(w, "Cannot parse request body to obj", http.StatusBadRequest)
+			return
+		}
+		refreshToken = refreshStruct.RefreshToken
+	}
+
+	if refreshToken == "" {
+		log.Warn("Refresh token is required")
+		problem.Error(w, "Refresh token is required", http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, newRefreshToken, err := a.service.Refresh(r.Context(), refreshToken)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidToken) {
+			log.Warn("Invalid refresh token", sl.Err(err))
+			clearRefreshCookie(w)
+			problem.FromError(w, err, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		log.Error("Cannot refresh tokens", sl.Err(err))
+		problem.FromError(w, err, "Cannot refresh tokens", http.StatusInternalServerError)
+		return
+	}
+
+	tokenResponse := struct {
+		AccessToken string `json:"access_token"`
+	}{
+		AccessToken: accessToken,
+	}
+
+	setRefreshCookie(w, newRefreshToken)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tokenResponse); err != nil {
+		log.Error("Cannot write token to response", sl.Err(err))
+		problem.Error(w, "Cannot write token to response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// IntrospectHandler answers whether an access token is active and for whom
+// (RFC 7662). The token is taken from the form field "token"; only access
+// tokens are supported, so token_type_hint is ignored. Services that do
+// not verify tokens themselves can rely on it. The caller must authenticate
+// itself (RFC 7662 section 2.1) with its client credentials, see
+// AuthMiddleware.AuthenticateClient.
+func (a *AuthHandler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.auth.Introspect"
+	log := a.log.With(
+		"op", op,
+	)
+
+	if err := r.ParseForm(); err != nil {
+		log.Warn("Cannot parse form", sl.Err(err))
+		introspectionError(w, "invalid_request")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		log.Warn("Token is required")
+		introspectionError(w, "invalid_request")
+		return
+	}
+
+	introspection, err := a.service.Introspect(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid argument", sl.Err(err))
+			introspectionError(w, "invalid_request")
+			return
+		}
+
+		log.Error("Cannot introspect token", sl.Err(err))
+		problem.FromError(w, err, "Cannot introspect token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(introspection); err != nil {
+		log.Error("Cannot write introspection to response", sl.Err(err))
+		return
+	}
+}
+
+func introspectionError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// JWKSHandler publishes the Auth service verification keys, so other
+// services can check access tokens offline.
+func (a *AuthHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.auth.JWKS"
+	log := a.log.With(
+		"op", op,
+	)
+
+	keys, err := a.service.JWKS(r.Context())
+	if err != nil {
+		log.Error("Cannot get JWKS", sl.Err(err))
+		problem.FromError(w, err, "Cannot get JWKS", http.StatusInternalServerError)
+		return
+	}
+
+	jwks := struct {
+		Keys []models.JSONWebKey `json:"keys"`
+	}{
+		Keys: keys,
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(jwks); err != nil {
+		log.Error("Cannot write JWKS to response", sl.Err(err))
+		return
+	}
+}
+
+// LogoutHandler revokes the bearer access token and the session behind the
+// refresh_token cookie, either of them is enough.
+func (a *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.auth.Logout"
+	log := a.log.With(
+		"op", op,
+	)
+
+	accessToken := jwt.BearerToken(r)
+
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		refreshToken = cookie.Value
+	}
+
+	if accessToken == "" && refreshToken == "" {
+		log.Warn("No token to revoke")
+		problem.Error(w, "Access or refresh token is required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.service.Logout(r.Context(), accessToken, refreshToken); err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidToken) {
+			log.Warn("Invalid token", sl.Err(err))
+			clearRefreshCookie(w)
+			problem.FromError(w, err, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		log.Error("Cannot logout", sl.Err(err))
+		problem.FromError(w, err, "Cannot logout", http.StatusInternalServerError)
+		return
+	}
+
+	clearRefreshCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setRefreshCookie(w http.ResponseWriter, refreshToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    refreshToken,
+		Path:     refreshCookiePath,
+		MaxAge:   int(refreshCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Path:     refreshCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
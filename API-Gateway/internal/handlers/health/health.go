@@ -0,0 +1,78 @@
+package healthhandler
+
+import (
+	"api-gateway/internal/lib/health"
+	"api-gateway/pkg/lib/logger/sl"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type IHealthReporter interface {
+	Health() health.Status
+}
+
+// Dependency is reported by readiness. The gateway is not ready while a
+// required one is down, the others only degrade it.
+type Dependency struct {
+	Reporter IHealthReporter
+	Required bool
+}
+
+type dependencyStatus struct {
+	health.Status
+	Required bool `json:"required"`
+}
+
+type readinessResponse struct {
+	Ready        bool               `json:"ready"`
+	Degraded     bool               `json:"degraded"`
+	Dependencies []dependencyStatus `json:"dependencies"`
+}
+
+type HealthHandler struct {
+	log          *slog.Logger
+	dependencies []Dependency
+}
+
+func New(log *slog.Logger, dependencies []Dependency) *HealthHandler {
+	return &HealthHandler{
+		log:          log,
+		dependencies: dependencies,
+	}
+}
+
+// ReadinessHandler answers 503 while a required dependency is down and 200
+// otherwise, the body lists the state of every dependency.
+func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.health.ReadinessHandler"
+	log := h.log.With(
+		"op", op,
+	)
+
+	resp := readinessResponse{
+		Ready:        true,
+		Dependencies: make([]dependencyStatus, 0, len(h.dependencies)),
+	}
+	for _, dep := range h.dependencies {
+		status := dep.Reporter.Health()
+		if !status.Healthy {
+			if dep.Required {
+				resp.Ready = false
+			} else {
+				resp.Degraded = true
+			}
+		}
+
+		resp.Dependencies = append(resp.Dependencies, dependencyStatus{Status: status, Required: dep.Required})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("Cannot write readiness to response", sl.Err(err))
+		return
+	}
+}
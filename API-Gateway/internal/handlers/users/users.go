@@ -2,48 +2,52 @@ package usershandler
 
 import (
 	"api-gateway/internal/domain/models"
+	"api-gateway/internal/lib/admission"
+	"api-gateway/internal/lib/problem"
 	serviceerror "api-gateway/internal/service"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+const (
+	userRoleTitle       = "user"
+	mergePatchMediaType = "application/merge-patch+json"
+)
+
 type IUsersService interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
-	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
-}
-
-type IUserCashService interface {
-	Get(context.Context, uuid.UUID) (models.User, error)
-	Set(context.Context, models.User) error
-	Del(context.Context, uuid.UUID) error
+	Insert(ctx context.Context, user models.User, isImport bool) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error)
+	AdmissionStats() admission.Stats
 }
 
 type UsersHandler struct {
-	log                *slog.Logger
-	service            IUsersService
-	redisService       IUserCashService
-	MaxRequestsPerUser int
+	log     *slog.Logger
+	service IUsersService
 }
 
-var frequentlyRequestedUsers = map[uuid.UUID]int{}
-
-func New(log *slog.Logger, service IUsersService, redisService IUserCashService, maxRequestsPerUser int) *UsersHandler {
+func New(log *slog.Logger, service IUsersService) *UsersHandler {
 	return &UsersHandler{
-		log:                log,
-		service:            service,
-		redisService:       redisService,
-		MaxRequestsPerUser: maxRequestsPerUser,
+		log:     log,
+		service: service,
 	}
 }
 
@@ -53,21 +57,74 @@ func (u *UsersHandler) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 		"op", op,
 	)
 
-	users, err := u.service.GetUsers(r.Context())
+	req, err := parsePageRequest(r.URL.Query())
 	if err != nil {
+		log.Warn("Invalid query parameters", sl.Err(err))
+		problem.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := u.service.GetUsers(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			problem.FromError(w, err, "Invalid page request", http.StatusBadRequest)
+			return
+		}
+
 		log.Error("Error fetching users", sl.Err(err))
-		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		problem.FromError(w, err, "Error fetching users", http.StatusInternalServerError)
 		return
 	}
 
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(users); err != nil {
+	if err := json.NewEncoder(w).Encode(models.NewUsersPageResponse(page)); err != nil {
 		log.Error("Cannot write users to response", sl.Err(err))
-		http.Error(w, "Cannot write users to response", http.StatusInternalServerError)
+		problem.Error(w, "Cannot write users to response", http.StatusInternalServerError)
 		return
 	}
 }
 
+// parsePageRequest reads ?limit=&cursor=&role=&login_prefix=&sort= where
+// sort is a field name optionally prefixed with "-" for descending order.
+func parsePageRequest(query url.Values) (models.UsersPageRequest, error) {
+	req := models.UsersPageRequest{
+		Filter: models.UsersFilter{
+			Role:        query.Get("role"),
+			LoginPrefix: query.Get("login_prefix"),
+		},
+		Cursor: query.Get("cursor"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return models.UsersPageRequest{}, errors.New("limit must be a positive integer")
+		}
+		req.PageSize = n
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		req.Sort.Desc = strings.HasPrefix(sort, "-")
+		req.Sort.By = strings.TrimPrefix(sort, "-")
+
+		if req.Sort.By != models.SortById && req.Sort.By != models.SortByLogin {
+			return models.UsersPageRequest{}, fmt.Errorf("sort must be one of %s, %s (prefix with - for descending)", models.SortById, models.SortByLogin)
+		}
+	}
+
+	return req, nil
+}
+
 func (u *UsersHandler) GetUserByIdHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handlers.users.GetUserByHandler"
 	log := u.log.With(
@@ -77,52 +134,35 @@ func (u *UsersHandler) GetUserByIdHandler(w http.ResponseWriter, r *http.Request
 	id_s, ok := mux.Vars(r)["id"]
 	if !ok {
 		log.Error("Id is required")
-		http.Error(w, "Id is required", http.StatusBadRequest)
+		problem.Error(w, "Id is required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := uuid.Parse(id_s)
 	if err != nil {
 		log.Error("id must be uuid", sl.Err(err))
-		http.Error(w, "id must be uuid", http.StatusBadRequest)
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
 		return
 	}
 
-	if frequentlyRequestedUsers[id] >= u.MaxRequestsPerUser {
-		user, err := u.redisService.Get(r.Context(), id)
-		if err == nil {
-			w.WriteHeader(http.StatusOK)
-			if err := json.NewEncoder(w).Encode(user); err != nil {
-				log.Error("Cannot write user to response", sl.Err(err))
-				http.Error(w, "Cannot write user to response", http.StatusInternalServerError)
-				return
-			}
-			return
-		}
-	}
-
 	user, err := u.service.GetUserById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(err))
-			http.Error(w, "User not found", http.StatusNotFound)
+			problem.FromError(w, err, "User not found", http.StatusNotFound)
 			return
 		}
 
 		log.Error("Cannot fetch user by id", sl.Err(err))
-		http.Error(w, "Cannot fetch user by id", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot fetch user by id", http.StatusInternalServerError)
 		return
 	}
 
-	frequentlyRequestedUsers[id]++
-	if frequentlyRequestedUsers[id] >= u.MaxRequestsPerUser {
-		u.redisService.Set(r.Context(), user)
-	}
-
+	w.Header().Set("ETag", userETag(user))
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(user); err != nil {
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(user)); err != nil {
 		log.Error("Cannot write user to response", sl.Err(err))
-		http.Error(w, "Cannot write user to response", http.StatusInternalServerError)
+		problem.Error(w, "Cannot write user to response", http.StatusInternalServerError)
 		return
 	}
 }
@@ -133,38 +173,68 @@ func (u *UsersHandler) InsertHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Cannot read request body", http.StatusBadRequest)
+		problem.Error(w, "Cannot read request body", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	var userForInsert models.User
-	if err := json.Unmarshal(body, &userForInsert); err != nil {
+	var req models.CreateUserRequest
+	if err := json.Unmarshal(body, &req); err != nil {
 		log.Error("Cannot parse body to user", sl.Err(err))
-		http.Error(w, "Cannot parse body to user", http.StatusBadRequest)
+		problem.Error(w, "Cannot parse body to user", http.StatusBadRequest)
+		return
+	}
+
+	if req.Login == "" || req.Password == "" {
+		log.Warn("Login and password are required")
+		problem.Error(w, "Login and password are required", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = userRoleTitle
+	}
+
+	// ?import=true lets an admin migrate users together with their ids
+	isImport, _ := strconv.ParseBool(r.URL.Query().Get("import"))
+	if req.Id != uuid.Nil && !isImport {
+		log.Warn("Client-chosen id rejected")
+		problem.Error(w, "Id is assigned by the server, use ?import=true to keep it", http.StatusBadRequest)
 		return
 	}
 
-	insertedUser, err := u.service.Insert(r.Context(), userForInsert)
+	insertedUser, err := u.service.Insert(r.Context(), req.User(), isImport)
 	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			problem.FromError(w, err, "Invalid email, display name, locale or timezone", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(err, serviceerror.ErrAlreadyExists) {
 			log.Warn("User already exists", sl.Err(err))
-			http.Error(w, "User already exists", http.StatusConflict)
+			problem.FromError(w, err, "User already exists", http.StatusConflict)
 			return
 		}
 
 		log.Error("Cannot insert user", sl.Err(err))
-		http.Error(w, "Cannot insert user", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot insert user", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Location", UserLocation(insertedUser.Id))
+	w.Header().Set("ETag", userETag(insertedUser))
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(insertedUser); err != nil {
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(insertedUser)); err != nil {
 		log.Error("Cannot write user to response", sl.Err(err))
-		http.Error(w, "Cannot write user to response", http.StatusInternalServerError)
+		problem.Error(w, "Cannot write user to response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// UserLocation is the URL of the user resource, used for Location headers.
+func UserLocation(id uuid.UUID) string {
+	return "/api/v1/users/" + id.String()
+}
+
 func (u *UsersHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handlers.users.UpdateHandler"
 	log := u.log.With(
@@ -174,49 +244,167 @@ func (u *UsersHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	id_s, ok := mux.Vars(r)["id"]
 	if !ok {
 		log.Error("Id is required")
-		http.Error(w, "Id is required", http.StatusBadRequest)
+		problem.Error(w, "Id is required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := uuid.Parse(id_s)
 	if err != nil {
 		log.Error("id must be uuid", sl.Err(err))
-		http.Error(w, "id must be uuid", http.StatusBadRequest)
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		log.Warn("Invalid If-Match", sl.Err(err))
+		problem.Error(w, "If-Match does not match the user", http.StatusPreconditionFailed)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Error("Cannot read request body", sl.Err(err))
-		http.Error(w, "Cannot read request body", http.StatusBadRequest)
+		problem.Error(w, "Cannot read request body", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	var userForUpdate models.User
-	if err := json.Unmarshal(body, &userForUpdate); err != nil {
+	var req models.UpdateUserRequest
+	if err := json.Unmarshal(body, &req); err != nil {
 		log.Error("Cannot parse body to user", sl.Err(err))
-		http.Error(w, "Cannot parse body to user", http.StatusBadRequest)
+		problem.Error(w, "Cannot parse body to user", http.StatusBadRequest)
+		return
+	}
+
+	if req.Login == "" || req.Role == "" {
+		log.Warn("Login and role are required")
+		problem.Error(w, "Login and role are required", http.StatusBadRequest)
 		return
 	}
 
-	updatedUser, err := u.service.Update(r.Context(), id, userForUpdate)
+	updatedUser, err := u.service.Update(r.Context(), id, req.User(), nil, expectedVersion)
 	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			problem.FromError(w, err, "Invalid email, display name, locale or timezone", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Error("User not found", sl.Err(err))
-			http.Error(w, "User not found", http.StatusNotFound)
+			problem.FromError(w, err, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, serviceerror.ErrConflict) {
+			log.Warn("User was changed concurrently", sl.Err(err))
+			problem.FromError(w, err, "User was changed, fetch it again and retry", http.StatusPreconditionFailed)
 			return
 		}
 
 		log.Error("Cannot update user", sl.Err(err))
-		http.Error(w, "Cannot update user", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot update user", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", userETag(updatedUser))
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(updatedUser); err != nil {
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(updatedUser)); err != nil {
+		log.Error("Cannot write user to response", sl.Err(err))
+		problem.Error(w, "Cannot write user to response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PatchHandler changes only the fields present in a JSON Merge Patch.
+func (u *UsersHandler) PatchHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.PatchHandler"
+	log := u.log.With(
+		"op", op,
+	)
+
+	id_s, ok := mux.Vars(r)["id"]
+	if !ok {
+		log.Error("Id is required")
+		problem.Error(w, "Id is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(id_s)
+	if err != nil {
+		log.Error("id must be uuid", sl.Err(err))
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != mergePatchMediaType && mediaType != "application/json" {
+		log.Warn("Unsupported patch format", slog.String("content_type", r.Header.Get("Content-Type")))
+		w.Header().Set("Accept-Patch", mergePatchMediaType)
+		problem.Error(w, "Patch must be "+mergePatchMediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		log.Warn("Invalid If-Match", sl.Err(err))
+		problem.Error(w, "If-Match does not match the user", http.StatusPreconditionFailed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error("Cannot read request body", sl.Err(err))
+		problem.Error(w, "Cannot read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var req models.PatchUserRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Warn("Cannot parse body to patch", sl.Err(err))
+		problem.Error(w, "Patch must be a JSON object of strings and nulls", http.StatusBadRequest)
+		return
+	}
+
+	user, fields, err := req.User()
+	if err != nil {
+		log.Warn("Invalid patch", sl.Err(err))
+		problem.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var patchedUser models.User
+	if len(fields) == 0 {
+		// an empty patch changes nothing, while no fields would replace the user
+		patchedUser, err = u.service.GetUserById(r.Context(), id)
+	} else {
+		patchedUser, err = u.service.Update(r.Context(), id, user, fields, expectedVersion)
+	}
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			problem.FromError(w, err, "Invalid email, display name, locale or timezone", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(err))
+			problem.FromError(w, err, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, serviceerror.ErrConflict) {
+			log.Warn("User was changed concurrently", sl.Err(err))
+			problem.FromError(w, err, "User was changed, fetch it again and retry", http.StatusPreconditionFailed)
+			return
+		}
+
+		log.Error("Cannot patch user", sl.Err(err))
+		problem.FromError(w, err, "Cannot patch user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", userETag(patchedUser))
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(patchedUser)); err != nil {
 		log.Error("Cannot write user to response", sl.Err(err))
-		http.Error(w, "Cannot write user to response", http.StatusInternalServerError)
 		return
 	}
 }
@@ -230,34 +418,235 @@ func (u *UsersHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	id_s, ok := mux.Vars(r)["id"]
 	if !ok {
 		log.Error("Id is required")
-		http.Error(w, "Id is required", http.StatusBadRequest)
+		problem.Error(w, "Id is required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := uuid.Parse(id_s)
 	if err != nil {
 		log.Error("id must be uuid", sl.Err(err))
-		http.Error(w, "id must be uuid", http.StatusBadRequest)
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		log.Warn("Invalid If-Match", sl.Err(err))
+		problem.Error(w, "If-Match does not match the user", http.StatusPreconditionFailed)
 		return
 	}
 
-	deletedUser, err := u.service.Delete(r.Context(), id)
+	deletedUser, err := u.service.Delete(r.Context(), id, expectedVersion)
 	if err != nil {
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Error("User not found", sl.Err(err))
-			http.Error(w, "User not found", http.StatusNotFound)
+			problem.FromError(w, err, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, serviceerror.ErrConflict) {
+			log.Warn("User was changed concurrently", sl.Err(err))
+			problem.FromError(w, err, "User was changed, fetch it again and retry", http.StatusPreconditionFailed)
 			return
 		}
 
 		log.Error("Cannot delete user", sl.Err(err))
-		http.Error(w, "Cannot delete user", http.StatusInternalServerError)
+		problem.FromError(w, err, "Cannot delete user", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(deletedUser); err != nil {
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(deletedUser)); err != nil {
+		log.Error("Cannot write user to response", sl.Err(err))
+		problem.Error(w, "Cannot write user to response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RestoreHandler brings back a soft-deleted user.
+func (u *UsersHandler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.RestoreHandler"
+	log := u.log.With(
+		"op", op,
+	)
+
+	id_s, ok := mux.Vars(r)["id"]
+	if !ok {
+		log.Error("Id is required")
+		problem.Error(w, "Id is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(id_s)
+	if err != nil {
+		log.Error("id must be uuid", sl.Err(err))
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	restoredUser, err := u.service.Restore(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("Deleted user not found", sl.Err(err))
+			problem.FromError(w, err, "Deleted user not found", http.StatusNotFound)
+			return
+		}
+
+		log.Error("Cannot restore user", sl.Err(err))
+		problem.FromError(w, err, "Cannot restore user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", userETag(restoredUser))
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(restoredUser)); err != nil {
+		log.Error("Cannot write user to response", sl.Err(err))
+		return
+	}
+}
+
+// PurgeHandler removes a user for good, whether it was deleted or not.
+func (u *UsersHandler) PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.PurgeHandler"
+	log := u.log.With(
+		"op", op,
+	)
+
+	id_s, ok := mux.Vars(r)["id"]
+	if !ok {
+		log.Error("Id is required")
+		problem.Error(w, "Id is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(id_s)
+	if err != nil {
+		log.Error("id must be uuid", sl.Err(err))
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	purgedUser, err := u.service.Purge(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(err))
+			problem.FromError(w, err, "User not found", http.StatusNotFound)
+			return
+		}
+
+		log.Error("Cannot purge user", sl.Err(err))
+		problem.FromError(w, err, "Cannot purge user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.NewUserResponse(purgedUser)); err != nil {
 		log.Error("Cannot write user to response", sl.Err(err))
-		http.Error(w, "Cannot write user to response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// GetUserHistoryHandler lists the recorded changes of a user, newest
+// first, paged with ?limit=&cursor= like GetUsersHandler.
+func (u *UsersHandler) GetUserHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.GetUserHistoryHandler"
+	log := u.log.With(
+		"op", op,
+	)
+
+	id_s, ok := mux.Vars(r)["id"]
+	if !ok {
+		log.Error("Id is required")
+		problem.Error(w, "Id is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(id_s)
+	if err != nil {
+		log.Error("id must be uuid", sl.Err(err))
+		problem.Error(w, "id must be uuid", http.StatusBadRequest)
+		return
+	}
+
+	req := models.HistoryPageRequest{
+		UserId: id,
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			log.Warn("Invalid query parameters", sl.Err(err))
+			problem.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		req.PageSize = n
+	}
+
+	page, err := u.service.GetUserHistory(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			problem.FromError(w, err, "Invalid page request", http.StatusBadRequest)
+			return
+		}
+
+		log.Error("Error fetching user history", sl.Err(err))
+		problem.FromError(w, err, "Error fetching user history", http.StatusInternalServerError)
+		return
+	}
+
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(models.NewHistoryPageResponse(page)); err != nil {
+		log.Error("Cannot write user history to response", sl.Err(err))
+		return
+	}
+}
+
+// CacheStatsHandler reports how the cache admission behaves, to tune its
+// settings.
+func (u *UsersHandler) CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.CacheStatsHandler"
+	log := u.log.With(
+		"op", op,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(u.service.AdmissionStats()); err != nil {
+		log.Error("Cannot write cache stats to response", sl.Err(err))
+		return
+	}
+}
+
+// userETag is a strong entity tag built from the user version.
+func userETag(user models.User) string {
+	return `"` + strconv.FormatInt(user.Version, 10) + `"`
+}
+
+// ifMatchVersion reads the version a write is conditional on. No If-Match
+// or "*" gives 0, which makes the write unconditional.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+
+	// weak tags never match, If-Match uses the strong comparison
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, fmt.Errorf("malformed entity tag %s", tag)
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("unknown entity tag %s", tag)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,147 @@
+// Package admission decides which users are requested often enough to be
+// cached. Request frequencies are estimated with a count-min sketch, as in
+// TinyLFU: memory stays fixed whatever the number of ids, and all counters
+// are halved periodically so that ids which stopped being requested age
+// out.
+package admission
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	depth = 4
+
+	defaultWidth = 1 << 14
+	maxCount     = 1<<16 - 1
+)
+
+// Config tunes the policy. Width is rounded up to a power of two, zero
+// fields take defaults: 16384 counters per row and a reset every
+// 10 * Width requests.
+type Config struct {
+	// Threshold is the number of requests within a window after which an
+	// id is admitted.
+	Threshold int
+	// Width is the number of counters per row, more counters mean fewer
+	// collisions between ids.
+	Width int
+	// ResetAfter is the number of requests after which all counters are
+	// halved.
+	ResetAfter int
+}
+
+// Stats tell how the policy behaves, for tuning Config.
+type Stats struct {
+	Threshold  int    `json:"threshold"`
+	Width      int    `json:"width"`
+	ResetAfter int    `json:"reset_after"`
+	Requests   uint64 `json:"requests"`
+	Admitted   uint64 `json:"admitted"`
+	Rejected   uint64 `json:"rejected"`
+	Resets     uint64 `json:"resets"`
+}
+
+// Policy is safe for concurrent use.
+type Policy struct {
+	seed       maphash.Seed
+	threshold  int
+	mask       uint64
+	resetAfter int
+
+	mu       sync.Mutex
+	counters [depth][]uint16
+	window   int
+	stats    Stats
+}
+
+func New(cfg Config) *Policy {
+	width := cfg.Width
+	if width <= 0 {
+		width = defaultWidth
+	}
+	width = 1 << bits.Len(uint(width-1))
+
+	resetAfter := cfg.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = 10 * width
+	}
+
+	threshold := min(max(cfg.Threshold, 1), maxCount)
+
+	p := &Policy{
+		seed:       maphash.MakeSeed(),
+		threshold:  threshold,
+		mask:       uint64(width - 1),
+		resetAfter: resetAfter,
+		stats: Stats{
+			Threshold:  threshold,
+			Width:      width,
+			ResetAfter: resetAfter,
+		},
+	}
+	for i := range p.counters {
+		p.counters[i] = make([]uint16, width)
+	}
+
+	return p
+}
+
+// Admit records a request of id and tells whether id has been requested at
+// least Threshold times in the current window.
+func (p *Policy) Admit(id uuid.UUID) bool {
+	h := maphash.Bytes(p.seed, id[:])
+	h1, h2 := h&0xffffffff, h>>32|1
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the estimate is the smallest of the counters, collisions only ever
+	// add to a counter
+	estimate := maxCount
+	for i := range p.counters {
+		idx := (h1 + uint64(i)*h2) & p.mask
+		if p.counters[i][idx] < maxCount {
+			p.counters[i][idx]++
+		}
+		estimate = min(estimate, int(p.counters[i][idx]))
+	}
+
+	p.window++
+	if p.window >= p.resetAfter {
+		p.reset()
+	}
+
+	p.stats.Requests++
+	if estimate >= p.threshold {
+		p.stats.Admitted++
+		return true
+	}
+
+	p.stats.Rejected++
+	return false
+}
+
+// Stats returns a snapshot of the counters.
+func (p *Policy) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+// reset halves every counter, so past requests weigh less than recent ones,
+// and starts a new window of ResetAfter requests.
+func (p *Policy) reset() {
+	for i := range p.counters {
+		for j := range p.counters[i] {
+			p.counters[i][j] >>= 1
+		}
+	}
+	p.window = 0
+	p.stats.Resets++
+}
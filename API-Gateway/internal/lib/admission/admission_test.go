@@ -0,0 +1,138 @@
+package admission_test
+
+import (
+	"sync"
+	"testing"
+
+	"api-gateway/internal/lib/admission"
+
+	"github.com/google/uuid"
+)
+
+func TestNew_Defaults(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  admission.Config
+		want admission.Stats
+	}{
+		{"zero config", admission.Config{}, admission.Stats{Threshold: 1, Width: 1 << 14, ResetAfter: 10 << 14}},
+		{"width rounded up", admission.Config{Threshold: 3, Width: 1000}, admission.Stats{Threshold: 3, Width: 1024, ResetAfter: 10240}},
+		{"explicit reset", admission.Config{Threshold: 2, Width: 64, ResetAfter: 100}, admission.Stats{Threshold: 2, Width: 64, ResetAfter: 100}},
+		{"threshold capped", admission.Config{Threshold: 1 << 20, Width: 16}, admission.Stats{Threshold: 1<<16 - 1, Width: 16, ResetAfter: 160}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := admission.New(tt.cfg).Stats(); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAdmit_Threshold(t *testing.T) {
+	tests := []struct {
+		threshold int
+		requests  int
+		want      []bool
+	}{
+		{threshold: 1, requests: 2, want: []bool{true, true}},
+		{threshold: 2, requests: 3, want: []bool{false, true, true}},
+		{threshold: 5, requests: 6, want: []bool{false, false, false, false, true, true}},
+	}
+
+	for _, tt := range tests {
+		p := admission.New(admission.Config{Threshold: tt.threshold})
+		id := uuid.New()
+
+		for i := range tt.requests {
+			if got := p.Admit(id); got != tt.want[i] {
+				t.Errorf("threshold %d, request %d: expected %v, got %v", tt.threshold, i+1, tt.want[i], got)
+			}
+		}
+	}
+}
+
+func TestAdmit_IdsCountedApart(t *testing.T) {
+	p := admission.New(admission.Config{Threshold: 3})
+	hot, cold := uuid.New(), uuid.New()
+
+	for range 10 {
+		p.Admit(hot)
+	}
+
+	if p.Admit(cold) {
+		t.Error("expected an id requested once to be rejected")
+	}
+	if !p.Admit(hot) {
+		t.Error("expected a hot id to be admitted")
+	}
+}
+
+func TestAdmit_Aging(t *testing.T) {
+	p := admission.New(admission.Config{Threshold: 4, ResetAfter: 8})
+	id := uuid.New()
+
+	for range 3 {
+		p.Admit(id)
+	}
+	for range 5 {
+		p.Admit(uuid.New())
+	}
+	if got := p.Stats().Resets; got != 1 {
+		t.Fatalf("expected a reset after 8 requests, got %d", got)
+	}
+
+	// 3 requests were halved to 1, one more does not reach the threshold
+	if p.Admit(id) {
+		t.Error("expected the halved count to stay below the threshold")
+	}
+
+	// a new window is a full ResetAfter requests long
+	for range 6 {
+		p.Admit(uuid.New())
+	}
+	if got := p.Stats().Resets; got != 1 {
+		t.Errorf("expected no reset within the window, got %d", got)
+	}
+	p.Admit(uuid.New())
+	if got := p.Stats().Resets; got != 2 {
+		t.Errorf("expected a reset at the end of the window, got %d", got)
+	}
+}
+
+func TestAdmit_Stats(t *testing.T) {
+	p := admission.New(admission.Config{Threshold: 2})
+	id := uuid.New()
+
+	for range 3 {
+		p.Admit(id)
+	}
+
+	stats := p.Stats()
+	if stats.Requests != 3 || stats.Admitted != 2 || stats.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdmit_Concurrent(t *testing.T) {
+	p := admission.New(admission.Config{Threshold: 2, Width: 64, ResetAfter: 100})
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 250 {
+				p.Admit(ids[(i+j)%len(ids)])
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := p.Stats()
+	if stats.Requests != 2000 || stats.Admitted+stats.Rejected != 2000 || stats.Resets != 20 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,134 @@
+// Package health tracks whether the dependencies of the gateway are
+// reachable, so that an outage degrades the gateway instead of stopping it.
+package health
+
+import (
+	"api-gateway/pkg/lib/logger/sl"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff   = 500 * time.Millisecond
+	maxBackoff   = 30 * time.Second
+	probeTimeout = 2 * time.Second
+)
+
+// Status is what readiness reports about a dependency.
+type Status struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Since     time.Time `json:"since"`
+	LastError string    `json:"last_error,omitempty"`
+	Failures  uint64    `json:"failures"`
+}
+
+// Monitor follows one dependency. Once it fails, the dependency is probed
+// in the background with exponential backoff until it answers again;
+// meanwhile callers skip it instead of waiting for their own timeouts.
+type Monitor struct {
+	log   *slog.Logger
+	name  string
+	probe func(ctx context.Context) error
+
+	mu      sync.Mutex
+	status  Status
+	probing bool
+	done    chan struct{}
+}
+
+// NewMonitor probes the dependency once, a failure only starts the
+// background probing.
+func NewMonitor(log *slog.Logger, name string, probe func(ctx context.Context) error) *Monitor {
+	m := &Monitor{
+		log:   log.With(slog.String("dependency", name)),
+		name:  name,
+		probe: probe,
+		status: Status{
+			Name:    name,
+			Healthy: true,
+			Since:   time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	if err := probe(ctx); err != nil {
+		m.Fail(err)
+	}
+
+	return m
+}
+
+// Healthy tells whether the dependency answered last time.
+func (m *Monitor) Healthy() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status.Healthy
+}
+
+// Fail reports an error of the dependency and starts probing it.
+func (m *Monitor) Fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.Failures++
+	m.status.LastError = err.Error()
+	if m.status.Healthy {
+		m.status.Healthy = false
+		m.status.Since = time.Now()
+		m.log.Warn("Dependency is unavailable, running degraded", sl.Err(err))
+	}
+
+	if !m.probing {
+		m.probing = true
+		go m.recover()
+	}
+}
+
+// Status returns a snapshot for readiness.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status
+}
+
+// Close stops the background probing.
+func (m *Monitor) Close() {
+	close(m.done)
+}
+
+func (m *Monitor) recover() {
+	backoff := minBackoff
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		err := m.probe(ctx)
+		cancel()
+
+		m.mu.Lock()
+		if err == nil {
+			m.status.Healthy = true
+			m.status.Since = time.Now()
+			m.probing = false
+			m.mu.Unlock()
+			m.log.Info("Dependency is available again")
+			return
+		}
+		m.status.LastError = err.Error()
+		m.mu.Unlock()
+
+		backoff = min(2*backoff, maxBackoff)
+		m.log.Debug("Dependency is still unavailable", sl.Err(err), slog.Duration("retry_in", backoff))
+	}
+}
@@ -0,0 +1,268 @@
+package jwt
+
+import (
+	"api-gateway/internal/domain/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	typeAccess = "access"
+
+	// jwksTTL bounds how long a fetched key set is trusted, so keys removed
+	// from the Auth service stop verifying.
+	jwksTTL = 10 * time.Minute
+	// jwksMinRefetch rate limits refetches caused by an unknown kid.
+	jwksMinRefetch = time.Minute
+	// jwksFetchTimeout bounds a fetch shared by concurrent requests, it
+	// does not depend on the request that started it.
+	jwksFetchTimeout = 5 * time.Second
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type IKeysSource interface {
+	JWKS(ctx context.Context) ([]models.JSONWebKey, error)
+}
+
+// claims mirrors the access token claims issued by the Auth service.
+type claims struct {
+	UID    uuid.UUID `json:"uid"`
+	Login  string    `json:"login"`
+	Role   string    `json:"role"`
+	Type   string    `json:"typ"`
+	Family uuid.UUID `json:"fid"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks access tokens offline. Asymmetric tokens are verified
+// with the key set published by the Auth service, HS256 tokens with the
+// shared secret of their kid, if one is configured.
+type Verifier struct {
+	issuer   string
+	audience string
+	secrets  map[string][]byte
+	source   IKeysSource
+
+	mu        sync.Mutex
+	keys      map[string]publicKey
+	fetchedAt time.Time
+	fetches   singleflight.Group
+}
+
+// NewVerifier creates a verifier, secrets are the HS256 secrets by kid.
+func NewVerifier(issuer string, audience string, secrets map[string][]byte, source IKeysSource) *Verifier {
+	return &Verifier{
+		issuer:   issuer,
+		audience: audience,
+		secrets:  secrets,
+		source:   source,
+		keys:     make(map[string]publicKey),
+	}
+}
+
+// ParseAccessToken verifies signature, expiry, issuer, audience and type of
+// an access token.
+func (v *Verifier) ParseAccessToken(ctx context.Context, token string) (models.AccessToken, error) {
+	var c claims
+
+	_, err := jwt.ParseWithClaims(token, &c, func(t *jwt.Token) (any, error) {
+		return v.keyFunc(ctx, t)
+	},
+		jwt.WithValidMethods([]string{algorithmHS256, algorithmRS256, algorithmES256, algorithmEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+	)
+	if err != nil {
+		return models.AccessToken{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if c.Type != typeAccess {
+		return models.AccessToken{}, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+
+	id, err := uuid.Parse(c.ID)
+	if err != nil || c.UID == uuid.Nil {
+		return models.AccessToken{}, fmt.Errorf("%w: missing jti or uid", ErrInvalidToken)
+	}
+
+	return models.AccessToken{
+		Id:        id,
+		UserId:    c.UID,
+		FamilyId:  c.Family,
+		Login:     c.Login,
+		Role:      c.Role,
+		ExpiresAt: c.ExpiresAt.Time,
+	}, nil
+}
+
+// TokenId reads the jti of token without verifying it, so it is only
+// meant for tokens the Auth service has accepted already.
+func TokenId(token string) (uuid.UUID, error) {
+	var c claims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &c); err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	id, err := uuid.Parse(c.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: missing jti", ErrInvalidToken)
+	}
+
+	return id, nil
+}
+
+// keyFunc picks the verification key. The algorithm must match the key,
+// otherwise a public key could be used as an HMAC secret.
+func (v *Verifier) keyFunc(ctx context.Context, t *jwt.Token) (any, error) {
+	alg := t.Method.Alg()
+	kid, _ := t.Header["kid"].(string)
+	if alg == algorithmHS256 {
+		// HMAC secrets are never published, during a rotation both the old
+		// and the new one are configured
+		if len(v.secrets) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		secret, ok := v.secrets[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return secret, nil
+	}
+
+	key, err := v.lookup(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.algorithm != alg {
+		return nil, fmt.Errorf("algorithm %s does not match key %q", alg, kid)
+	}
+
+	return key.key, nil
+}
+
+// lookup returns the key with the given kid, refetching the key set when it
+// is stale or the kid is unknown, e.g. right after a key rotation. The key
+// set is fetched outside mu, concurrent lookups share one fetch.
+func (v *Verifier) lookup(ctx context.Context, kid string) (publicKey, error) {
+	v.mu.Lock()
+	now := time.Now()
+	key, ok := v.keys[kid]
+	stale := now.Sub(v.fetchedAt) > jwksTTL
+	recent := now.Sub(v.fetchedAt) < jwksMinRefetch
+	v.mu.Unlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && recent {
+		return publicKey{}, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	ch := v.fetches.DoChan("jwks", func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+		defer cancel()
+
+		return nil, v.refresh(fetchCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return publicKey{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return publicKey{}, fmt.Errorf("cannot fetch JWKS: %w", res.Err)
+		}
+	}
+
+	v.mu.Lock()
+	key, ok = v.keys[kid]
+	v.mu.Unlock()
+	if !ok {
+		return publicKey{}, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	return key, nil
+}
+
+// refresh fetches and replaces the cached key set. A failed fetch counts
+// as one too, so a down Auth service is not asked on every request. Keys
+// that cannot be parsed are skipped so one bad entry does not block the
+// rest.
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	jwks, err := v.source.JWKS(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]publicKey, len(jwks))
+	for _, jwk := range jwks {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+
+	return nil
+}
+
+// LoadSecrets reads the HS256 secrets from <kid>.secret files in dir, the
+// layout of the Auth service JWT_KEYS_DIR. Other files are ignored.
+func LoadSecrets(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".secret" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil, fmt.Errorf("%s: empty secret", entry.Name())
+		}
+		secrets[strings.TrimSuffix(entry.Name(), ".secret")] = []byte(secret)
+	}
+
+	return secrets, nil
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
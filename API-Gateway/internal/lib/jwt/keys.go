@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"api-gateway/internal/domain/models"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	algorithmHS256 = "HS256"
+	algorithmRS256 = "RS256"
+	algorithmES256 = "ES256"
+	algorithmEdDSA = "EdDSA"
+)
+
+var errUnsupportedKey = errors.New("unsupported key")
+
+type publicKey struct {
+	algorithm string
+	key       any
+}
+
+// parseJWK restores a public key published by the Auth service
+// (RFC 7517, 7518, 8037).
+func parseJWK(jwk models.JSONWebKey) (publicKey, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+
+	switch {
+	case jwk.Kty == "RSA" && jwk.Alg == algorithmRS256:
+		n, err := b64(jwk.N)
+		if err != nil {
+			return publicKey{}, err
+		}
+		e, err := b64(jwk.E)
+		if err != nil {
+			return publicKey{}, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return publicKey{}, fmt.Errorf("%w: bad RSA exponent", errUnsupportedKey)
+		}
+
+		return publicKey{
+			algorithm: algorithmRS256,
+			key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(exp.Int64()),
+			},
+		}, nil
+	case jwk.Kty == "EC" && jwk.Alg == algorithmES256 && jwk.Crv == "P-256":
+		x, err := b64(jwk.X)
+		if err != nil {
+			return publicKey{}, err
+		}
+		y, err := b64(jwk.Y)
+		if err != nil {
+			return publicKey{}, err
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if _, err := key.ECDH(); err != nil {
+			return publicKey{}, fmt.Errorf("%w: point is not on curve", errUnsupportedKey)
+		}
+
+		return publicKey{algorithm: algorithmES256, key: key}, nil
+	case jwk.Kty == "OKP" && jwk.Alg == algorithmEdDSA && jwk.Crv == "Ed25519":
+		x, err := b64(jwk.X)
+		if err != nil {
+			return publicKey{}, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return publicKey{}, fmt.Errorf("%w: bad Ed25519 key size", errUnsupportedKey)
+		}
+
+		return publicKey{algorithm: algorithmEdDSA, key: ed25519.PublicKey(x)}, nil
+	default:
+		return publicKey{}, fmt.Errorf("%w: kty %q, alg %q", errUnsupportedKey, jwk.Kty, jwk.Alg)
+	}
+}
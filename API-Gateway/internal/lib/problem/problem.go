@@ -0,0 +1,101 @@
+// Package problem writes error responses as RFC 7807 problem details.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chas3air/protos/apierror"
+)
+
+const MediaType = "application/problem+json"
+
+// statusByReason maps the error catalogue to HTTP. Versions are only ever
+// compared through If-Match, so a version conflict is a failed
+// precondition.
+var statusByReason = map[apierror.Reason]int{
+	apierror.MalformedRequest:   http.StatusBadRequest,
+	apierror.ValidationFailed:   http.StatusUnprocessableEntity,
+	apierror.UserNotFound:       http.StatusNotFound,
+	apierror.UserAlreadyExists:  http.StatusConflict,
+	apierror.VersionConflict:    http.StatusPreconditionFailed,
+	apierror.InvalidCredentials: http.StatusUnauthorized,
+	apierror.InvalidToken:       http.StatusUnauthorized,
+	apierror.PermissionDenied:   http.StatusForbidden,
+	apierror.DeadlineExceeded:   http.StatusGatewayTimeout,
+	apierror.Unavailable:        http.StatusServiceUnavailable,
+	apierror.Internal:           http.StatusInternalServerError,
+}
+
+// InvalidParam names a field of the request that was rejected.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Problem is the body of an error response. Code is the reason from the
+// error catalogue, when the error came from one of the services.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Code          string         `json:"code,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// Error replies with a problem of status, like http.Error does with plain
+// text.
+func Error(w http.ResponseWriter, detail string, status int) {
+	Write(w, Problem{
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// FromError replies with the error of the catalogue found in err, keeping
+// its message and invalid fields. Other errors are replied to with detail
+// and status.
+func FromError(w http.ResponseWriter, err error, detail string, status int) {
+	apiErr, ok := apierror.FromError(err)
+	if !ok {
+		Error(w, detail, status)
+		return
+	}
+
+	if s, ok := statusByReason[apiErr.Reason]; ok {
+		status = s
+	}
+	if apiErr.Message != "" {
+		detail = apiErr.Message
+	}
+
+	p := Problem{
+		Status: status,
+		Detail: detail,
+		Code:   apiErr.Reason.String(),
+	}
+	for _, v := range apiErr.Violations {
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: v.Field, Reason: v.Description})
+	}
+
+	Write(w, p)
+}
+
+// Write replies with p, an empty type and title are filled in from the
+// status.
+func Write(w http.ResponseWriter, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", MediaType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
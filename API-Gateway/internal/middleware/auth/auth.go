@@ -0,0 +1,250 @@
+package authmiddleware
+
+import (
+	"api-gateway/internal/domain/models"
+	"api-gateway/internal/lib/jwt"
+	"api-gateway/internal/lib/problem"
+	serviceerror "api-gateway/internal/service"
+	"api-gateway/pkg/lib/logger/sl"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const RoleAdmin = "admin"
+
+// ITokensService tells whether a verified access token is still active,
+// the Auth service holds the denylist.
+type ITokensService interface {
+	IsTokenActive(ctx context.Context, jti uuid.UUID, accessToken string) (bool, error)
+}
+
+type ITokenVerifier interface {
+	ParseAccessToken(ctx context.Context, token string) (models.AccessToken, error)
+}
+
+type IAuthService interface {
+	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+}
+
+type ctxKey struct{}
+
+type AuthMiddleware struct {
+	log      *slog.Logger
+	verifier ITokenVerifier
+	tokens   ITokensService
+	service  IAuthService
+	clients  map[string]string
+}
+
+// New creates the middleware, clients are the secrets of the services
+// AuthenticateClient lets through by client id.
+func New(log *slog.Logger, verifier ITokenVerifier, tokens ITokensService, service IAuthService, clients map[string]string) *AuthMiddleware {
+	return &AuthMiddleware{
+		log:      log,
+		verifier: verifier,
+		tokens:   tokens,
+		service:  service,
+		clients:  clients,
+	}
+}
+
+// TokenFromContext returns the access token put into the request context
+// by Authenticate.
+func TokenFromContext(ctx context.Context) (models.AccessToken, bool) {
+	token, ok := ctx.Value(ctxKey{}).(models.AccessToken)
+	return token, ok
+}
+
+// Authenticate rejects requests without a valid, not revoked bearer access
+// token with 401.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "middleware.auth.Authenticate"
+		log := m.log.With(
+			"op", op,
+		)
+
+		raw := jwt.BearerToken(r)
+		if raw == "" {
+			log.Warn("Bearer token is required")
+			unauthorized(w, "Bearer token is required", false)
+			return
+		}
+
+		token, err := m.verifier.ParseAccessToken(r.Context(), raw)
+		if err != nil {
+			log.Warn("Invalid access token", sl.Err(err))
+			unauthorized(w, "Invalid access token", true)
+			return
+		}
+
+		// a logout denies either the token itself or its whole session, Auth
+		// decides about both
+		active, err := m.tokens.IsTokenActive(r.Context(), token.Id, raw)
+		if errors.Is(err, serviceerror.ErrUnavailable) {
+			// fail closed, a revoked token must not get through
+			problem.Error(w, "Cannot verify access token", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Error("Cannot check access token", sl.Err(err))
+			problem.Error(w, "Cannot verify access token", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			log.Warn("Access token revoked", slog.String("jti", token.Id.String()))
+			unauthorized(w, "Access token revoked", true)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, token)))
+	})
+}
+
+// AuthenticateClient lets through services presenting the id and secret of
+// a configured client with HTTP Basic, others get 401. It guards endpoints
+// meant for services, which hold no user token.
+func (m *AuthMiddleware) AuthenticateClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "middleware.auth.AuthenticateClient"
+		log := m.log.With(
+			"op", op,
+		)
+
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			log.Warn("Client credentials are required")
+			clientUnauthorized(w, "Client credentials are required")
+			return
+		}
+
+		if !m.isClient(id, secret) {
+			log.Warn("Invalid client credentials", slog.String("client_id", id))
+			clientUnauthorized(w, "Invalid client credentials")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isClient compares digests, so neither the secret nor its length leaks
+// through timing.
+func (m *AuthMiddleware) isClient(id string, secret string) bool {
+	expected, ok := m.clients[id]
+	got, want := sha256.Sum256([]byte(secret)), sha256.Sum256([]byte(expected))
+
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1 && ok && expected != ""
+}
+
+// RequireAdmin lets through admins only, others get 403. Must be wrapped by
+// Authenticate.
+func (m *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "middleware.auth.RequireAdmin"
+		log := m.log.With(
+			"op", op,
+		)
+
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			log.Error("RequireAdmin is used without Authenticate")
+			unauthorized(w, "Bearer token is required", false)
+			return
+		}
+
+		if !m.isAdmin(r.Context(), log, w, token) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireSelfOrAdmin lets through the user named by the {id} route variable
+// and admins, others get 403. Must be wrapped by Authenticate.
+func (m *AuthMiddleware) RequireSelfOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "middleware.auth.RequireSelfOrAdmin"
+		log := m.log.With(
+			"op", op,
+		)
+
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			log.Error("RequireSelfOrAdmin is used without Authenticate")
+			unauthorized(w, "Bearer token is required", false)
+			return
+		}
+
+		if id, err := uuid.Parse(mux.Vars(r)["id"]); err == nil && id == token.UserId {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !m.isAdmin(r.Context(), log, w, token) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin checks the role claim first and then confirms it with the Auth
+// service, since the role may have been taken away after the token was
+// issued. A token of a user that no longer exists gets 401. It writes the
+// error response itself.
+func (m *AuthMiddleware) isAdmin(ctx context.Context, log *slog.Logger, w http.ResponseWriter, token models.AccessToken) bool {
+	if token.Role != RoleAdmin {
+		log.Warn("Forbidden", slog.String("uid", token.UserId.String()))
+		problem.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	isAdmin, err := m.service.IsAdmin(ctx, token.UserId)
+	if errors.Is(err, serviceerror.ErrNotFound) {
+		log.Warn("Token user not found", slog.String("uid", token.UserId.String()))
+		unauthorized(w, "Invalid access token", true)
+		return false
+	}
+	if errors.Is(err, serviceerror.ErrUnavailable) {
+		problem.Error(w, "Cannot check permissions", http.StatusServiceUnavailable)
+		return false
+	}
+	if err != nil {
+		// the catalogue error belongs to the permission check, not to the
+		// request, so its status is not passed on
+		log.Error("Cannot check is an user admin", sl.Err(err))
+		problem.Error(w, "Cannot check permissions", http.StatusInternalServerError)
+		return false
+	}
+	if !isAdmin {
+		log.Warn("Role claim is outdated", slog.String("uid", token.UserId.String()))
+		problem.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func clientUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+	problem.Error(w, message, http.StatusUnauthorized)
+}
+
+func unauthorized(w http.ResponseWriter, message string, invalidToken bool) {
+	challenge := "Bearer"
+	if invalidToken {
+		challenge += ` error="invalid_token"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	problem.Error(w, message, http.StatusUnauthorized)
+}
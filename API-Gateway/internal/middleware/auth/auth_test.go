@@ -0,0 +1,170 @@
+package authmiddleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway/internal/domain/models"
+	authmiddleware "api-gateway/internal/middleware/auth"
+	authservice "api-gateway/internal/service/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeAuthServer keeps the denylist of the Auth service.
+type fakeAuthServer struct {
+	authservice.IAuthServer
+
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func newFakeAuthServer() *fakeAuthServer {
+	return &fakeAuthServer{revoked: make(map[string]bool)}
+}
+
+func (f *fakeAuthServer) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.revoked[accessToken] = true
+	return nil
+}
+
+func (f *fakeAuthServer) ValidateToken(ctx context.Context, accessToken string) (models.TokenIntrospection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return models.TokenIntrospection{Active: !f.revoked[accessToken]}, nil
+}
+
+// fakeVerifier accepts every token and reads its jti.
+type fakeVerifier struct{}
+
+func (fakeVerifier) ParseAccessToken(ctx context.Context, token string) (models.AccessToken, error) {
+	var c jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &c); err != nil {
+		return models.AccessToken{}, err
+	}
+
+	return models.AccessToken{Id: uuid.MustParse(c.ID), UserId: uuid.New()}, nil
+}
+
+func newToken(t *testing.T) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{ID: uuid.NewString()}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("cannot sign token: %v", err)
+	}
+
+	return token
+}
+
+func authenticate(m *authmiddleware.AuthMiddleware, token string) int {
+	handler := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w.Code
+}
+
+func TestAuthenticate_AfterLogout(t *testing.T) {
+	server := newFakeAuthServer()
+	// two gateways in front of the same Auth service, both caching well
+	// past the logout
+	first := authservice.New(discard, server, time.Minute)
+	second := authservice.New(discard, server, time.Minute)
+	firstMiddleware := authmiddleware.New(discard, fakeVerifier{}, first, first, nil)
+	secondMiddleware := authmiddleware.New(discard, fakeVerifier{}, second, second, nil)
+	token := newToken(t)
+
+	for name, m := range map[string]*authmiddleware.AuthMiddleware{"first": firstMiddleware, "second": secondMiddleware} {
+		if code := authenticate(m, token); code != http.StatusNoContent {
+			t.Fatalf("%s gateway: expected %d before logout, got %d", name, http.StatusNoContent, code)
+		}
+	}
+
+	if err := first.Logout(context.Background(), token, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	for name, m := range map[string]*authmiddleware.AuthMiddleware{"first": firstMiddleware, "second": secondMiddleware} {
+		if code := authenticate(m, token); code != http.StatusUnauthorized {
+			t.Errorf("%s gateway: expected %d after logout, got %d", name, http.StatusUnauthorized, code)
+		}
+	}
+}
+
+func TestAuthenticate_RevokedCached(t *testing.T) {
+	server := newFakeAuthServer()
+	service := authservice.New(discard, server, time.Minute)
+	m := authmiddleware.New(discard, fakeVerifier{}, service, service, nil)
+	token := newToken(t)
+
+	if err := service.Logout(context.Background(), token, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	// Auth would let the token through now, the gateway remembers the logout
+	server.mu.Lock()
+	server.revoked = map[string]bool{}
+	server.mu.Unlock()
+
+	if code := authenticate(m, token); code != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	clients := map[string]string{"billing": "s3cret", "empty": ""}
+	m := authmiddleware.New(discard, fakeVerifier{}, nil, nil, clients)
+	handler := m.AuthenticateClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+		basic  bool
+		want   int
+	}{
+		{"known client", "billing", "s3cret", true, http.StatusNoContent},
+		{"wrong secret", "billing", "guess", true, http.StatusUnauthorized},
+		{"unknown client", "reports", "s3cret", true, http.StatusUnauthorized},
+		{"empty secret", "empty", "", true, http.StatusUnauthorized},
+		{"no credentials", "", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/introspect", nil)
+			if tt.basic {
+				r.SetBasicAuth(tt.id, tt.secret)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("expected a Basic challenge")
+			}
+		})
+	}
+}
@@ -2,16 +2,23 @@ package authservice
 
 import (
 	"api-gateway/internal/domain/models"
+	"api-gateway/internal/lib/jwt"
+	serviceerror "api-gateway/internal/service"
+	storageerror "api-gateway/internal/storage"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
 	Insert(ctx context.Context, user models.User) (models.User, error)
 	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
@@ -22,17 +29,42 @@ type IAuthServer interface {
 	Login(ctx context.Context, login string, password string) (string, string, error)
 	Register(ctx context.Context, user models.User) (models.User, error)
 	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+	Refresh(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	GetJWKS(ctx context.Context) ([]models.JSONWebKey, error)
+	ValidateToken(ctx context.Context, accessToken string) (models.TokenIntrospection, error)
 }
 
+const (
+	// maxRevokedTokens bounds the cache of revoked tokens, it is emptied
+	// once full of live entries.
+	maxRevokedTokens = 100000
+	// validateTimeout bounds a check shared by concurrent requests, it
+	// does not depend on the request that started it.
+	validateTimeout = 5 * time.Second
+)
+
+// AuthService asks the Auth service whether access tokens are still active
+// on every request, so a revocation reaches every gateway at once whatever
+// storage Auth keeps its denylist in. Only revoked tokens are cached, for
+// revokedTTL, a revoked token never becomes active again.
 type AuthService struct {
 	log        *slog.Logger
 	authServer IAuthServer
+	revokedTTL time.Duration
+
+	mu      sync.Mutex
+	revoked map[uuid.UUID]time.Time
+	checks  singleflight.Group
 }
 
-func New(log *slog.Logger, authServer IAuthServer) *AuthService {
+// New creates the service, revokedTTL 0 caches no revoked tokens.
+func New(log *slog.Logger, authServer IAuthServer, revokedTTL time.Duration) *AuthService {
 	return &AuthService{
 		log:        log,
 		authServer: authServer,
+		revokedTTL: revokedTTL,
+		revoked:    make(map[uuid.UUID]time.Time),
 	}
 }
 
@@ -52,14 +84,212 @@ func (a *AuthService) Login(ctx context.Context, login string, password string)
 
 	accessToken, refreshToken, err := a.authServer.Login(ctx, login, password)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidCredentials) {
+			log.Warn("Invalid credentials", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidCredentials, err)
+		}
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid argument", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+
 		log.Error("Cannot login", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	_ = accessToken
-	_ = refreshToken
+	return accessToken, refreshToken, nil
+}
+
+// Refresh implements auth.IAuthService.
+func (a *AuthService) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	const op = "service.auth.Refresh"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return "", "", fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	accessToken, newRefreshToken, err := a.authServer.Refresh(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidToken) {
+			log.Warn("Invalid refresh token", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidToken, err)
+		}
+
+		log.Error("Cannot refresh tokens", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, newRefreshToken, nil
+}
+
+// Logout implements auth.IAuthService.
+func (a *AuthService) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	const op = "service.auth.Logout"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if err := a.authServer.Logout(ctx, accessToken, refreshToken); err != nil {
+		if errors.Is(err, storageerror.ErrInvalidToken) {
+			log.Warn("Invalid token", sl.Err(err))
+			return fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidToken, err)
+		}
+
+		log.Error("Cannot logout", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Auth has accepted the token, so its jti can be trusted
+	if accessToken != "" {
+		if jti, err := jwt.TokenId(accessToken); err == nil {
+			a.cacheRevoked(jti)
+		}
+	}
+
+	return nil
+}
+
+// Introspect implements auth.IAuthService.
+func (a *AuthService) Introspect(ctx context.Context, accessToken string) (models.TokenIntrospection, error) {
+	const op = "service.auth.Introspect"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	introspection, err := a.authServer.ValidateToken(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid argument", sl.Err(err))
+			return models.TokenIntrospection{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+
+		log.Error("Cannot validate token", sl.Err(err))
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return introspection, nil
+}
+
+// IsTokenActive implements authmiddleware.ITokensService.
+// The signature of accessToken must have been verified already, jti only
+// keys the cache. Concurrent checks of one token share a single request.
+func (a *AuthService) IsTokenActive(ctx context.Context, jti uuid.UUID, accessToken string) (bool, error) {
+	const op = "service.auth.IsTokenActive"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return false, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if a.isRevoked(jti) {
+		return false, nil
+	}
+
+	ch := a.checks.DoChan(accessToken, func() (any, error) {
+		checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), validateTimeout)
+		defer cancel()
+
+		introspection, err := a.authServer.ValidateToken(checkCtx, accessToken)
+		if err != nil {
+			return false, err
+		}
+
+		if !introspection.Active {
+			a.cacheRevoked(jti)
+		}
+		return introspection.Active, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return false, fmt.Errorf("%s: %w", op, ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			if errors.Is(res.Err, storageerror.ErrUnavailable) {
+				log.Warn("Auth service is unavailable", sl.Err(res.Err))
+				return false, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrUnavailable, res.Err)
+			}
+
+			log.Error("Cannot validate token", sl.Err(res.Err))
+			return false, fmt.Errorf("%s: %w", op, res.Err)
+		}
+
+		return res.Val.(bool), nil
+	}
+}
+
+func (a *AuthService) isRevoked(jti uuid.UUID) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	return accessToken, "", nil
+	expiresAt, ok := a.revoked[jti]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (a *AuthService) cacheRevoked(jti uuid.UUID) {
+	if a.revokedTTL <= 0 || jti == uuid.Nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if len(a.revoked) >= maxRevokedTokens {
+		for id, expiresAt := range a.revoked {
+			if now.After(expiresAt) {
+				delete(a.revoked, id)
+			}
+		}
+	}
+	if len(a.revoked) >= maxRevokedTokens {
+		clear(a.revoked)
+	}
+
+	a.revoked[jti] = now.Add(a.revokedTTL)
+}
+
+// JWKS implements auth.IAuthService.
+func (a *AuthService) JWKS(ctx context.Context) ([]models.JSONWebKey, error) {
+	const op = "service.auth.JWKS"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	keys, err := a.authServer.GetJWKS(ctx)
+	if err != nil {
+		log.Error("Cannot get JWKS", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
 }
 
 // Register implements auth.IAuthService.
@@ -78,6 +308,15 @@ func (a *AuthService) Register(ctx context.Context, userForRegister models.User)
 
 	registeredUser, err := a.authServer.Register(ctx, userForRegister)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrAlreadyExists) {
+			log.Warn("User already exists", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrAlreadyExists, err)
+		}
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+
 		log.Error("Cannot register", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -101,6 +340,13 @@ func (a *AuthService) IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
 
 	isAdmin, err := a.authServer.IsAdmin(ctx, uid)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("User not found", slog.String("uid", uid.String()))
+			return false, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, err)
+		}
+		if errors.Is(err, storageerror.ErrUnavailable) {
+			return false, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrUnavailable, err)
+		}
 		log.Error("Cannot check is an user admin", sl.Err(err))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
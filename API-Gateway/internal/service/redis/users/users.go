@@ -6,6 +6,7 @@ import (
 	storageerror "api-gateway/internal/storage"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -15,6 +16,7 @@ import (
 type UsersCashStorage interface {
 	Get(context.Context, uuid.UUID) (models.User, error)
 	Set(context.Context, models.User) error
+	SetNotFound(ctx context.Context, id uuid.UUID, version int64) error
 	Del(context.Context, uuid.UUID) error
 }
 
@@ -30,6 +32,8 @@ func New(log *slog.Logger, storage UsersCashStorage) *UsersCashService {
 	}
 }
 
+// Get returns ErrCacheMiss when the user is not cached and ErrNotFound
+// when it is cached as missing.
 func (u *UsersCashService) Get(ctx context.Context, id uuid.UUID) (models.User, error) {
 	const op = "service.redis.users.Get"
 	log := u.log.With(
@@ -44,8 +48,14 @@ func (u *UsersCashService) Get(ctx context.Context, id uuid.UUID) (models.User,
 
 	userFromCash, err := u.storage.Get(ctx, id)
 	if err != nil {
-		if err == storageerror.ErrNotFound {
-			log.Warn("not found", sl.Err(err))
+		if errors.Is(err, storageerror.ErrUnavailable) {
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrUnavailable)
+		}
+		if errors.Is(err, storageerror.ErrCacheMiss) {
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrCacheMiss)
+		}
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Info("Cached as not found", sl.Err(err))
 			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
 		}
 
@@ -69,6 +79,15 @@ func (u *UsersCashService) Set(ctx context.Context, user models.User) error {
 	}
 
 	if err := u.storage.Set(ctx, user); err != nil {
+		// the storage reports outages itself, once
+		if errors.Is(err, storageerror.ErrUnavailable) {
+			return fmt.Errorf("%s: %w", op, serviceerror.ErrUnavailable)
+		}
+		// a newer version is cached already
+		if errors.Is(err, storageerror.ErrConflict) {
+			return fmt.Errorf("%s: %w", op, serviceerror.ErrConflict)
+		}
+
 		log.Error("Error set user to cash", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -76,6 +95,36 @@ func (u *UsersCashService) Set(ctx context.Context, user models.User) error {
 	return nil
 }
 
+// SetNotFound caches the user as missing. version is that of the write
+// that removed it, so older reads in flight do not bring it back.
+func (u *UsersCashService) SetNotFound(ctx context.Context, id uuid.UUID, version int64) error {
+	const op = "service.redis.users.SetNotFound"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if err := u.storage.SetNotFound(ctx, id, version); err != nil {
+		// the storage reports outages itself, once
+		if errors.Is(err, storageerror.ErrUnavailable) {
+			return fmt.Errorf("%s: %w", op, serviceerror.ErrUnavailable)
+		}
+		if errors.Is(err, storageerror.ErrConflict) {
+			return fmt.Errorf("%s: %w", op, serviceerror.ErrConflict)
+		}
+
+		log.Error("Error set negative entry to cash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (u *UsersCashService) Del(ctx context.Context, id uuid.UUID) error {
 	const op = "service.redis.users.Del"
 	log := u.log.With(
@@ -89,6 +138,11 @@ func (u *UsersCashService) Del(ctx context.Context, id uuid.UUID) error {
 	}
 
 	if err := u.storage.Del(ctx, id); err != nil {
+		// the storage reports outages itself, once
+		if errors.Is(err, storageerror.ErrUnavailable) {
+			return fmt.Errorf("%s: %w", op, serviceerror.ErrUnavailable)
+		}
+
 		log.Error("Erro deleting user from cash", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
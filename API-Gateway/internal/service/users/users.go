@@ -2,38 +2,79 @@ package usersservice
 
 import (
 	"api-gateway/internal/domain/models"
+	"api-gateway/internal/lib/admission"
+	serviceerror "api-gateway/internal/service"
 	storageerror "api-gateway/internal/storage"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
-	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Insert(ctx context.Context, user models.User, isImport bool) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error)
 }
 
+// IUsersCache keeps users read by id. Get returns ErrCacheMiss for users
+// it does not know and ErrNotFound for the ones cached as missing.
+type IUsersCache interface {
+	Get(ctx context.Context, id uuid.UUID) (models.User, error)
+	Set(ctx context.Context, user models.User) error
+	SetNotFound(ctx context.Context, id uuid.UUID, version int64) error
+	Del(ctx context.Context, id uuid.UUID) error
+}
+
+// IAdmissionPolicy decides which users are requested often enough to be
+// cached.
+type IAdmissionPolicy interface {
+	Admit(id uuid.UUID) bool
+	Stats() admission.Stats
+}
+
+// loadTimeout bounds a load shared by concurrent misses, it does not depend
+// on the request that started it.
+const loadTimeout = 5 * time.Second
+
+// UsersService reads users through the cache and keeps it coherent:
+// writes refresh the cached user, deletes replace it with a negative entry
+// and writes with an unknown outcome evict it. Cache upkeep outlives the
+// request, a client gone after a write must not leave a stale entry.
 type UsersService struct {
-	log     *slog.Logger
-	storage IUsersStorage
+	log       *slog.Logger
+	storage   IUsersStorage
+	cache     IUsersCache
+	admission IAdmissionPolicy
+	loads     singleflight.Group
 }
 
-func New(log *slog.Logger, storage IUsersStorage) *UsersService {
+func New(log *slog.Logger, storage IUsersStorage, cache IUsersCache, admission IAdmissionPolicy) *UsersService {
 	return &UsersService{
-		log:     log,
-		storage: storage,
+		log:       log,
+		storage:   storage,
+		cache:     cache,
+		admission: admission,
 	}
 }
 
+// AdmissionStats tells how the cache admission behaves.
+func (u *UsersService) AdmissionStats() admission.Stats {
+	return u.admission.Stats()
+}
+
 // GetUsers implements IUsersStorage.
-func (u *UsersService) GetUsers(ctx context.Context) ([]models.User, error) {
+func (u *UsersService) GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error) {
 	const op = "service.users.GetUsers"
 	log := u.log.With(
 		"op", op,
@@ -41,20 +82,28 @@ func (u *UsersService) GetUsers(ctx context.Context) ([]models.User, error) {
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
 	default:
 	}
 
-	users, err := u.storage.GetUsers(ctx)
+	page, err := u.storage.GetUsers(ctx, req)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			return models.UsersPage{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+
 		log.Error("Cannot fetxh users", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return users, nil
+	return page, nil
 }
 
 // GetUserById implements IUsersStorage.
+// Concurrent misses of one id share a single request to UsersService, each
+// caller still gives up when its own ctx is done. A missing user is cached
+// as such, a found one once it is requested often.
 func (u *UsersService) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	const op = "service.users.GetUserById"
 	log := u.log.With(
@@ -67,22 +116,65 @@ func (u *UsersService) GetUserById(ctx context.Context, uid uuid.UUID) (models.U
 	default:
 	}
 
+	user, err := u.cache.Get(ctx, uid)
+	if err == nil {
+		return user, nil
+	}
+	if errors.Is(err, serviceerror.ErrNotFound) {
+		return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
+	}
+	if !errors.Is(err, serviceerror.ErrCacheMiss) && !errors.Is(err, serviceerror.ErrUnavailable) {
+		log.Warn("Cannot read cache, reading through", sl.Err(err))
+	}
+
+	ch := u.loads.DoChan(uid.String(), func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		return u.load(loadCtx, uid)
+	})
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			if errors.Is(res.Err, storageerror.ErrNotFound) {
+				log.Warn("User not found", sl.Err(res.Err))
+				return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, res.Err)
+			}
+
+			log.Error("Cannot fetch user by id", sl.Err(res.Err))
+			return models.User{}, fmt.Errorf("%s: %w", op, res.Err)
+		}
+
+		return res.Val.(models.User), nil
+	}
+}
+
+// load fetches a user missing from the cache and caches the outcome. ctx
+// belongs to no single request.
+func (u *UsersService) load(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	user, err := u.storage.GetUserById(ctx, uid)
 	if err != nil {
 		if errors.Is(err, storageerror.ErrNotFound) {
-			log.Warn("User not found", sl.Err(err))
-			return models.User{}, fmt.Errorf("%s: %w", op, err)
+			u.cache.SetNotFound(ctx, uid, 0)
 		}
 
-		log.Error("Cannot fetch user by id", sl.Err(err))
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return models.User{}, err
+	}
+
+	if u.admission.Admit(uid) {
+		u.cache.Set(ctx, user)
 	}
 
 	return user, nil
 }
 
 // Insert implements IUsersStorage.
-func (u *UsersService) Insert(ctx context.Context, userForInsert models.User) (models.User, error) {
+// isImport keeps the id and timestamps of userForInsert, only admins may
+// ask for it.
+func (u *UsersService) Insert(ctx context.Context, userForInsert models.User, isImport bool) (models.User, error) {
 	const op = "service.users.Insert"
 	log := u.log.With(
 		"op", op,
@@ -94,22 +186,31 @@ func (u *UsersService) Insert(ctx context.Context, userForInsert models.User) (m
 	default:
 	}
 
-	insertedUser, err := u.storage.Insert(ctx, userForInsert)
+	insertedUser, err := u.storage.Insert(ctx, userForInsert, isImport)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
 		if errors.Is(err, storageerror.ErrAlreadyExists) {
 			log.Warn("User already exists", sl.Err(err))
-			return models.User{}, fmt.Errorf("%s: %w", op, err)
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrAlreadyExists, err)
 		}
 
 		log.Error("Cannot insert user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	// an imported id may be cached as missing
+	u.cache.Set(context.WithoutCancel(ctx), insertedUser)
+
 	return insertedUser, nil
 }
 
 // Update implements IUsersStorage.
-func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate models.User) (models.User, error) {
+// No fields replace the whole user. expectedVersion 0 updates the user
+// whatever its version is.
+func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate models.User, fields []string, expectedVersion int64) (models.User, error) {
 	const op = "service.users.Update"
 	log := u.log.With(
 		"op", op,
@@ -121,22 +222,35 @@ func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate
 	default:
 	}
 
-	updatedUser, err := u.storage.Update(ctx, uid, userForUpdate)
+	updatedUser, err := u.storage.Update(ctx, uid, userForUpdate, fields, expectedVersion)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+		if errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User version has changed", sl.Err(err))
+			u.cache.Del(context.WithoutCancel(ctx), uid)
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrConflict, err)
+		}
 		if errors.Is(err, storageerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(err))
-			return models.User{}, fmt.Errorf("%s: %w", op, err)
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, err)
 		}
 
 		log.Error("Cannot update user", sl.Err(err))
+		u.cache.Del(context.WithoutCancel(ctx), uid)
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	u.cache.Set(context.WithoutCancel(ctx), updatedUser)
+
 	return updatedUser, nil
 }
 
 // Delete implements IUsersStorage.
-func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+// expectedVersion 0 deletes the user whatever its version is.
+func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error) {
 	const op = "service.users.Delete"
 	log := u.log.With(
 		"op", op,
@@ -148,16 +262,111 @@ func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User,
 	default:
 	}
 
-	deletedUser, err := u.storage.Delete(ctx, uid)
+	deletedUser, err := u.storage.Delete(ctx, uid, expectedVersion)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User version has changed", sl.Err(err))
+			u.cache.Del(context.WithoutCancel(ctx), uid)
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrConflict, err)
+		}
 		if errors.Is(err, storageerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(err))
-			return models.User{}, fmt.Errorf("%s: %w", op, err)
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, err)
 		}
 
 		log.Error("Cannot delete user", sl.Err(err))
+		u.cache.Del(context.WithoutCancel(ctx), uid)
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	u.cache.SetNotFound(context.WithoutCancel(ctx), uid, deletedUser.Version)
+
 	return deletedUser, nil
 }
+
+// Restore implements IUsersStorage.
+func (u *UsersService) Restore(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "service.users.Restore"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	restoredUser, err := u.storage.Restore(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("Deleted user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, err)
+		}
+
+		log.Error("Cannot restore user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.cache.Set(context.WithoutCancel(ctx), restoredUser)
+
+	return restoredUser, nil
+}
+
+// Purge implements IUsersStorage.
+func (u *UsersService) Purge(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "service.users.Purge"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	purgedUser, err := u.storage.Purge(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrNotFound, err)
+		}
+
+		log.Error("Cannot purge user", sl.Err(err))
+		u.cache.Del(context.WithoutCancel(ctx), uid)
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// purging does not bump the version, yet no read of the user is newer
+	u.cache.SetNotFound(context.WithoutCancel(ctx), uid, purgedUser.Version+1)
+
+	return purgedUser, nil
+}
+
+// GetUserHistory implements IUsersStorage.
+func (u *UsersService) GetUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error) {
+	const op = "service.users.GetUserHistory"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	page, err := u.storage.GetUserHistory(ctx, req)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			return models.HistoryPage{}, fmt.Errorf("%s: %w: %w", op, serviceerror.ErrInvalidArgument, err)
+		}
+
+		log.Error("Cannot fetch user history", sl.Err(err))
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
@@ -3,6 +3,8 @@ package grpcauthserver
 import (
 	"api-gateway/internal/domain/models"
 	asprofiles "api-gateway/internal/domain/profiles/as"
+	storageerror "api-gateway/internal/storage"
+	"api-gateway/internal/storage/grpc/grpcerror"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
 	"fmt"
@@ -11,7 +13,9 @@ import (
 	authv1 "github.com/chas3air/protos/gen/go/auth"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 type GRPCAuthServer struct {
@@ -64,13 +68,147 @@ func (u *GRPCAuthServer) Login(ctx context.Context, login string, password strin
 		},
 	)
 	if err != nil {
-		log.Error("Cannot login user", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot login user", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return res.AccessToken, res.RefreshToken, nil
 }
 
+// Refresh implements authservice.IAuthStorage.
+func (u *GRPCAuthServer) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	const op = "storage.grpc.auth.Refresh"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over", sl.Err(ctx.Err()))
+		return "", "", fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := authv1.NewAuthClient(u.conn)
+	res, err := c.Refresh(
+		ctx,
+		&authv1.RefreshRequest{
+			RefreshToken: refreshToken,
+		},
+	)
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			// a missing token, Auth does not tell it from an invalid one
+			log.Warn("Refresh token rejected", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, storageerror.ErrInvalidToken)
+		}
+
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot refresh tokens", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.GetAccessToken(), res.GetRefreshToken(), nil
+}
+
+// Logout implements authservice.IAuthStorage.
+func (u *GRPCAuthServer) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	const op = "storage.grpc.auth.Logout"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over", sl.Err(ctx.Err()))
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := authv1.NewAuthClient(u.conn)
+	_, err := c.Logout(
+		ctx,
+		&authv1.LogoutRequest{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+		},
+	)
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			// a missing token, Auth does not tell it from an invalid one
+			log.Warn("Token rejected", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, storageerror.ErrInvalidToken)
+		}
+
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot logout", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ValidateToken implements authservice.IAuthStorage.
+func (u *GRPCAuthServer) ValidateToken(ctx context.Context, accessToken string) (models.TokenIntrospection, error) {
+	const op = "storage.grpc.auth.ValidateToken"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over", sl.Err(ctx.Err()))
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := authv1.NewAuthClient(u.conn)
+	res, err := c.ValidateToken(
+		ctx,
+		&authv1.ValidateTokenRequest{
+			AccessToken: accessToken,
+		},
+	)
+	if err != nil {
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot validate token", sl.Err(err))
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return asprofiles.ProtoValidateResponseToIntrospection(res), nil
+}
+
+// GetJWKS implements authservice.IAuthStorage.
+func (u *GRPCAuthServer) GetJWKS(ctx context.Context) ([]models.JSONWebKey, error) {
+	const op = "storage.grpc.auth.GetJWKS"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over", sl.Err(ctx.Err()))
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := authv1.NewAuthClient(u.conn)
+	res, err := c.GetJWKS(ctx, &authv1.GetJWKSRequest{})
+	if err != nil {
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot get JWKS", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := make([]models.JSONWebKey, 0, len(res.GetKeys()))
+	for _, key := range res.GetKeys() {
+		keys = append(keys, asprofiles.ProtoJWKToJWK(key))
+	}
+
+	return keys, nil
+}
+
 // Register implements authservice.IAuthStorage.
 func (u *GRPCAuthServer) Register(ctx context.Context, userForRegister models.User) (models.User, error) {
 	const op = "storage.grpc.auth.Register"
@@ -90,7 +228,8 @@ func (u *GRPCAuthServer) Register(ctx context.Context, userForRegister models.Us
 			User: asprofiles.UsrToProtoUsr(userForRegister),
 		})
 	if err != nil {
-		log.Error("Cannot register user", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot register user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -118,7 +257,8 @@ func (u *GRPCAuthServer) IsAdmin(ctx context.Context, uid uuid.UUID) (bool, erro
 		},
 	)
 	if err != nil {
-		log.Error("Cannot check is an user admin", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot check is an user admin", sl.Err(err))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -0,0 +1,66 @@
+// Package grpcerror translates the errors of the gRPC services to the
+// storage errors of the gateway.
+package grpcerror
+
+import (
+	storageerror "api-gateway/internal/storage"
+	"fmt"
+	"log/slog"
+
+	"github.com/chas3air/protos/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Translate wraps err into the storage error matching its code. The error
+// of the catalogue it carries, if any, stays in the chain for the
+// handlers, see apierror.FromError. Transport failures are given one.
+func Translate(err error) error {
+	cause := err
+	apiErr, ok := apierror.FromError(err)
+	if ok {
+		cause = apiErr
+	} else {
+		// the service was not reached, there is nothing else to tell
+		switch status.Code(err) {
+		case codes.Unavailable:
+			cause = apierror.New(apierror.Unavailable, "service is unavailable")
+		case codes.DeadlineExceeded:
+			cause = apierror.New(apierror.DeadlineExceeded, "service did not answer in time")
+		}
+	}
+
+	var sentinel error
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		sentinel = storageerror.ErrInvalidArgument
+	case codes.NotFound:
+		sentinel = storageerror.ErrNotFound
+	case codes.AlreadyExists:
+		sentinel = storageerror.ErrAlreadyExists
+	case codes.Aborted:
+		sentinel = storageerror.ErrConflict
+	case codes.Unauthenticated:
+		sentinel = storageerror.ErrInvalidToken
+		if ok && apiErr.Reason == apierror.InvalidCredentials {
+			sentinel = storageerror.ErrInvalidCredentials
+		}
+	case codes.Unavailable:
+		sentinel = storageerror.ErrUnavailable
+	default:
+		return cause
+	}
+
+	return fmt.Errorf("%w: %w", sentinel, cause)
+}
+
+// LogLevel is Warn for errors caused by the request and Error for the
+// others.
+func LogLevel(err error) slog.Level {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Aborted, codes.Unauthenticated, codes.PermissionDenied:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -3,6 +3,8 @@ package grpcstorage
 import (
 	"api-gateway/internal/domain/models"
 	umprofiles "api-gateway/internal/domain/profiles/um"
+	authmiddleware "api-gateway/internal/middleware/auth"
+	"api-gateway/internal/storage/grpc/grpcerror"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
 	"fmt"
@@ -12,8 +14,13 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
+// actorMetadataKey names the acting user for the UsersService history.
+const actorMetadataKey = "x-actor-id"
+
 type GRPCUsersStorage struct {
 	log  *slog.Logger
 	conn *grpc.ClientConn
@@ -23,6 +30,7 @@ func New(log *slog.Logger, host string, port int) *GRPCUsersStorage {
 	conn, err := grpc.NewClient(
 		fmt.Sprintf("%s:%d", host, port),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(forwardActor),
 	)
 	if err != nil {
 		log.Error("failed to connect to gRPC server", sl.Err(err))
@@ -35,6 +43,16 @@ func New(log *slog.Logger, host string, port int) *GRPCUsersStorage {
 	}
 }
 
+// forwardActor passes the authenticated user on to UsersService, so its
+// history tells who made a change.
+func forwardActor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if token, ok := authmiddleware.TokenFromContext(ctx); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx, actorMetadataKey, token.UserId.String())
+	}
+
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
 func (u *GRPCUsersStorage) Close() {
 	if err := u.conn.Close(); err != nil {
 		panic(err)
@@ -42,21 +60,22 @@ func (u *GRPCUsersStorage) Close() {
 }
 
 // GetUsers implements users.IUsersStorage.
-func (s *GRPCUsersStorage) GetUsers(ctx context.Context) ([]models.User, error) {
+func (s *GRPCUsersStorage) GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error) {
 	const op = "storage.grpc.users.GetUsers"
 	log := s.log.With(slog.String("op", op))
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
 	default:
 	}
 
 	c := umv1.NewUsersManagerClient(s.conn)
-	res, err := c.GetUsers(ctx, nil)
+	res, err := c.GetUsers(ctx, umprofiles.PageReqToProtoReq(req))
 	if err != nil {
-		log.Error("failed to get users", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot fetch users", sl.Err(err))
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	var resUsers = make([]models.User, 0, len(res.GetUsers()))
@@ -69,7 +88,10 @@ func (s *GRPCUsersStorage) GetUsers(ctx context.Context) ([]models.User, error)
 		resUsers = append(resUsers, user)
 	}
 
-	return resUsers, nil
+	return models.UsersPage{
+		Users:      resUsers,
+		NextCursor: res.GetNextCursor(),
+	}, nil
 }
 
 // GetUserById implements users.IUsersStorage.
@@ -88,7 +110,8 @@ func (s *GRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 		Id: uid.String(),
 	})
 	if err != nil {
-		log.Error("Cannot fetxh user by id", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot fetch user by id", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -102,7 +125,9 @@ func (s *GRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 }
 
 // Insert implements users.IUsersStorage.
-func (s *GRPCUsersStorage) Insert(ctx context.Context, user models.User) (models.User, error) {
+// isImport asks UsersService to keep the id and timestamps of user. It
+// trusts the flag as is, so only the admin-gated InsertHandler may set it.
+func (s *GRPCUsersStorage) Insert(ctx context.Context, user models.User, isImport bool) (models.User, error) {
 	const op = "storage.grpc.users.Insert"
 	log := s.log.With(slog.String("op", op))
 
@@ -114,10 +139,12 @@ func (s *GRPCUsersStorage) Insert(ctx context.Context, user models.User) (models
 
 	c := umv1.NewUsersManagerClient(s.conn)
 	res, err := c.Insert(ctx, &umv1.InsertRequest{
-		User: umprofiles.UsrToProtoUsr(user),
+		User:     umprofiles.UsrToProtoUsr(user),
+		IsImport: isImport,
 	})
 	if err != nil {
-		log.Error("Error inserting user", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot insert user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -131,7 +158,10 @@ func (s *GRPCUsersStorage) Insert(ctx context.Context, user models.User) (models
 }
 
 // Update implements users.IUsersStorage.
-func (s *GRPCUsersStorage) Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error) {
+// Only the given fields are changed, no fields replace the whole user. A
+// non-zero expectedVersion makes UsersService reject the update with
+// ErrConflict once the user has changed.
+func (s *GRPCUsersStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error) {
 	const op = "storage.grpc.users.Update"
 	log := s.log.With(slog.String("op", op))
 
@@ -141,13 +171,20 @@ func (s *GRPCUsersStorage) Update(ctx context.Context, uid uuid.UUID, user model
 	default:
 	}
 
+	req := &umv1.UpdateRequest{
+		Id:              uid.String(),
+		User:            umprofiles.UsrToProtoUsr(user),
+		ExpectedVersion: expectedVersion,
+	}
+	if len(fields) > 0 {
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: fields}
+	}
+
 	c := umv1.NewUsersManagerClient(s.conn)
-	res, err := c.Update(ctx, &umv1.UpdateRequest{
-		Id:   uid.String(),
-		User: umprofiles.UsrToProtoUsr(user),
-	})
+	res, err := c.Update(ctx, req)
 	if err != nil {
-		log.Error("Error updating user", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot update user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -161,7 +198,8 @@ func (s *GRPCUsersStorage) Update(ctx context.Context, uid uuid.UUID, user model
 }
 
 // Delete implements users.IUsersStorage.
-func (s *GRPCUsersStorage) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+// A non-zero expectedVersion works as in Update.
+func (s *GRPCUsersStorage) Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error) {
 	const op = "storage.grpc.users.Delete"
 	log := s.log.With(slog.String("op", op))
 
@@ -173,10 +211,12 @@ func (s *GRPCUsersStorage) Delete(ctx context.Context, uid uuid.UUID) (models.Us
 
 	c := umv1.NewUsersManagerClient(s.conn)
 	res, err := c.Delete(ctx, &umv1.DeleteRequest{
-		Id: uid.String(),
+		Id:              uid.String(),
+		ExpectedVersion: expectedVersion,
 	})
 	if err != nil {
-		log.Error("Error deleting user", sl.Err(err))
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot delete user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -188,3 +228,102 @@ func (s *GRPCUsersStorage) Delete(ctx context.Context, uid uuid.UUID) (models.Us
 
 	return deletedUser, nil
 }
+
+// Restore implements users.IUsersStorage.
+func (s *GRPCUsersStorage) Restore(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "storage.grpc.users.Restore"
+	log := s.log.With(slog.String("op", op))
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := umv1.NewUsersManagerClient(s.conn)
+	res, err := c.Restore(ctx, &umv1.RestoreRequest{
+		Id: uid.String(),
+	})
+	if err != nil {
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot restore user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	restoredUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
+	if err != nil {
+		log.Error("Wrong user format", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return restoredUser, nil
+}
+
+// Purge implements users.IUsersStorage.
+func (s *GRPCUsersStorage) Purge(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "storage.grpc.users.Purge"
+	log := s.log.With(slog.String("op", op))
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := umv1.NewUsersManagerClient(s.conn)
+	res, err := c.Purge(ctx, &umv1.PurgeRequest{
+		Id: uid.String(),
+	})
+	if err != nil {
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot purge user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purgedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
+	if err != nil {
+		log.Error("Wrong user format", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purgedUser, nil
+}
+
+// GetUserHistory implements users.IUsersStorage.
+func (s *GRPCUsersStorage) GetUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error) {
+	const op = "storage.grpc.users.GetUserHistory"
+	log := s.log.With(slog.String("op", op))
+
+	select {
+	case <-ctx.Done():
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	c := umv1.NewUsersManagerClient(s.conn)
+	res, err := c.ListUserHistory(ctx, &umv1.ListUserHistoryRequest{
+		Id:       req.UserId.String(),
+		PageSize: int32(req.PageSize),
+		Cursor:   req.Cursor,
+	})
+	if err != nil {
+		err = grpcerror.Translate(err)
+		log.Log(ctx, grpcerror.LogLevel(err), "Cannot fetch user history", sl.Err(err))
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries := make([]models.HistoryEntry, 0, len(res.GetEntries()))
+	for _, pbEntry := range res.GetEntries() {
+		entry, err := umprofiles.ProtoHistoryEntryToHistoryEntry(pbEntry)
+		if err != nil {
+			log.Warn("failed to convert proto history entry", sl.Err(err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return models.HistoryPage{
+		Entries:    entries,
+		NextCursor: res.GetNextCursor(),
+	}, nil
+}
@@ -0,0 +1,187 @@
+package userslrustorage
+
+import (
+	"api-gateway/internal/domain/models"
+	storageerror "api-gateway/internal/storage"
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultSize = 10000
+
+type entry struct {
+	id        uuid.UUID
+	user      models.User
+	notFound  bool
+	version   int64
+	expiresAt time.Time
+}
+
+// UsersLRUStorage caches users in process memory. It holds at most size
+// entries and drops the least recently used one to make room, entries
+// expire like the Redis ones. Versions are compared as in the Redis
+// storage, an entry is never replaced by an older one and such writes
+// return ErrConflict.
+type UsersLRUStorage struct {
+	log                    *slog.Logger
+	size                   int
+	expirationTime         time.Duration
+	notFoundExpirationTime time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[uuid.UUID]*list.Element
+}
+
+// New creates the cache, expiration times are in seconds and size 0 holds
+// 10000 entries.
+func New(log *slog.Logger, size int, expirationTime int, notFoundExpirationTime int) *UsersLRUStorage {
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	return &UsersLRUStorage{
+		log:                    log,
+		size:                   size,
+		expirationTime:         time.Duration(expirationTime) * time.Second,
+		notFoundExpirationTime: time.Duration(notFoundExpirationTime) * time.Second,
+		order:                  list.New(),
+		entries:                make(map[uuid.UUID]*list.Element, size),
+	}
+}
+
+func (u *UsersLRUStorage) Close() {}
+
+// Get implements userscashservice.UsersCashStorage.
+// It returns ErrCacheMiss for an unknown or expired id and ErrNotFound for
+// a negative entry.
+func (u *UsersLRUStorage) Get(ctx context.Context, id uuid.UUID) (models.User, error) {
+	const op = "storage.lru.users.Get"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	elem, ok := u.entries[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrCacheMiss)
+	}
+
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		u.remove(elem)
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrCacheMiss)
+	}
+
+	u.order.MoveToFront(elem)
+	if e.notFound {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	return e.user, nil
+}
+
+// Set implements userscashservice.UsersCashStorage.
+func (u *UsersLRUStorage) Set(ctx context.Context, user models.User) error {
+	const op = "storage.lru.users.Set"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if !u.put(&entry{
+		id:        user.Id,
+		user:      user,
+		version:   user.Version,
+		expiresAt: time.Now().Add(u.expirationTime),
+	}) {
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	return nil
+}
+
+// SetNotFound implements userscashservice.UsersCashStorage.
+func (u *UsersLRUStorage) SetNotFound(ctx context.Context, id uuid.UUID, version int64) error {
+	const op = "storage.lru.users.SetNotFound"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if !u.put(&entry{
+		id:        id,
+		notFound:  true,
+		version:   version,
+		expiresAt: time.Now().Add(u.notFoundExpirationTime),
+	}) {
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	return nil
+}
+
+// Del implements userscashservice.UsersCashStorage.
+func (u *UsersLRUStorage) Del(ctx context.Context, id uuid.UUID) error {
+	const op = "storage.lru.users.Del"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if elem, ok := u.entries[id]; ok {
+		u.remove(elem)
+	}
+
+	return nil
+}
+
+// put stores e unless a live entry with a newer version is cached, and
+// tells whether it did.
+func (u *UsersLRUStorage) put(e *entry) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if elem, ok := u.entries[e.id]; ok {
+		current := elem.Value.(*entry)
+		if current.version > e.version && time.Now().Before(current.expiresAt) {
+			return false
+		}
+
+		elem.Value = e
+		u.order.MoveToFront(elem)
+		return true
+	}
+
+	u.entries[e.id] = u.order.PushFront(e)
+	for u.order.Len() > u.size {
+		u.remove(u.order.Back())
+	}
+
+	return true
+}
+
+func (u *UsersLRUStorage) remove(elem *list.Element) {
+	u.order.Remove(elem)
+	delete(u.entries, elem.Value.(*entry).id)
+}
@@ -0,0 +1,214 @@
+package userslrustorage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"api-gateway/internal/domain/models"
+	storageerror "api-gateway/internal/storage"
+	userslrustorage "api-gateway/internal/storage/lru/users"
+
+	"github.com/google/uuid"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func user(version int64) models.User {
+	return models.User{Id: uuid.New(), Login: "user1", Version: version}
+}
+
+func TestGet_Miss(t *testing.T) {
+	cache := userslrustorage.New(discard, 2, 60, 60)
+
+	if _, err := cache.Get(context.Background(), uuid.New()); !errors.Is(err, storageerror.ErrCacheMiss) {
+		t.Errorf("expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestSet_Get(t *testing.T) {
+	ctx := context.Background()
+	cache := userslrustorage.New(discard, 2, 60, 60)
+	u := user(1)
+
+	if err := cache.Set(ctx, u); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := cache.Get(ctx, u.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != u {
+		t.Errorf("expected %+v, got %+v", u, got)
+	}
+}
+
+func TestSetNotFound(t *testing.T) {
+	ctx := context.Background()
+	cache := userslrustorage.New(discard, 2, 60, 60)
+	id := uuid.New()
+
+	if err := cache.SetNotFound(ctx, id, 3); err != nil {
+		t.Fatalf("SetNotFound: %v", err)
+	}
+
+	if _, err := cache.Get(ctx, id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := userslrustorage.New(discard, 2, 60, 60)
+	first, second, third := user(1), user(1), user(1)
+
+	for _, u := range []models.User{first, second} {
+		if err := cache.Set(ctx, u); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	// reading first makes second the least recently used
+	if _, err := cache.Get(ctx, first.Id); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := cache.Set(ctx, third); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   uuid.UUID
+		want error
+	}{
+		{"recently read", first.Id, nil},
+		{"least recently used", second.Id, storageerror.ErrCacheMiss},
+		{"just added", third.Id, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cache.Get(ctx, tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	// zero expiration times make every entry stale at once
+	cache := userslrustorage.New(discard, 2, 0, 0)
+	u := user(2)
+
+	if err := cache.Set(ctx, u); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := cache.Get(ctx, u.Id); !errors.Is(err, storageerror.ErrCacheMiss) {
+		t.Errorf("expected ErrCacheMiss, got %v", err)
+	}
+
+	// a stale entry does not hold back older versions
+	if err := cache.Set(ctx, u); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.SetNotFound(ctx, u.Id, 1); err != nil {
+		t.Errorf("expected stale entry to be replaced, got %v", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		cached  int64
+		written int64
+		want    error
+	}{
+		{"newer", 2, 3, nil},
+		{"same", 2, 2, nil},
+		{"older", 2, 1, storageerror.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := userslrustorage.New(discard, 2, 60, 60)
+			cached := user(tt.cached)
+			if err := cache.Set(ctx, cached); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			written := cached
+			written.Version = tt.written
+			if err := cache.Set(ctx, written); !errors.Is(err, tt.want) {
+				t.Errorf("Set: expected %v, got %v", tt.want, err)
+			}
+			if err := cache.SetNotFound(ctx, cached.Id, tt.written); !errors.Is(err, tt.want) {
+				t.Errorf("SetNotFound: expected %v, got %v", tt.want, err)
+			}
+
+			got, err := cache.Get(ctx, cached.Id)
+			if tt.want != nil && (err != nil || got.Version != tt.cached) {
+				t.Errorf("expected version %d kept, got %+v, %v", tt.cached, got, err)
+			}
+			if tt.want == nil && !errors.Is(err, storageerror.ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDel(t *testing.T) {
+	ctx := context.Background()
+	cache := userslrustorage.New(discard, 2, 60, 60)
+	u := user(5)
+
+	if err := cache.Set(ctx, u); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.Del(ctx, u.Id); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	if _, err := cache.Get(ctx, u.Id); !errors.Is(err, storageerror.ErrCacheMiss) {
+		t.Errorf("expected ErrCacheMiss, got %v", err)
+	}
+
+	// the deleted version no longer holds back older ones
+	u.Version = 1
+	if err := cache.Set(ctx, u); err != nil {
+		t.Errorf("expected Set after Del to succeed, got %v", err)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	ctx := context.Background()
+	cache := userslrustorage.New(discard, 8, 60, 60)
+	ids := make([]uuid.UUID, 16)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j, id := range ids {
+				switch (i + j) % 4 {
+				case 0:
+					cache.Set(ctx, models.User{Id: id, Version: int64(j)})
+				case 1:
+					cache.SetNotFound(ctx, id, int64(j))
+				case 2:
+					cache.Del(ctx, id)
+				default:
+					cache.Get(ctx, id)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
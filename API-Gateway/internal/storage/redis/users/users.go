@@ -2,45 +2,157 @@ package userscashstorage
 
 import (
 	"api-gateway/internal/domain/models"
+	"api-gateway/internal/lib/health"
+	storageerror "api-gateway/internal/storage"
 	"api-gateway/pkg/lib/logger/sl"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// notFoundField marks a negative entry, a user known not to exist.
+const notFoundField = "not_found"
+
+// setScript replaces the entry unless the cached one has a newer version,
+// so a slow read can not overwrite what a later write has put there. It
+// returns 0 when it keeps the cached entry.
+// KEYS[1] is the entry, ARGV[1] the version, ARGV[2] the TTL in
+// milliseconds, the rest are field/value pairs.
+var setScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'version')
+if current and tonumber(current) > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'version', ARGV[1], unpack(ARGV, 3))
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// UsersCashStorage keeps working while Redis is down: every call fails
+// fast with ErrUnavailable until the health monitor reaches Redis again.
+// Writes missed meanwhile would leave stale users behind, so the cached
+// users are dropped before Redis is used again.
 type UsersCashStorage struct {
-	log            *slog.Logger
-	rds            *redis.Client
-	expirationTime int
+	log                    *slog.Logger
+	rds                    *redis.Client
+	health                 *health.Monitor
+	missedWrites           atomic.Bool
+	expirationTime         time.Duration
+	notFoundExpirationTime time.Duration
 }
 
-func New(log *slog.Logger, host string, port int, expirationTime int) *UsersCashStorage {
+// New connects to Redis, an unreachable Redis is retried in the
+// background. Entries live expirationTime seconds, negative ones
+// notFoundExpirationTime seconds.
+func New(log *slog.Logger, host string, port int, expirationTime int, notFoundExpirationTime int) *UsersCashStorage {
+	// a cache answers fast or not at all, a miss costs less than waiting
 	rds := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", host, port),
-		Password: "",
-		DB:       0,
+		Addr:         fmt.Sprintf("%s:%d", host, port),
+		Password:     "",
+		DB:           0,
+		DialTimeout:  time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
+		MaxRetries:   -1,
 	})
 
-	if err := rds.Ping(context.TODO()).Err(); err != nil {
-		panic(err)
+	u := &UsersCashStorage{
+		log:                    log,
+		rds:                    rds,
+		expirationTime:         time.Duration(expirationTime) * time.Second,
+		notFoundExpirationTime: time.Duration(notFoundExpirationTime) * time.Second,
+	}
+	u.health = health.NewMonitor(log, "users cache", u.probe)
+
+	return u
+}
+
+// probe tells whether Redis can be used again.
+func (u *UsersCashStorage) probe(ctx context.Context) error {
+	if err := u.rds.Ping(ctx).Err(); err != nil {
+		return err
 	}
 
-	return &UsersCashStorage{
-		log:            log,
-		rds:            rds,
-		expirationTime: expirationTime,
+	if u.missedWrites.Load() {
+		if err := u.flush(ctx); err != nil {
+			return err
+		}
+		u.missedWrites.Store(false)
+	}
+
+	return nil
+}
+
+// flush drops every cached user.
+func (u *UsersCashStorage) flush(ctx context.Context) error {
+	iter := u.rds.Scan(ctx, 0, keyPrefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		if err := u.rds.Unlink(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
 	}
+
+	return iter.Err()
 }
 
 func (u *UsersCashStorage) Close() {
+	u.health.Close()
 	u.rds.Close()
 }
 
+// Health reports whether Redis is reachable.
+func (u *UsersCashStorage) Health() health.Status {
+	return u.health.Status()
+}
+
+// check fails fast while Redis is known to be down.
+func (u *UsersCashStorage) check() error {
+	if !u.health.Healthy() {
+		return storageerror.ErrUnavailable
+	}
+
+	return nil
+}
+
+// checkWrite is check for writes, which are remembered when they fail.
+func (u *UsersCashStorage) checkWrite() error {
+	if err := u.check(); err != nil {
+		u.missedWrites.Store(true)
+		return err
+	}
+
+	return nil
+}
+
+// observe reports a failed call to the health monitor.
+func (u *UsersCashStorage) observe(err error) error {
+	if err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled) {
+		u.health.Fail(err)
+	}
+
+	return err
+}
+
+// observeWrite is observe for writes, which are remembered when they fail.
+func (u *UsersCashStorage) observeWrite(err error) error {
+	if err != nil {
+		u.missedWrites.Store(true)
+	}
+
+	return u.observe(err)
+}
+
 // Get implements userscashservice.UsersCashStorage.
+// It returns ErrCacheMiss for an unknown id and ErrNotFound for a negative
+// entry.
 func (u *UsersCashStorage) Get(ctx context.Context, id uuid.UUID) (models.User, error) {
 	const op = "storage.redis.users.Get"
 	log := u.log.With(
@@ -53,15 +165,23 @@ func (u *UsersCashStorage) Get(ctx context.Context, id uuid.UUID) (models.User,
 	default:
 	}
 
-	userFromRedis, err := u.rds.HGetAll(
-		ctx,
-		fmt.Sprintf("user:%s", id.String()),
-	).Result()
-	if err != nil {
-		log.Info("User not found in redis", sl.Err(err))
+	if err := u.check(); err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	userFromRedis, err := u.rds.HGetAll(ctx, key(id)).Result()
+	if err := u.observe(err); err != nil {
+		log.Warn("Cannot read user from redis", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(userFromRedis) == 0 {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrCacheMiss)
+	}
+	if userFromRedis[notFoundField] != "" {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
 	userForReturn := mapToUser(userFromRedis)
 	return userForReturn, nil
 }
@@ -79,28 +199,60 @@ func (u *UsersCashStorage) Set(ctx context.Context, user models.User) error {
 	default:
 	}
 
-	_, err := u.rds.HSet(
-		ctx,
-		fmt.Sprintf("user:%s", user.Id.String()),
-		map[string]string{
-			"id":       user.Id.String(),
-			"login":    user.Login,
-			"password": string(user.Password),
-			"role":     user.Role,
-		},
-	).Result()
+	if err := u.checkWrite(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err := u.set(ctx, user.Id, user.Version, u.expirationTime,
+		"id", user.Id.String(),
+		"login", user.Login,
+		"role", user.Role,
+		"email", user.Email,
+		"display_name", user.DisplayName,
+		"locale", user.Locale,
+		"timezone", user.Timezone,
+		"created_at", formatTime(user.CreatedAt),
+		"updated_at", formatTime(user.UpdatedAt),
+		"last_login_at", formatTime(user.LastLoginAt),
+	)
 	if err != nil {
+		if errors.Is(err, storageerror.ErrConflict) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
 		log.Warn("Cannot insert user to redis", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = u.rds.Expire(
-		ctx,
-		fmt.Sprintf("user:%s", user.Id.String()),
-		time.Duration(u.expirationTime),
-	).Result()
-	if err != nil {
-		log.Warn("Cannot set expiration time for user"+user.Id.String(), sl.Err(err))
+	return nil
+}
+
+// SetNotFound implements userscashservice.UsersCashStorage.
+// version is that of the write that removed the user, 0 when it was never
+// seen.
+func (u *UsersCashStorage) SetNotFound(ctx context.Context, id uuid.UUID, version int64) error {
+	const op = "storage.redis.users.SetNotFound"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	if err := u.checkWrite(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.set(ctx, id, version, u.notFoundExpirationTime, notFoundField, "1"); err != nil {
+		if errors.Is(err, storageerror.ErrConflict) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Warn("Cannot insert negative entry to redis", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
@@ -119,24 +271,71 @@ func (u *UsersCashStorage) Del(ctx context.Context, id uuid.UUID) error {
 	default:
 	}
 
-	_, err := u.rds.HDel(
-		ctx,
-		fmt.Sprintf("user:%s", id.String()),
-	).Result()
-	if err != nil {
+	if err := u.checkWrite(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.observeWrite(u.rds.Del(ctx, key(id)).Err()); err != nil {
 		log.Warn("Cannot delete user:"+id.String()+" from cash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
+}
+
+// set returns ErrConflict when a newer entry is cached already.
+func (u *UsersCashStorage) set(ctx context.Context, id uuid.UUID, version int64, ttl time.Duration, fields ...string) error {
+	args := make([]any, 0, len(fields)+2)
+	args = append(args, version, ttl.Milliseconds())
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	replaced, err := setScript.Run(ctx, u.rds, []string{key(id)}, args...).Int()
+	if err := u.observeWrite(err); err != nil {
+		return err
+	}
+	if replaced == 0 {
+		return storageerror.ErrConflict
+	}
 
+	return nil
+}
+
+const keyPrefix = "user:"
+
+func key(id uuid.UUID) string {
+	return keyPrefix + id.String()
 }
 
 func mapToUser(mappedUser map[string]string) models.User {
 	id, _ := uuid.Parse(mappedUser["id"])
+	version, _ := strconv.ParseInt(mappedUser["version"], 10, 64)
 	return models.User{
-		Id:       id,
-		Login:    mappedUser["login"],
-		Password: mappedUser["password"],
-		Role:     mappedUser["role"],
+		Id:          id,
+		Login:       mappedUser["login"],
+		Role:        mappedUser["role"],
+		Email:       mappedUser["email"],
+		DisplayName: mappedUser["display_name"],
+		Locale:      mappedUser["locale"],
+		Timezone:    mappedUser["timezone"],
+		CreatedAt:   parseTime(mappedUser["created_at"]),
+		UpdatedAt:   parseTime(mappedUser["updated_at"]),
+		LastLoginAt: parseTime(mappedUser["last_login_at"]),
+		Version:     version,
+	}
+}
+
+// formatTime stores a zero time as an empty field.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
 	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
 }
@@ -3,6 +3,12 @@ package storageerror
 import "errors"
 
 var (
-	ErrNotFound      = errors.New("resource not found")
-	ErrAlreadyExists = errors.New("resource already exists")
+	ErrNotFound           = errors.New("resource not found")
+	ErrAlreadyExists      = errors.New("resource already exists")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrConflict           = errors.New("resource version conflict")
+	ErrCacheMiss          = errors.New("not cached")
+	ErrUnavailable        = errors.New("dependency unavailable")
 )
@@ -0,0 +1,134 @@
+package userstieredstorage
+
+import (
+	"api-gateway/internal/domain/models"
+	storageerror "api-gateway/internal/storage"
+	"api-gateway/pkg/lib/logger/sl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// ICacheTier is one level of the cache, with the contract of
+// userscashservice.UsersCashStorage.
+type ICacheTier interface {
+	Get(ctx context.Context, id uuid.UUID) (models.User, error)
+	Set(ctx context.Context, user models.User) error
+	SetNotFound(ctx context.Context, id uuid.UUID, version int64) error
+	Del(ctx context.Context, id uuid.UUID) error
+}
+
+// UsersTieredStorage puts a small local cache (L1) in front of a shared
+// one (L2). Writes go to both, L2 first: an entry L2 refused for a newer
+// version is dropped from L1, one L2 failed to store is kept in L1 alone.
+// Other gateway instances only reach a local tier through L2, so L1
+// entries should expire quickly.
+type UsersTieredStorage struct {
+	log    *slog.Logger
+	local  ICacheTier
+	shared ICacheTier
+}
+
+func New(log *slog.Logger, local ICacheTier, shared ICacheTier) *UsersTieredStorage {
+	return &UsersTieredStorage{
+		log:    log,
+		local:  local,
+		shared: shared,
+	}
+}
+
+// Get implements userscashservice.UsersCashStorage.
+// An L1 miss is looked up in L2 and copied into L1.
+func (u *UsersTieredStorage) Get(ctx context.Context, id uuid.UUID) (models.User, error) {
+	const op = "storage.tiered.users.Get"
+	log := u.log.With(
+		"op", op,
+	)
+
+	user, err := u.local.Get(ctx, id)
+	if !errors.Is(err, storageerror.ErrCacheMiss) {
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return user, nil
+	}
+
+	user, err = u.shared.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			// the version of the shared entry is unknown here, any later
+			// write replaces this one
+			if err := u.local.SetNotFound(ctx, id, 0); err != nil {
+				log.Warn("Cannot copy negative entry to local cache", sl.Err(err))
+			}
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.local.Set(ctx, user); err != nil {
+		log.Warn("Cannot copy user to local cache", sl.Err(err))
+	}
+
+	return user, nil
+}
+
+// Set implements userscashservice.UsersCashStorage.
+func (u *UsersTieredStorage) Set(ctx context.Context, user models.User) error {
+	const op = "storage.tiered.users.Set"
+
+	sharedErr := u.shared.Set(ctx, user)
+	if errors.Is(sharedErr, storageerror.ErrConflict) {
+		// L2 holds a newer version, L1 must not serve an older one
+		u.local.Del(ctx, user.Id)
+		return fmt.Errorf("%s: %w", op, sharedErr)
+	}
+
+	if err := u.local.Set(ctx, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if sharedErr != nil {
+		return fmt.Errorf("%s: %w", op, sharedErr)
+	}
+
+	return nil
+}
+
+// SetNotFound implements userscashservice.UsersCashStorage.
+func (u *UsersTieredStorage) SetNotFound(ctx context.Context, id uuid.UUID, version int64) error {
+	const op = "storage.tiered.users.SetNotFound"
+
+	sharedErr := u.shared.SetNotFound(ctx, id, version)
+	if errors.Is(sharedErr, storageerror.ErrConflict) {
+		u.local.Del(ctx, id)
+		return fmt.Errorf("%s: %w", op, sharedErr)
+	}
+
+	if err := u.local.SetNotFound(ctx, id, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if sharedErr != nil {
+		return fmt.Errorf("%s: %w", op, sharedErr)
+	}
+
+	return nil
+}
+
+// Del implements userscashservice.UsersCashStorage.
+func (u *UsersTieredStorage) Del(ctx context.Context, id uuid.UUID) error {
+	const op = "storage.tiered.users.Del"
+
+	localErr := u.local.Del(ctx, id)
+	if err := u.shared.Del(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if localErr != nil {
+		return fmt.Errorf("%s: %w", op, localErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package userstieredstorage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"api-gateway/internal/domain/models"
+	storageerror "api-gateway/internal/storage"
+	userslrustorage "api-gateway/internal/storage/lru/users"
+	userstieredstorage "api-gateway/internal/storage/tiered/users"
+
+	"github.com/google/uuid"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeTier is an LRU cache whose writes fail with err when it is set.
+type fakeTier struct {
+	*userslrustorage.UsersLRUStorage
+	err error
+}
+
+func newFakeTier() *fakeTier {
+	return &fakeTier{UsersLRUStorage: userslrustorage.New(discard, 16, 60, 60)}
+}
+
+func (f *fakeTier) Set(ctx context.Context, user models.User) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	return f.UsersLRUStorage.Set(ctx, user)
+}
+
+func (f *fakeTier) SetNotFound(ctx context.Context, id uuid.UUID, version int64) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	return f.UsersLRUStorage.SetNotFound(ctx, id, version)
+}
+
+func newTiered() (*userstieredstorage.UsersTieredStorage, *userslrustorage.UsersLRUStorage, *fakeTier) {
+	local := userslrustorage.New(discard, 16, 60, 60)
+	shared := newFakeTier()
+
+	return userstieredstorage.New(discard, local, shared), local, shared
+}
+
+func TestGet_CopiesToLocal(t *testing.T) {
+	ctx := context.Background()
+	tiered, local, shared := newTiered()
+	user := models.User{Id: uuid.New(), Login: "user1", Version: 2}
+	if err := shared.Set(ctx, user); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := tiered.Get(ctx, user.Id)
+	if err != nil || got != user {
+		t.Fatalf("expected %+v, got %+v, %v", user, got, err)
+	}
+	if got, err := local.Get(ctx, user.Id); err != nil || got != user {
+		t.Errorf("expected L1 to hold %+v, got %+v, %v", user, got, err)
+	}
+}
+
+func TestGet_CopiesNotFoundToLocal(t *testing.T) {
+	ctx := context.Background()
+	tiered, local, shared := newTiered()
+	id := uuid.New()
+	if err := shared.SetNotFound(ctx, id, 1); err != nil {
+		t.Fatalf("SetNotFound: %v", err)
+	}
+
+	if _, err := tiered.Get(ctx, id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := local.Get(ctx, id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected negative L1 entry, got %v", err)
+	}
+}
+
+func TestGet_Miss(t *testing.T) {
+	tiered, _, _ := newTiered()
+
+	if _, err := tiered.Get(context.Background(), uuid.New()); !errors.Is(err, storageerror.ErrCacheMiss) {
+		t.Errorf("expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		sharedErr error
+		wantErr   error
+		wantLocal error
+	}{
+		{"written to both", nil, nil, nil},
+		{"shared unavailable keeps local", storageerror.ErrUnavailable, storageerror.ErrUnavailable, nil},
+		{"shared conflict drops local", storageerror.ErrConflict, storageerror.ErrConflict, storageerror.ErrCacheMiss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tiered, local, shared := newTiered()
+			user := models.User{Id: uuid.New(), Login: "user1", Version: 1}
+			// an entry the write has to replace or drop
+			if err := local.SetNotFound(ctx, user.Id, 0); err != nil {
+				t.Fatalf("SetNotFound: %v", err)
+			}
+			shared.err = tt.sharedErr
+
+			if err := tiered.Set(ctx, user); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if _, err := local.Get(ctx, user.Id); !errors.Is(err, tt.wantLocal) {
+				t.Errorf("expected L1 %v, got %v", tt.wantLocal, err)
+			}
+			if tt.sharedErr == nil {
+				if got, err := shared.Get(ctx, user.Id); err != nil || got != user {
+					t.Errorf("expected L2 to hold %+v, got %+v, %v", user, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetNotFound(t *testing.T) {
+	tests := []struct {
+		name      string
+		sharedErr error
+		wantErr   error
+		wantLocal error
+	}{
+		{"written to both", nil, nil, storageerror.ErrNotFound},
+		{"shared unavailable keeps local", storageerror.ErrUnavailable, storageerror.ErrUnavailable, storageerror.ErrNotFound},
+		{"shared conflict drops local", storageerror.ErrConflict, storageerror.ErrConflict, storageerror.ErrCacheMiss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tiered, local, shared := newTiered()
+			user := models.User{Id: uuid.New(), Login: "user1", Version: 1}
+			if err := local.Set(ctx, user); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			shared.err = tt.sharedErr
+
+			if err := tiered.SetNotFound(ctx, user.Id, 2); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if _, err := local.Get(ctx, user.Id); !errors.Is(err, tt.wantLocal) {
+				t.Errorf("expected L1 %v, got %v", tt.wantLocal, err)
+			}
+			if tt.sharedErr == nil {
+				if _, err := shared.Get(ctx, user.Id); !errors.Is(err, storageerror.ErrNotFound) {
+					t.Errorf("expected negative L2 entry, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDel(t *testing.T) {
+	ctx := context.Background()
+	tiered, local, shared := newTiered()
+	user := models.User{Id: uuid.New(), Login: "user1", Version: 1}
+	if err := tiered.Set(ctx, user); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if err := tiered.Del(ctx, user.Id); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	for name, tier := range map[string]userstieredstorage.ICacheTier{"L1": local, "L2": shared} {
+		if _, err := tier.Get(ctx, user.Id); !errors.Is(err, storageerror.ErrCacheMiss) {
+			t.Errorf("expected %s miss, got %v", name, err)
+		}
+	}
+}
@@ -24,7 +24,44 @@ type Config struct {
 	RedisHost string `yaml:"redis_host" env:"REDIS_HOST" env-default:"redis"`
 	RedisPort int    `yaml:"redis_port" env:"REDIS_PORT" env-default:"6379"`
 
-	MaxRequestsPerUser int `yaml:"max_requests_per_user" env:"MAX_REQUESTS_PER_USER" env-default:"100"`
+	// seconds a user cached as missing is kept, ExpirationTime is the one
+	// of cached users
+	NotFoundExpirationTime int `yaml:"not_found_expiration_time" env:"NOT_FOUND_EXPIRATION_TIME" env-default:"2"`
+
+	// CacheBackend is one of CacheBackendRedis, CacheBackendMemory and
+	// CacheBackendTiered. The local cache holds LocalCacheSize users for
+	// LocalExpirationTime seconds (0: ExpirationTime), keep it short when
+	// it sits in front of Redis and several gateways run.
+	CacheBackend        string `yaml:"cache_backend" env:"CACHE_BACKEND" env-default:"redis"`
+	LocalCacheSize      int    `yaml:"local_cache_size" env:"LOCAL_CACHE_SIZE" env-default:"10000"`
+	LocalExpirationTime int    `yaml:"local_expiration_time" env:"LOCAL_EXPIRATION_TIME" env-default:"0"`
+
+	// a user is cached once requested MaxRequestsPerUser times within the
+	// last AdmissionResetAfter requests (0: 10 * AdmissionWidth), counted
+	// in a sketch of AdmissionWidth counters per row (0: 16384)
+	MaxRequestsPerUser  int `yaml:"max_requests_per_user" env:"MAX_REQUESTS_PER_USER" env-default:"100"`
+	AdmissionWidth      int `yaml:"admission_width" env:"ADMISSION_WIDTH" env-default:"0"`
+	AdmissionResetAfter int `yaml:"admission_reset_after" env:"ADMISSION_RESET_AFTER" env-default:"0"`
+
+	// must match the Auth service JWT_ISSUER and JWT_AUDIENCE
+	JWTIssuer   string `yaml:"jwt_issuer" env:"JWT_ISSUER" env-default:"auth"`
+	JWTAudience string `yaml:"jwt_audience" env:"JWT_AUDIENCE" env-default:"users-connector"`
+
+	// seconds a revoked access token is denied without asking the Auth
+	// service again, active tokens are checked with it on every request
+	TokenStatusCacheTime int `yaml:"token_status_cache_time" env:"TOKEN_STATUS_CACHE_TIME" env-default:"5"`
+
+	// HS256 secret shared with the Auth service, leave empty when it signs
+	// with an asymmetric key published in the JWKS. It is the one of kid
+	// AccessTokenSecretId, more secrets are read from <kid>.secret files in
+	// AccessTokenSecretsDir to verify tokens signed before a rotation.
+	AccessTokenSecret     string `yaml:"access_token_secret" env:"ACCESS_TOKEN_SECRET" json:"-"`
+	AccessTokenSecretId   string `yaml:"access_token_secret_id" env:"ACCESS_TOKEN_SECRET_ID" env-default:"default"`
+	AccessTokenSecretsDir string `yaml:"access_token_secrets_dir" env:"ACCESS_TOKEN_SECRETS_DIR"`
+
+	// services allowed to introspect tokens as "id:secret,id2:secret2",
+	// they authenticate with HTTP Basic. None closes the endpoint.
+	IntrospectionClients map[string]string `yaml:"introspection_clients" env:"INTROSPECTION_CLIENTS" json:"-"`
 }
 
 func MustLoadYaml() *Config {
@@ -5,3 +5,9 @@ const (
 	EnvDev   = "dev"
 	EnvProd  = "prod"
 )
+
+const (
+	CacheBackendRedis  = "redis"
+	CacheBackendMemory = "memory"
+	CacheBackendTiered = "tiered"
+)
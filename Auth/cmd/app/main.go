@@ -2,9 +2,13 @@ package main
 
 import (
 	"auth/internal/app"
+	"auth/internal/lib/jwt"
 	grpcusers "auth/internal/storage/grpc/users"
+	memorytokens "auth/internal/storage/memory/tokens"
+	redistokens "auth/internal/storage/redis/tokens"
 	"auth/pkg/config"
 	"auth/pkg/lib/logger"
+	"auth/pkg/lib/logger/sl"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -22,7 +26,29 @@ func main() {
 
 	log.Info("connection configured")
 
-	application := app.New(log, cfg.Port, usersConnection)
+	var tokensStorage app.ITokensStorage = memorytokens.New(log)
+	if cfg.RedisHost != "" {
+		redisTokens, err := redistokens.New(log, cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword)
+		if err != nil {
+			log.Warn("redis is unavailable, tokens are kept in memory", sl.Err(err))
+		} else {
+			defer redisTokens.Close()
+			tokensStorage = redisTokens
+			log.Info("tokens storage: redis")
+		}
+	}
+
+	issuer := jwt.MustNew(jwt.Config{
+		Issuer:          cfg.JWTIssuer,
+		Audience:        cfg.JWTAudience,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+		SigningKeyId:    cfg.JWTSigningKeyId,
+		KeysDir:         cfg.JWTKeysDir,
+		Secret:          cfg.JWTSecret,
+	})
+
+	application := app.New(log, cfg.Port, usersConnection, tokensStorage, issuer)
 
 	go func() {
 		application.GRPCServer.MustRun()
@@ -3,9 +3,11 @@ package app
 import (
 	grpcapp "auth/internal/app/grpc"
 	"auth/internal/domain/models"
+	"auth/internal/lib/jwt"
 	authservice "auth/internal/service/auth"
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -15,13 +17,21 @@ type App struct {
 }
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
 	Insert(ctx context.Context, user models.User) (models.User, error)
+	VerifyCredentials(ctx context.Context, login string, password string) (models.User, error)
 }
 
-func New(log *slog.Logger, port int, storage IUsersStorage) *App {
-	authService := authservice.New(log, storage)
+type ITokensStorage interface {
+	SaveFamily(ctx context.Context, family models.TokenFamily) error
+	RotateFamily(ctx context.Context, familyId uuid.UUID, usedId uuid.UUID, nextId uuid.UUID, expiresAt time.Time) error
+	RevokeFamily(ctx context.Context, familyId uuid.UUID) error
+	RevokeToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+}
+
+func New(log *slog.Logger, port int, storage IUsersStorage, tokens ITokensStorage, issuer *jwt.Manager) *App {
+	authService := authservice.New(log, storage, tokens, issuer)
 	grpcApp := grpcapp.New(log, authService, port)
 
 	return &App{
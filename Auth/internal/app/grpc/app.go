@@ -22,6 +22,10 @@ type IAuthService interface {
 	Login(ctx context.Context, login string, password string) (string, string, error)
 	Register(ctx context.Context, user models.User) (models.User, error)
 	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+	Refresh(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	JWKS(ctx context.Context) ([]models.JSONWebKey, error)
+	ValidateAccessToken(ctx context.Context, accessToken string) (models.AccessToken, error)
 }
 
 func New(log *slog.Logger, authService IAuthService, port int) *App {
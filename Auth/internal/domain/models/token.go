@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenFamily links all refresh tokens issued by rotation from one login.
+// Only CurrentId may be exchanged, presenting any older token of the family
+// means it was stolen, and the whole family gets revoked.
+type TokenFamily struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	CurrentId uuid.UUID
+	Revoked   bool
+	ExpiresAt time.Time
+}
+
+// AccessToken is the verified content of an access token.
+type AccessToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	FamilyId  uuid.UUID
+	Login     string
+	Role      string
+	Issuer    string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// JSONWebKey is a public verification key as published in the JWKS.
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
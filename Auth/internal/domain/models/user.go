@@ -1,10 +1,22 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
-	Id       uuid.UUID `json:"id,omitempty"`
-	Login    string    `json:"login"`
-	Password string    `json:"password"`
-	Role     string    `json:"role"`
+	Id          uuid.UUID `json:"id,omitempty"`
+	Login       string    `json:"login"`
+	Password    string    `json:"password"`
+	Role        string    `json:"role"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// LastLoginAt is zero until the first successful login.
+	LastLoginAt time.Time `json:"last_login_at"`
 }
@@ -9,17 +9,20 @@ import (
 	"errors"
 	"log/slog"
 
+	"github.com/chas3air/protos/apierror"
 	authv1 "github.com/chas3air/protos/gen/go/auth"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type IAuthService interface {
-	Login(ctx context.Context, email string, password string) (string, string, error)
+	Login(ctx context.Context, login string, password string) (string, string, error)
 	Register(ctx context.Context, user models.User) (models.User, error)
 	IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
+	Refresh(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	JWKS(ctx context.Context) ([]models.JSONWebKey, error)
+	ValidateAccessToken(ctx context.Context, accessToken string) (models.AccessToken, error)
 }
 
 type ServerAPI struct {
@@ -47,14 +50,28 @@ func (s *ServerAPI) Login(ctx context.Context, req *authv1.LoginRequest) (*authv
 	select {
 	case <-ctx.Done():
 		log.Info("context is over")
-		return nil, status.Error(codes.DeadlineExceeded, "context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
 	default:
 	}
 
-	accessToken, refreshToken, err := s.Service.Login(ctx, req.GetEmail(), req.GetPassword())
+	accessToken, refreshToken, err := s.Service.Login(ctx, req.GetLogin(), req.GetPassword())
 	if err != nil {
+		switch {
+		case errors.Is(err, serviceerrors.ErrInvalidCredentials):
+			log.Warn("Invalid credentials", sl.Err(serviceerrors.ErrInvalidCredentials))
+			return nil, forward(err, apierror.New(apierror.InvalidCredentials, "invalid credentials"))
+
+		case errors.Is(err, serviceerrors.ErrInvalidArgument):
+			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
+			return nil, forward(err, apierror.New(apierror.ValidationFailed, "login and password are required"))
+
+		case errors.Is(err, serviceerrors.ErrDeadlineExceeded):
+			log.Warn("Deadline exceeded", sl.Err(serviceerrors.ErrDeadlineExceeded))
+			return nil, forward(err, apierror.New(apierror.DeadlineExceeded, "deadline exceeded"))
+		}
+
 		log.Error("Cannot generate token", sl.Err(err))
-		return nil, status.Error(codes.Internal, "Cannot generate token")
+		return nil, forward(err, apierror.New(apierror.Internal, "cannot generate token"))
 	}
 
 	return &authv1.LoginResponse{
@@ -63,6 +80,145 @@ func (s *ServerAPI) Login(ctx context.Context, req *authv1.LoginRequest) (*authv
 	}, nil
 }
 
+func (s *ServerAPI) Refresh(ctx context.Context, req *authv1.RefreshRequest) (*authv1.RefreshResponse, error) {
+	const op = "grpc.auth.Refresh"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
+	default:
+	}
+
+	if req.GetRefreshToken() == "" {
+		log.Warn("Refresh token is required")
+		return nil, apierror.New(apierror.ValidationFailed, "invalid request", apierror.FieldViolation{Field: "refresh_token", Description: "required"})
+	}
+
+	accessToken, refreshToken, err := s.Service.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceerrors.ErrTokenReused):
+			log.Warn("Refresh token reused", sl.Err(serviceerrors.ErrTokenReused))
+			return nil, apierror.New(apierror.InvalidToken, "refresh token reused, session revoked")
+
+		case errors.Is(err, serviceerrors.ErrInvalidToken):
+			log.Warn("Invalid refresh token", sl.Err(serviceerrors.ErrInvalidToken))
+			return nil, apierror.New(apierror.InvalidToken, "invalid refresh token")
+
+		default:
+			log.Error("Cannot refresh tokens", sl.Err(err))
+			return nil, apierror.New(apierror.Internal, "cannot refresh tokens")
+		}
+	}
+
+	return &authv1.RefreshResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *ServerAPI) Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	const op = "grpc.auth.Logout"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
+	default:
+	}
+
+	err := s.Service.Logout(ctx, req.GetAccessToken(), req.GetRefreshToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceerrors.ErrInvalidArgument):
+			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
+			return nil, apierror.New(apierror.ValidationFailed, "access or refresh token is required")
+
+		case errors.Is(err, serviceerrors.ErrInvalidToken):
+			log.Warn("Invalid token", sl.Err(serviceerrors.ErrInvalidToken))
+			return nil, apierror.New(apierror.InvalidToken, "invalid token")
+
+		default:
+			log.Error("Cannot logout", sl.Err(err))
+			return nil, apierror.New(apierror.Internal, "cannot logout")
+		}
+	}
+
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (s *ServerAPI) GetJWKS(ctx context.Context, req *authv1.GetJWKSRequest) (*authv1.GetJWKSResponse, error) {
+	const op = "grpc.auth.GetJWKS"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
+	default:
+	}
+
+	keys, err := s.Service.JWKS(ctx)
+	if err != nil {
+		log.Error("Cannot get JWKS", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "cannot get JWKS")
+	}
+
+	protoKeys := make([]*authv1.JWK, 0, len(keys))
+	for _, key := range keys {
+		protoKeys = append(protoKeys, amprofiles.JWKToProtoJWK(key))
+	}
+
+	return &authv1.GetJWKSResponse{
+		Keys: protoKeys,
+	}, nil
+}
+
+func (s *ServerAPI) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	const op = "grpc.auth.ValidateToken"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
+	default:
+	}
+
+	if req.GetAccessToken() == "" {
+		log.Warn("Access token is required")
+		return nil, apierror.New(apierror.ValidationFailed, "invalid request", apierror.FieldViolation{Field: "access_token", Description: "required"})
+	}
+
+	token, err := s.Service.ValidateAccessToken(ctx, req.GetAccessToken())
+	if err != nil {
+		if errors.Is(err, serviceerrors.ErrInvalidToken) || errors.Is(err, serviceerrors.ErrTokenRevoked) {
+			return &authv1.ValidateTokenResponse{
+				Active: false,
+			}, nil
+		}
+
+		log.Error("Cannot validate token", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "cannot validate token")
+	}
+
+	return &authv1.ValidateTokenResponse{
+		Active: true,
+		Claims: amprofiles.AccessTokenToProtoClaims(token),
+	}, nil
+}
+
 func (s *ServerAPI) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
 	const op = "grpc.auth.Register"
 	log := s.Log.With(
@@ -72,14 +228,25 @@ func (s *ServerAPI) Register(ctx context.Context, req *authv1.RegisterRequest) (
 	select {
 	case <-ctx.Done():
 		log.Info("context is over")
-		return nil, status.Error(codes.DeadlineExceeded, "context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
 	default:
 	}
 
+	if req.GetUser() == nil || req.GetUser().GetLogin() == "" || req.GetUser().GetPassword() == "" {
+		log.Warn("Login and password are required")
+		return nil, apierror.New(apierror.ValidationFailed, "login and password are required")
+	}
+
 	userforRegister, err := amprofiles.ProtoUsrToUsr(req.GetUser())
 	if err != nil {
 		log.Error("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
-		return nil, status.Error(codes.InvalidArgument, "Invalid argument")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid user")
+	}
+
+	// ids are assigned by UsersService, a registering user cannot pick one
+	if userforRegister.Id != uuid.Nil {
+		log.Warn("Client-chosen id rejected")
+		return nil, apierror.New(apierror.ValidationFailed, "invalid user", apierror.FieldViolation{Field: "id", Description: "assigned by the server"})
 	}
 
 	createdUser, err := s.Service.Register(ctx, userforRegister)
@@ -87,23 +254,23 @@ func (s *ServerAPI) Register(ctx context.Context, req *authv1.RegisterRequest) (
 		switch {
 		case errors.Is(err, serviceerrors.ErrDeadlineExceeded):
 			log.Warn("Deadline exceeded", sl.Err(serviceerrors.ErrDeadlineExceeded))
-			return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+			return nil, forward(err, apierror.New(apierror.DeadlineExceeded, "deadline exceeded"))
 
 		case errors.Is(err, serviceerrors.ErrInvalidArgument):
 			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
-			return nil, status.Error(codes.InvalidArgument, "invalid argument")
+			return nil, forward(err, apierror.New(apierror.ValidationFailed, "invalid argument"))
 
 		case errors.Is(err, serviceerrors.ErrAlreadyExists):
 			log.Warn("User already exists", sl.Err(serviceerrors.ErrAlreadyExists))
-			return nil, status.Error(codes.AlreadyExists, "user already exists")
+			return nil, forward(err, apierror.New(apierror.UserAlreadyExists, "user already exists"))
 
 		case errors.Is(err, serviceerrors.ErrNotFound):
 			log.Warn("User not found", sl.Err(serviceerrors.ErrNotFound))
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, forward(err, apierror.New(apierror.UserNotFound, "user not found"))
 
 		default:
 			log.Error("Cannot register user", sl.Err(err))
-			return nil, status.Error(codes.Internal, "cannot register user")
+			return nil, forward(err, apierror.New(apierror.Internal, "cannot register user"))
 		}
 	}
 
@@ -121,30 +288,30 @@ func (s *ServerAPI) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*a
 	select {
 	case <-ctx.Done():
 		log.Info("context is over")
-		return nil, status.Error(codes.DeadlineExceeded, "context is over")
+		return nil, apierror.New(apierror.DeadlineExceeded, "context is over")
 	default:
 	}
 
 	id, err := uuid.Parse(req.GetUserId())
 	if err != nil {
 		log.Error("Invalid argument", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "Invalid id")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid id")
 	}
 
 	isAdmin, err := s.Service.IsAdmin(ctx, id)
 	if err != nil {
 		if errors.Is(err, serviceerrors.ErrDeadlineExceeded) {
 			log.Warn("Deadline exceeded", sl.Err(serviceerrors.ErrDeadlineExceeded))
-			return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+			return nil, forward(err, apierror.New(apierror.DeadlineExceeded, "deadline exceeded"))
 		} else if errors.Is(err, serviceerrors.ErrInvalidArgument) {
 			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
-			return nil, status.Error(codes.InvalidArgument, "invalid argument")
+			return nil, forward(err, apierror.New(apierror.ValidationFailed, "invalid argument"))
 		} else if errors.Is(err, serviceerrors.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerrors.ErrNotFound))
-			return nil, status.Error(codes.NotFound, "User not found")
+			return nil, forward(err, apierror.New(apierror.UserNotFound, "user not found"))
 		} else {
 			log.Error("Cannot retrieve user", sl.Err(err))
-			return nil, status.Error(codes.Internal, "Cannot retrieve user")
+			return nil, forward(err, apierror.New(apierror.Internal, "cannot retrieve user"))
 		}
 	}
 
@@ -152,3 +319,14 @@ func (s *ServerAPI) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*a
 		IsAdmin: isAdmin,
 	}, nil
 }
+
+// forward passes on an error of the catalogue received from UsersService,
+// so that clients see what UsersService reported. Other errors are
+// reported as fallback.
+func forward(err error, fallback *apierror.Error) error {
+	if apiErr, ok := apierror.FromError(err); ok {
+		return apiErr
+	}
+
+	return fallback
+}
@@ -3,7 +3,9 @@ package authgrpc_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"auth/internal/domain/models"
 	authgrpc "auth/internal/grpc/auth"
@@ -11,6 +13,7 @@ import (
 	serviceerrors "auth/internal/service"
 	"auth/pkg/lib/logger"
 
+	"github.com/chas3air/protos/apierror"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -41,6 +44,26 @@ func (m *MockAuthService) IsAdmin(ctx context.Context, uid uuid.UUID) (bool, err
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	args := m.Called(ctx, accessToken, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) JWKS(ctx context.Context) ([]models.JSONWebKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.JSONWebKey), args.Error(1)
+}
+
+func (m *MockAuthService) ValidateAccessToken(ctx context.Context, accessToken string) (models.AccessToken, error) {
+	args := m.Called(ctx, accessToken)
+	return args.Get(0).(models.AccessToken), args.Error(1)
+}
+
 // --- Helpers ---
 
 func newTestServer(t *testing.T, service *MockAuthService) *authgrpc.ServerAPI {
@@ -60,7 +83,7 @@ func TestLogin_ContextDone(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	req := &authv1.LoginRequest{Email: "email@test.com", Password: "password"}
+	req := &authv1.LoginRequest{Login: "email@test.com", Password: "password"}
 	_, err := srv.Login(ctx, req)
 
 	assert.Error(t, err)
@@ -73,7 +96,7 @@ func TestLogin_Error(t *testing.T) {
 	mockSvc.On("Login", mock.Anything, "email@test.com", "password").Return("", "", errors.New("some error"))
 
 	srv := newTestServer(t, mockSvc)
-	req := &authv1.LoginRequest{Email: "email@test.com", Password: "password"}
+	req := &authv1.LoginRequest{Login: "email@test.com", Password: "password"}
 
 	_, err := srv.Login(context.Background(), req)
 	assert.Error(t, err)
@@ -82,6 +105,202 @@ func TestLogin_Error(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
+func TestLogin_InvalidCredentials(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	mockSvc.On("Login", mock.Anything, "user", "wrong").Return("", "", serviceerrors.ErrInvalidCredentials)
+
+	srv := newTestServer(t, mockSvc)
+	req := &authv1.LoginRequest{Login: "user", Password: "wrong"}
+
+	_, err := srv.Login(context.Background(), req)
+	assert.Error(t, err)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.Unauthenticated, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestLogin_InvalidArgument(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	mockSvc.On("Login", mock.Anything, "user", "").Return("", "", serviceerrors.ErrInvalidArgument)
+
+	srv := newTestServer(t, mockSvc)
+	req := &authv1.LoginRequest{Login: "user"}
+
+	_, err := srv.Login(context.Background(), req)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRegister_ForwardsUsersServiceError(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	user := models.User{Login: "user1", Password: "pass"}
+	upstream := apierror.New(apierror.ValidationFailed, "invalid user", apierror.FieldViolation{Field: "email", Description: "malformed email"})
+	mockSvc.On("Register", mock.Anything, user).
+		Return(models.User{}, fmt.Errorf("service.auth.Register: %w: %w", serviceerrors.ErrInvalidArgument, upstream.GRPCStatus().Err()))
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Register(context.Background(), &authv1.RegisterRequest{User: amprofiles.UsrToProtoUsr(user)})
+
+	got, ok := apierror.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, upstream, got)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRefresh_Success(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	mockSvc.On("Refresh", mock.Anything, "old-refresh").Return("access", "new-refresh", nil)
+
+	srv := newTestServer(t, mockSvc)
+	resp, err := srv.Refresh(context.Background(), &authv1.RefreshRequest{RefreshToken: "old-refresh"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "access", resp.GetAccessToken())
+	assert.Equal(t, "new-refresh", resp.GetRefreshToken())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRefresh_EmptyToken(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	srv := newTestServer(t, mockSvc)
+
+	_, err := srv.Refresh(context.Background(), &authv1.RefreshRequest{})
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	mockSvc.AssertNotCalled(t, "Refresh", mock.Anything, mock.Anything)
+}
+
+func TestRefresh_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceErr error
+		wantCode   codes.Code
+	}{
+		{"InvalidToken", serviceerrors.ErrInvalidToken, codes.Unauthenticated},
+		{"TokenReused", serviceerrors.ErrTokenReused, codes.Unauthenticated},
+		{"Other", errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockAuthService)
+			mockSvc.On("Refresh", mock.Anything, "token").Return("", "", tt.serviceErr)
+
+			srv := newTestServer(t, mockSvc)
+			_, err := srv.Refresh(context.Background(), &authv1.RefreshRequest{RefreshToken: "token"})
+
+			st, _ := status.FromError(err)
+			assert.Equal(t, tt.wantCode, st.Code())
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout_Success(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	mockSvc.On("Logout", mock.Anything, "access", "refresh").Return(nil)
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Logout(context.Background(), &authv1.LogoutRequest{AccessToken: "access", RefreshToken: "refresh"})
+
+	assert.NoError(t, err)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestLogout_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceErr error
+		wantCode   codes.Code
+	}{
+		{"NoTokens", serviceerrors.ErrInvalidArgument, codes.InvalidArgument},
+		{"InvalidToken", serviceerrors.ErrInvalidToken, codes.Unauthenticated},
+		{"Other", errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockAuthService)
+			mockSvc.On("Logout", mock.Anything, "access", "").Return(tt.serviceErr)
+
+			srv := newTestServer(t, mockSvc)
+			_, err := srv.Logout(context.Background(), &authv1.LogoutRequest{AccessToken: "access"})
+
+			st, _ := status.FromError(err)
+			assert.Equal(t, tt.wantCode, st.Code())
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	keys := []models.JSONWebKey{{Kid: "k1", Kty: "OKP", Alg: "EdDSA", Use: "sig", Crv: "Ed25519", X: "x"}}
+	mockSvc.On("JWKS", mock.Anything).Return(keys, nil)
+
+	srv := newTestServer(t, mockSvc)
+	resp, err := srv.GetJWKS(context.Background(), &authv1.GetJWKSRequest{})
+
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetKeys(), 1)
+	assert.Equal(t, "k1", resp.GetKeys()[0].GetKid())
+	assert.Equal(t, "Ed25519", resp.GetKeys()[0].GetCrv())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestValidateToken_Active(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	token := models.AccessToken{
+		Id:        uuid.New(),
+		UserId:    uuid.New(),
+		FamilyId:  uuid.New(),
+		Login:     "user",
+		Role:      "user",
+		Issuer:    "auth",
+		Audience:  []string{"users-connector"},
+		IssuedAt:  time.Unix(1000, 0),
+		ExpiresAt: time.Unix(2000, 0),
+	}
+	mockSvc.On("ValidateAccessToken", mock.Anything, "token").Return(token, nil)
+
+	srv := newTestServer(t, mockSvc)
+	resp, err := srv.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{AccessToken: "token"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.GetActive())
+	assert.Equal(t, token.UserId.String(), resp.GetClaims().GetSub())
+	assert.Equal(t, []string{"users-connector"}, resp.GetClaims().GetAud())
+	assert.Equal(t, int64(2000), resp.GetClaims().GetExp())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestValidateToken_Inactive(t *testing.T) {
+	for _, svcErr := range []error{serviceerrors.ErrInvalidToken, serviceerrors.ErrTokenRevoked} {
+		mockSvc := new(MockAuthService)
+		mockSvc.On("ValidateAccessToken", mock.Anything, "token").Return(models.AccessToken{}, svcErr)
+
+		srv := newTestServer(t, mockSvc)
+		resp, err := srv.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.False(t, resp.GetActive())
+		assert.Nil(t, resp.GetClaims())
+	}
+}
+
+func TestValidateToken_Errors(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	srv := newTestServer(t, mockSvc)
+
+	_, err := srv.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	mockSvc.On("ValidateAccessToken", mock.Anything, "token").Return(models.AccessToken{}, errors.New("redis is down"))
+	_, err = srv.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{AccessToken: "token"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
 func TestRegister_Success(t *testing.T) {
 	mockSvc := new(MockAuthService)
 	user := models.User{Login: "user1", Password: "pass"}
@@ -112,6 +331,17 @@ func TestRegister_InvalidProtoUser(t *testing.T) {
 	assert.Equal(t, codes.InvalidArgument, st.Code())
 }
 
+func TestRegister_ClientIdRejected(t *testing.T) {
+	mockSvc := new(MockAuthService)
+	srv := newTestServer(t, mockSvc)
+
+	req := &authv1.RegisterRequest{User: &authv1.User{Id: uuid.NewString(), Login: "user1", Password: "pass"}}
+
+	_, err := srv.Register(context.Background(), req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockSvc.AssertNotCalled(t, "Register", mock.Anything, mock.Anything)
+}
+
 func TestRegister_Errors(t *testing.T) {
 	mockSvc := new(MockAuthService)
 	user := models.User{Login: "user1", Password: "pass"}
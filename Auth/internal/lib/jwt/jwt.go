@@ -2,60 +2,252 @@ package jwt
 
 import (
 	"auth/internal/domain/models"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-var (
-	accessSecret  = []byte("1234567890") // лучше хранить в .env
-	refreshSecret = []byte("1234567890") // или в config-файле
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
 )
 
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config describes the key ring. Keys are read from KeysDir, Secret adds an
+// HMAC key under SigningKeyId. All keys verify, SigningKeyId signs.
+type Config struct {
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	SigningKeyId    string
+	KeysDir         string
+	Secret          string
+}
+
 type Claims struct {
-	UID   uuid.UUID `json:"uid"`
-	Login string    `json:"login"`
-	Role  string    `json:"role"`
+	UID    uuid.UUID `json:"uid"`
+	Login  string    `json:"login"`
+	Role   string    `json:"role"`
+	Type   string    `json:"typ"`
+	Family uuid.UUID `json:"fid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateTokens(user models.User) (accessToken string, refreshToken string, err error) {
+// TokenPair is the result of GenerateTokens. RefreshId and RefreshExpiresAt
+// describe RefreshToken so that its family can be tracked.
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	RefreshId        uuid.UUID
+	RefreshExpiresAt time.Time
+}
+
+// Manager issues and verifies tokens. Access tokens are meant for every
+// service in Audience, refresh tokens are addressed to the issuer only.
+type Manager struct {
+	cfg     Config
+	signing *Key
+	keys    map[string]*Key
+}
+
+func New(cfg Config) (*Manager, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("issuer and audience are required")
+	}
+	if cfg.AccessTokenTTL <= 0 || cfg.RefreshTokenTTL <= 0 {
+		return nil, errors.New("token TTLs must be positive")
+	}
+
+	keys := make(map[string]*Key)
+	if cfg.KeysDir != "" {
+		var err error
+		if keys, err = loadKeysDir(cfg.KeysDir); err != nil {
+			return nil, fmt.Errorf("cannot load keys: %w", err)
+		}
+	}
+	if cfg.Secret != "" {
+		keys[cfg.SigningKeyId] = newHMACKey(cfg.SigningKeyId, []byte(cfg.Secret))
+	}
+
+	signing, ok := keys[cfg.SigningKeyId]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", cfg.SigningKeyId)
+	}
+	if !signing.canSign() {
+		return nil, fmt.Errorf("signing key %q has no private part", cfg.SigningKeyId)
+	}
+
+	return &Manager{
+		cfg:     cfg,
+		signing: signing,
+		keys:    keys,
+	}, nil
+}
+
+func MustNew(cfg Config) *Manager {
+	m, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+func (m *Manager) AccessTokenTTL() time.Duration {
+	return m.cfg.AccessTokenTTL
+}
+
+func (m *Manager) GenerateTokens(user models.User, family uuid.UUID) (TokenPair, error) {
 	now := time.Now()
 
-	// Access Token: живет 15 минут
 	accessClaims := Claims{
-		UID:   user.Id,
-		Login: user.Login,
-		Role:  user.Role,
+		UID:    user.Id,
+		Login:  user.Login,
+		Role:   user.Role,
+		Type:   TypeAccess,
+		Family: family,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(15 * time.Minute)),
+			ID:        uuid.NewString(),
+			Issuer:    m.cfg.Issuer,
+			Subject:   user.Id.String(),
+			Audience:  jwt.ClaimStrings{m.cfg.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.cfg.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessToken, err = at.SignedString(accessSecret)
+	accessToken, err := m.sign(accessClaims)
 	if err != nil {
-		return "", "", err
+		return TokenPair{}, err
 	}
 
-	// Refresh Token: живет 7 дней
+	refreshId := uuid.New()
+	refreshExpiresAt := now.Add(m.cfg.RefreshTokenTTL)
 	refreshClaims := Claims{
-		UID:   user.Id,
-		Login: user.Login,
-		Role:  user.Role,
+		UID:    user.Id,
+		Login:  user.Login,
+		Role:   user.Role,
+		Type:   TypeRefresh,
+		Family: family,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(7 * 24 * time.Hour)),
+			ID:        refreshId.String(),
+			Issuer:    m.cfg.Issuer,
+			Subject:   user.Id.String(),
+			Audience:  jwt.ClaimStrings{m.cfg.Issuer},
+			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err = rt.SignedString(refreshSecret)
+	refreshToken, err := m.sign(refreshClaims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshId:        refreshId,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// ParseAccessToken verifies signature, expiry, issuer, audience and type of
+// an access token.
+func (m *Manager) ParseAccessToken(token string) (Claims, error) {
+	return m.parse(token, m.cfg.Audience, TypeAccess)
+}
+
+// ParseRefreshToken verifies signature, expiry, issuer, audience and type of
+// a refresh token.
+func (m *Manager) ParseRefreshToken(token string) (Claims, error) {
+	return m.parse(token, m.cfg.Issuer, TypeRefresh)
+}
+
+// JWKS returns the public keys other services need to verify access tokens
+// offline. HMAC secrets are never published.
+func (m *Manager) JWKS() []models.JSONWebKey {
+	jwks := make([]models.JSONWebKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if key.symmetric() {
+			continue
+		}
+		jwks = append(jwks, key.jwk())
+	}
+
+	sort.Slice(jwks, func(i, j int) bool {
+		return jwks[i].Kid < jwks[j].Kid
+	})
+
+	return jwks
+}
+
+// AccessToken converts access token claims to the domain model.
+func (c Claims) AccessToken() models.AccessToken {
+	id, _ := uuid.Parse(c.ID)
+
+	return models.AccessToken{
+		Id:        id,
+		UserId:    c.UID,
+		FamilyId:  c.Family,
+		Login:     c.Login,
+		Role:      c.Role,
+		Issuer:    c.Issuer,
+		Audience:  c.Audience,
+		IssuedAt:  c.IssuedAt.Time,
+		ExpiresAt: c.ExpiresAt.Time,
+	}
+}
+
+func (m *Manager) sign(claims Claims) (string, error) {
+	t := jwt.NewWithClaims(m.signing.method, claims)
+	t.Header["kid"] = m.signing.Id
+
+	return t.SignedString(m.signing.signing)
+}
+
+func (m *Manager) parse(token string, audience string, typ string) (Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(token, &claims, m.keyFunc,
+		jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithIssuer(m.cfg.Issuer),
+		jwt.WithAudience(audience),
+	)
 	if err != nil {
-		return "", "", err
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.Type != typ {
+		return Claims{}, fmt.Errorf("%w: not a %s token", ErrInvalidToken, typ)
+	}
+	if _, err := uuid.Parse(claims.ID); err != nil || claims.Family == uuid.Nil {
+		return Claims{}, fmt.Errorf("%w: missing jti or family", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// keyFunc picks the verification key by the kid header. The algorithm must
+// match the key, otherwise a public key could be used as an HMAC secret.
+func (m *Manager) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("algorithm %s does not match key %q", t.Method.Alg(), kid)
 	}
 
-	return accessToken, refreshToken, nil
+	return key.verifying, nil
 }
@@ -0,0 +1,186 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"auth/internal/domain/models"
+	"auth/internal/lib/jwt"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func baseConfig() jwt.Config {
+	return jwt.Config{
+		Issuer:          "auth",
+		Audience:        "users-connector",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}
+}
+
+func writePrivateKey(t *testing.T, dir string, kid string, key any) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+}
+
+func TestManager_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "rsa", rsaKey)
+	writePrivateKey(t, dir, "ec", ecKey)
+	writePrivateKey(t, dir, "ed", edKey)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hs.secret"), []byte("secret\n"), 0o600))
+
+	tests := []struct {
+		kid string
+		kty string
+	}{
+		{"rsa", "RSA"},
+		{"ec", "EC"},
+		{"ed", "OKP"},
+		{"hs", ""},
+	}
+
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+
+	for _, tt := range tests {
+		t.Run(tt.kid, func(t *testing.T) {
+			cfg := baseConfig()
+			cfg.KeysDir = dir
+			cfg.SigningKeyId = tt.kid
+
+			m, err := jwt.New(cfg)
+			require.NoError(t, err)
+
+			pair, err := m.GenerateTokens(user, uuid.New())
+			require.NoError(t, err)
+
+			claims, err := m.ParseAccessToken(pair.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, user.Id, claims.UID)
+			assert.Equal(t, "auth", claims.Issuer)
+
+			_, err = m.ParseRefreshToken(pair.RefreshToken)
+			assert.NoError(t, err)
+
+			// access and refresh tokens are addressed to different audiences
+			_, err = m.ParseRefreshToken(pair.AccessToken)
+			assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+			_, err = m.ParseAccessToken(pair.RefreshToken)
+			assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+		})
+	}
+
+	cfg := baseConfig()
+	cfg.KeysDir = dir
+	cfg.SigningKeyId = "ed"
+	m := jwt.MustNew(cfg)
+
+	jwks := m.JWKS()
+	require.Len(t, jwks, 3, "HMAC secrets must not be published")
+	for _, key := range jwks {
+		assert.NotEmpty(t, key.Kty)
+		assert.Equal(t, "sig", key.Use)
+	}
+}
+
+func TestManager_Rotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2025-01", oldKey)
+
+	cfg := baseConfig()
+	cfg.KeysDir = dir
+	cfg.SigningKeyId = "2025-01"
+	old := jwt.MustNew(cfg)
+
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	pair, err := old.GenerateTokens(user, uuid.New())
+	require.NoError(t, err)
+
+	// the retired key stays as a public key for verification only
+	pub, err := x509.MarshalPKIXPublicKey(oldKey.Public())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-01.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o600))
+	writePrivateKey(t, dir, "2025-02", newKey)
+
+	cfg.SigningKeyId = "2025-02"
+	rotated := jwt.MustNew(cfg)
+
+	_, err = rotated.ParseAccessToken(pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Len(t, rotated.JWKS(), 2)
+
+	cfg.SigningKeyId = "2025-01"
+	_, err = jwt.New(cfg)
+	assert.Error(t, err, "a public key cannot sign")
+}
+
+func TestManager_RejectsForeignTokens(t *testing.T) {
+	cfg := baseConfig()
+	cfg.SigningKeyId = "k"
+	cfg.Secret = "secret"
+	m := jwt.MustNew(cfg)
+
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+
+	otherAudience := cfg
+	otherAudience.Audience = "another-service"
+	pair, err := jwt.MustNew(otherAudience).GenerateTokens(user, uuid.New())
+	require.NoError(t, err)
+	_, err = m.ParseAccessToken(pair.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+
+	otherSecret := cfg
+	otherSecret.Secret = "another-secret"
+	pair, err = jwt.MustNew(otherSecret).GenerateTokens(user, uuid.New())
+	require.NoError(t, err)
+	_, err = m.ParseAccessToken(pair.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+
+	otherKid := cfg
+	otherKid.SigningKeyId = "unknown"
+	pair, err = jwt.MustNew(otherKid).GenerateTokens(user, uuid.New())
+	require.NoError(t, err)
+	_, err = m.ParseAccessToken(pair.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cfg := baseConfig()
+	cfg.SigningKeyId = "missing"
+	_, err := jwt.New(cfg)
+	assert.Error(t, err)
+
+	cfg = baseConfig()
+	cfg.SigningKeyId = "k"
+	cfg.Secret = "secret"
+	cfg.Audience = ""
+	_, err = jwt.New(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"auth/internal/domain/models"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key")
+
+// Key is one entry of the key ring. HMAC keys keep the secret in both
+// signing and verification, asymmetric keys loaded from a public PEM
+// can only verify.
+type Key struct {
+	Id        string
+	Algorithm string
+	method    jwt.SigningMethod
+	signing   any
+	verifying any
+}
+
+func (k *Key) canSign() bool {
+	return k.signing != nil
+}
+
+func (k *Key) symmetric() bool {
+	return k.Algorithm == AlgorithmHS256
+}
+
+func newHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		Id:        id,
+		Algorithm: AlgorithmHS256,
+		method:    jwt.SigningMethodHS256,
+		signing:   secret,
+		verifying: secret,
+	}
+}
+
+// loadKeysDir reads <kid>.pem (private or public PEM key) and <kid>.secret
+// (HMAC secret) files from dir.
+func loadKeysDir(dir string) (map[string]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*Key, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		kid := strings.TrimSuffix(entry.Name(), ext)
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		switch ext {
+		case ".pem":
+			key, err := parsePEMKey(kid, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			keys[kid] = key
+		case ".secret":
+			secret := strings.TrimSpace(string(data))
+			if secret == "" {
+				return nil, fmt.Errorf("%s: empty secret", entry.Name())
+			}
+			keys[kid] = newHMACKey(kid, []byte(secret))
+		}
+	}
+
+	return keys, nil
+}
+
+func parsePEMKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{Id: kid}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.signing = signer
+		parsed = signer.Public()
+	}
+
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm, key.method = AlgorithmRS256, jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: only P-256 curve is supported", ErrUnsupportedKey)
+		}
+		key.Algorithm, key.method = AlgorithmES256, jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.Algorithm, key.method = AlgorithmEdDSA, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, parsed)
+	}
+	key.verifying = parsed
+
+	return key, nil
+}
+
+// jwk renders the public part of an asymmetric key (RFC 7517, 7518, 8037).
+func (k *Key) jwk() models.JSONWebKey {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := models.JSONWebKey{
+		Kid: k.Id,
+		Alg: k.Algorithm,
+		Use: "sig",
+	}
+
+	switch pub := k.verifying.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	}
+
+	return jwk
+}
@@ -2,30 +2,82 @@ package umprofiles
 
 import (
 	"auth/internal/domain/models"
+	"time"
 
 	umv1 "github.com/chas3air/protos/gen/go/usersManager"
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func UsrToProtoUsr(user models.User) *umv1.User {
 	return &umv1.User{
-		Id:       user.Id.String(),
-		Login:    user.Login,
-		Password: user.Password,
-		Role:     user.Role,
+		Id:          idToProto(user.Id),
+		Login:       user.Login,
+		Password:    user.Password,
+		Role:        user.Role,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		CreatedAt:   timeToProto(user.CreatedAt),
+		UpdatedAt:   timeToProto(user.UpdatedAt),
+		LastLoginAt: timeToProto(user.LastLoginAt),
 	}
 }
 
 func ProtoUsrToUsr(proto_usr *umv1.User) (models.User, error) {
-	parsedUUID, err := uuid.Parse(proto_usr.GetId())
+	parsedUUID, err := protoToId(proto_usr.GetId())
 	if err != nil {
 		return models.User{}, err
 	}
 
 	return models.User{
-		Id:       parsedUUID,
-		Login:    proto_usr.GetLogin(),
-		Password: proto_usr.GetPassword(),
-		Role:     proto_usr.GetRole(),
+		Id:          parsedUUID,
+		Login:       proto_usr.GetLogin(),
+		Password:    proto_usr.GetPassword(),
+		Role:        proto_usr.GetRole(),
+		Email:       proto_usr.GetEmail(),
+		DisplayName: proto_usr.GetDisplayName(),
+		Locale:      proto_usr.GetLocale(),
+		Timezone:    proto_usr.GetTimezone(),
+		CreatedAt:   protoToTime(proto_usr.GetCreatedAt()),
+		UpdatedAt:   protoToTime(proto_usr.GetUpdatedAt()),
+		LastLoginAt: protoToTime(proto_usr.GetLastLoginAt()),
 	}, nil
 }
+
+// protoToId reads an optional id, an empty string stands for uuid.Nil.
+func protoToId(id string) (uuid.UUID, error) {
+	if id == "" {
+		return uuid.Nil, nil
+	}
+
+	return uuid.Parse(id)
+}
+
+// idToProto leaves the id empty when it is not assigned yet.
+func idToProto(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+
+	return id.String()
+}
+
+// timeToProto leaves unset timestamps out of the message.
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+// protoToTime maps a missing timestamp to the zero time.
+func protoToTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
@@ -10,25 +10,43 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
 	Insert(ctx context.Context, user models.User) (models.User, error)
+	VerifyCredentials(ctx context.Context, login string, password string) (models.User, error)
+}
+
+type ITokensStorage interface {
+	SaveFamily(ctx context.Context, family models.TokenFamily) error
+	// RotateFamily replaces the current refresh token of the family with
+	// nextId. If usedId is not the current one the family gets revoked and
+	// storageerrors.ErrTokenReused is returned.
+	RotateFamily(ctx context.Context, familyId uuid.UUID, usedId uuid.UUID, nextId uuid.UUID, expiresAt time.Time) error
+	RevokeFamily(ctx context.Context, familyId uuid.UUID) error
+	// RevokeToken puts an access token id (or a whole family id) on the
+	// denylist until expiresAt.
+	RevokeToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
 }
 
 type AuthService struct {
 	log     *slog.Logger
 	storage IUsersStorage
+	tokens  ITokensStorage
+	issuer  *jwt.Manager
 }
 
-func New(log *slog.Logger, storage IUsersStorage) *AuthService {
+func New(log *slog.Logger, storage IUsersStorage, tokens ITokensStorage, issuer *jwt.Manager) *AuthService {
 	return &AuthService{
 		log:     log,
 		storage: storage,
+		tokens:  tokens,
+		issuer:  issuer,
 	}
 }
 
@@ -45,66 +63,256 @@ func (a *AuthService) Login(ctx context.Context, login string, password string)
 	default:
 	}
 
-	users, err := a.storage.GetUsers(ctx)
+	loggedUser, err := a.storage.VerifyCredentials(ctx, login, password)
 	if err != nil {
-		log.Error("Failed to get user", sl.Err(err))
+		if errors.Is(err, storageerrors.ErrInvalidCredentials) {
+			log.Warn("Invalid credentials", sl.Err(serviceerrors.ErrInvalidCredentials))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrInvalidCredentials, err)
+		}
+		if errors.Is(err, storageerrors.ErrInvalidArgument) {
+			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrInvalidArgument, err)
+		}
+		if errors.Is(err, storageerrors.ErrDeadlineExceeded) {
+			log.Warn("Deadline exceeded", sl.Err(serviceerrors.ErrDeadlineExceeded))
+			return "", "", fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrDeadlineExceeded, err)
+		}
+
+		log.Error("Failed to verify credentials", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	var loggedUser = models.User{Login: "undef"}
-	for _, user := range users {
-		if user.Login == login && user.Password == password {
-			loggedUser = user
-		}
+	familyId := uuid.New()
+	pair, err := a.issuer.GenerateTokens(loggedUser, familyId)
+	if err != nil {
+		log.Error("Failed to generate tokens", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.tokens.SaveFamily(ctx, models.TokenFamily{
+		Id:        familyId,
+		UserId:    loggedUser.Id,
+		CurrentId: pair.RefreshId,
+		ExpiresAt: pair.RefreshExpiresAt,
+	})
+	if err != nil {
+		log.Error("Failed to save token family", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair.AccessToken, pair.RefreshToken, nil
+}
+
+// Refresh implements grpcapp.IAuthService.
+// The presented refresh token is exchanged for a new pair exactly once.
+func (a *AuthService) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	const op = "service.auth.Refresh"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return "", "", fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	claims, err := a.issuer.ParseRefreshToken(refreshToken)
+	if err != nil {
+		log.Warn("Invalid refresh token", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
+	}
+
+	usedId, err := uuid.Parse(claims.ID)
+	if err != nil {
+		log.Warn("Invalid refresh token id", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
 	}
-	if loggedUser.Login == "undef" {
-		log.Error("User doesn't exists")
-		return "", "", fmt.Errorf("%s: %w", op, errors.New("user doesn't exists"))
+
+	// role or login may have changed since the family was created
+	user, err := a.storage.GetUserById(ctx, claims.UID)
+	if err != nil {
+		if errors.Is(err, storageerrors.ErrNotFound) {
+			log.Warn("Token owner not found", sl.Err(err))
+			if err := a.tokens.RevokeFamily(ctx, claims.Family); err != nil {
+				log.Warn("Cannot revoke token family", sl.Err(err))
+			}
+			return "", "", fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
+		}
+
+		log.Error("Cannot retrieve user", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	accessToken, refreshToken, err := jwt.GenerateTokens(loggedUser)
+	pair, err := a.issuer.GenerateTokens(user, claims.Family)
 	if err != nil {
 		log.Error("Failed to generate tokens", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	return accessToken, refreshToken, nil
+	err = a.tokens.RotateFamily(ctx, claims.Family, usedId, pair.RefreshId, pair.RefreshExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, storageerrors.ErrTokenReused):
+			log.Warn("Refresh token reuse detected", slog.String("uid", claims.UID.String()), sl.Err(err))
+			a.revokeSession(ctx, claims.Family)
+			return "", "", fmt.Errorf("%s: %w", op, serviceerrors.ErrTokenReused)
+
+		case errors.Is(err, storageerrors.ErrTokenRevoked), errors.Is(err, storageerrors.ErrNotFound):
+			log.Warn("Token family is not active", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
+
+		default:
+			log.Error("Failed to rotate token family", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return pair.AccessToken, pair.RefreshToken, nil
 }
 
-// Register implements grpcapp.IAuthService.
-func (a *AuthService) Register(ctx context.Context, userForCheck models.User) (models.User, error) {
-	const op = "service.auth.Register"
+// Logout implements grpcapp.IAuthService.
+// Either token identifies the session, both are accepted so clients that
+// lost one of them can still log out.
+func (a *AuthService) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	const op = "service.auth.Logout"
 	log := a.log.With(
 		"op", op,
 	)
 
 	select {
 	case <-ctx.Done():
-		log.Info("context is over")
-		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+		return fmt.Errorf("%s: %w", op, ctx.Err())
 	default:
 	}
 
-	users, err := a.storage.GetUsers(ctx)
+	if accessToken == "" && refreshToken == "" {
+		log.Warn("No token to revoke")
+		return fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidArgument)
+	}
+
+	var family uuid.UUID
+
+	if accessToken != "" {
+		claims, err := a.issuer.ParseAccessToken(accessToken)
+		if err != nil {
+			log.Warn("Invalid access token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
+		}
+
+		token := claims.AccessToken()
+		if err := a.tokens.RevokeToken(ctx, token.Id, token.ExpiresAt); err != nil {
+			log.Error("Cannot revoke access token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		family = token.FamilyId
+	}
+
+	if refreshToken != "" {
+		claims, err := a.issuer.ParseRefreshToken(refreshToken)
+		if err != nil {
+			log.Warn("Invalid refresh token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
+		}
+		family = claims.Family
+	}
+
+	a.revokeSession(ctx, family)
+
+	return nil
+}
+
+// ValidateAccessToken checks signature and expiry of an access token and
+// consults the denylist for the token and for its session.
+func (a *AuthService) ValidateAccessToken(ctx context.Context, accessToken string) (models.AccessToken, error) {
+	const op = "service.auth.ValidateAccessToken"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.AccessToken{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	claims, err := a.issuer.ParseAccessToken(accessToken)
 	if err != nil {
-		log.Error("Cannot fetching users", sl.Err(err))
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		log.Warn("Invalid access token", sl.Err(err))
+		return models.AccessToken{}, fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidToken)
 	}
 
-	var appUser models.User
-	for _, user := range users {
-		if userForCheck.Login == user.Login && userForCheck.Password == user.Password {
-			appUser = user
+	token := claims.AccessToken()
+	for _, id := range []uuid.UUID{token.Id, token.FamilyId} {
+		revoked, err := a.tokens.IsTokenRevoked(ctx, id)
+		if err != nil {
+			log.Error("Cannot check denylist", sl.Err(err))
+			return models.AccessToken{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if revoked {
+			log.Warn("Access token revoked", slog.String("jti", token.Id.String()))
+			return models.AccessToken{}, fmt.Errorf("%s: %w", op, serviceerrors.ErrTokenRevoked)
 		}
 	}
 
-	if appUser.Login != "" {
-		log.Warn("User already exists", sl.Err(serviceerrors.ErrAlreadyExists))
-		return models.User{}, fmt.Errorf("%s: %w", op, serviceerrors.ErrAlreadyExists)
+	return token, nil
+}
+
+// revokeSession ends a token family: its refresh token stops rotating and
+// every access token issued for it is denied until they expire on their own.
+func (a *AuthService) revokeSession(ctx context.Context, family uuid.UUID) {
+	log := a.log.With(
+		"op", "service.auth.revokeSession",
+	)
+
+	if err := a.tokens.RevokeFamily(ctx, family); err != nil && !errors.Is(err, storageerrors.ErrNotFound) {
+		log.Warn("Cannot revoke token family", sl.Err(err))
+	}
+
+	if err := a.tokens.RevokeToken(ctx, family, time.Now().Add(a.issuer.AccessTokenTTL())); err != nil {
+		log.Warn("Cannot deny family access tokens", sl.Err(err))
 	}
+}
 
+// JWKS implements grpcapp.IAuthService.
+func (a *AuthService) JWKS(ctx context.Context) ([]models.JSONWebKey, error) {
+	const op = "service.auth.JWKS"
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	return a.issuer.JWKS(), nil
+}
+
+// Register implements grpcapp.IAuthService.
+func (a *AuthService) Register(ctx context.Context, userForCheck models.User) (models.User, error) {
+	const op = "service.auth.Register"
+	log := a.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Info("context is over")
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	// duplicate logins are rejected by the unique index in UsersService
 	insertedUser, err := a.storage.Insert(ctx, userForCheck)
 	if err != nil {
+		if errors.Is(err, storageerrors.ErrAlreadyExists) {
+			log.Warn("User already exists", sl.Err(serviceerrors.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrAlreadyExists, err)
+		}
+		if errors.Is(err, storageerrors.ErrInvalidArgument) {
+			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrInvalidArgument, err)
+		}
+
 		log.Error("Cannot insert user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -130,13 +338,13 @@ func (a *AuthService) IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
 	if err != nil {
 		if errors.Is(err, storageerrors.ErrDeadlineExceeded) {
 			log.Warn("Deadline exceeded", sl.Err(serviceerrors.ErrDeadlineExceeded))
-			return false, fmt.Errorf("%s: %w", op, serviceerrors.ErrDeadlineExceeded)
+			return false, fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrDeadlineExceeded, err)
 		} else if errors.Is(err, storageerrors.ErrInvalidArgument) {
 			log.Warn("Invalid argument", sl.Err(serviceerrors.ErrInvalidArgument))
-			return false, fmt.Errorf("%s: %w", op, serviceerrors.ErrInvalidArgument)
+			return false, fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrInvalidArgument, err)
 		} else if errors.Is(err, storageerrors.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerrors.ErrNotFound))
-			return false, fmt.Errorf("%s: %w", op, serviceerrors.ErrNotFound)
+			return false, fmt.Errorf("%s: %w: %w", op, serviceerrors.ErrNotFound, err)
 		} else {
 			log.Error("Cannot retrieve user", sl.Err(err))
 			return false, fmt.Errorf("%s: %w", op, err)
@@ -145,3 +353,4 @@ func (a *AuthService) IsAdmin(ctx context.Context, uid uuid.UUID) (bool, error)
 
 	return user.Role == "admin", nil
 }
+
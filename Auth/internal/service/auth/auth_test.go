@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"auth/internal/domain/models"
+	"auth/internal/lib/jwt"
 	serviceerrors "auth/internal/service"
 	authservice "auth/internal/service/auth"
 	storageerrors "auth/internal/storage"
+	memorytokens "auth/internal/storage/memory/tokens"
 	"auth/pkg/lib/logger"
 
 	"github.com/google/uuid"
@@ -22,11 +25,6 @@ type MockUsersStorage struct {
 	mock.Mock
 }
 
-func (m *MockUsersStorage) GetUsers(ctx context.Context) ([]models.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]models.User), args.Error(1)
-}
-
 func (m *MockUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	args := m.Called(ctx, uid)
 	return args.Get(0).(models.User), args.Error(1)
@@ -37,28 +35,66 @@ func (m *MockUsersStorage) Insert(ctx context.Context, user models.User) (models
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+func (m *MockUsersStorage) VerifyCredentials(ctx context.Context, login string, password string) (models.User, error) {
+	args := m.Called(ctx, login, password)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
 // --- Tests ---
 
 func newTestService(storage *MockUsersStorage) *authservice.AuthService {
 	logger := logger.SetupLogger("local")
-	return authservice.New(logger, storage)
+	issuer := jwt.MustNew(jwt.Config{
+		Issuer:          "auth",
+		Audience:        "test",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		SigningKeyId:    "test",
+		Secret:          "secret",
+	})
+
+	return authservice.New(logger, storage, memorytokens.New(logger), issuer)
+}
+
+func TestLogin_Success(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	accessToken, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, accessToken)
+	assert.NotEmpty(t, refreshToken)
+	mockStorage.AssertExpectations(t)
 }
 
-func TestLogin_UserNotFound(t *testing.T) {
+func TestLogin_InvalidCredentials(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
-	mockStorage.On("GetUsers", mock.Anything).Return([]models.User{}, nil)
+	mockStorage.On("VerifyCredentials", mock.Anything, "no-user", "pass").Return(models.User{}, storageerrors.ErrInvalidCredentials)
 
 	svc := newTestService(mockStorage)
 
 	_, _, err := svc.Login(context.Background(), "no-user", "pass")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "user doesn't exists")
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidCredentials)
 	mockStorage.AssertExpectations(t)
 }
 
-func TestLogin_GetUsersError(t *testing.T) {
+func TestLogin_InvalidArgument(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
-	mockStorage.On("GetUsers", mock.Anything).Return([]models.User(nil), errors.New("db error"))
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "").Return(models.User{}, storageerrors.ErrInvalidArgument)
+
+	svc := newTestService(mockStorage)
+
+	_, _, err := svc.Login(context.Background(), "user", "")
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidArgument)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestLogin_StorageError(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("VerifyCredentials", mock.Anything, "any", "any").Return(models.User{}, errors.New("db error"))
 
 	svc := newTestService(mockStorage)
 
@@ -68,12 +104,180 @@ func TestLogin_GetUsersError(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+func TestRefresh_RotatesTokens(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+	mockStorage.On("GetUserById", mock.Anything, user.Id).Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	_, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	accessToken, rotated, err := svc.Refresh(context.Background(), refreshToken)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, accessToken)
+	assert.NotEqual(t, refreshToken, rotated)
+
+	_, _, err = svc.Refresh(context.Background(), rotated)
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+	mockStorage.On("GetUserById", mock.Anything, user.Id).Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	_, stolen, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	_, rotated, err := svc.Refresh(context.Background(), stolen)
+	assert.NoError(t, err)
+
+	_, _, err = svc.Refresh(context.Background(), stolen)
+	assert.ErrorIs(t, err, serviceerrors.ErrTokenReused)
+
+	// the legitimate holder is logged out as well
+	_, _, err = svc.Refresh(context.Background(), rotated)
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+}
+
+func TestRefresh_InvalidToken(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	accessToken, _, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Garbage", "not-a-jwt"},
+		{"AccessToken", accessToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := svc.Refresh(context.Background(), tt.token)
+			assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+		})
+	}
+	mockStorage.AssertNotCalled(t, "GetUserById", mock.Anything, mock.Anything)
+}
+
+func TestRefresh_UserDeleted(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+	mockStorage.On("GetUserById", mock.Anything, user.Id).Return(models.User{}, storageerrors.ErrNotFound)
+
+	svc := newTestService(mockStorage)
+
+	_, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	_, _, err = svc.Refresh(context.Background(), refreshToken)
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+	mockStorage.On("GetUserById", mock.Anything, user.Id).Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	accessToken, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	token, err := svc.ValidateAccessToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, token.UserId)
+
+	assert.NoError(t, svc.Logout(context.Background(), accessToken, ""))
+
+	_, err = svc.ValidateAccessToken(context.Background(), accessToken)
+	assert.ErrorIs(t, err, serviceerrors.ErrTokenRevoked)
+
+	_, _, err = svc.Refresh(context.Background(), refreshToken)
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+}
+
+func TestLogout_ByRefreshTokenDeniesAccessTokens(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	accessToken, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	assert.NoError(t, svc.Logout(context.Background(), "", refreshToken))
+
+	_, err = svc.ValidateAccessToken(context.Background(), accessToken)
+	assert.ErrorIs(t, err, serviceerrors.ErrTokenRevoked)
+}
+
+func TestLogout_InvalidInput(t *testing.T) {
+	svc := newTestService(new(MockUsersStorage))
+
+	err := svc.Logout(context.Background(), "", "")
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidArgument)
+
+	err = svc.Logout(context.Background(), "not-a-jwt", "")
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+}
+
+func TestValidateAccessToken_ReturnsClaims(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "admin"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	accessToken, _, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	token, err := svc.ValidateAccessToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, token.UserId)
+	assert.Equal(t, "admin", token.Role)
+	assert.Equal(t, "auth", token.Issuer)
+	assert.Equal(t, []string{"test"}, token.Audience)
+	assert.NotEqual(t, uuid.Nil, token.FamilyId)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+}
+
+func TestValidateAccessToken_RejectsRefreshToken(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user", Role: "user"}
+	mockStorage.On("VerifyCredentials", mock.Anything, "user", "pass").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+
+	_, refreshToken, err := svc.Login(context.Background(), "user", "pass")
+	assert.NoError(t, err)
+
+	_, err = svc.ValidateAccessToken(context.Background(), refreshToken)
+	assert.ErrorIs(t, err, serviceerrors.ErrInvalidToken)
+}
+
 func TestRegister_Success(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	newUser := models.User{Login: "newuser", Password: "pass123"}
 
-	// В хранилище нет пользователей с таким логином и паролем
-	mockStorage.On("GetUsers", mock.Anything).Return([]models.User{}, nil)
 	mockStorage.On("Insert", mock.Anything, newUser).Return(newUser, nil)
 
 	svc := newTestService(mockStorage)
@@ -88,7 +292,8 @@ func TestRegister_UserAlreadyExists(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	existingUser := models.User{Login: "existuser", Password: "pass123"}
 
-	mockStorage.On("GetUsers", mock.Anything).Return([]models.User{existingUser}, nil)
+	// Дубликат отклоняется уникальным индексом на стороне UsersService
+	mockStorage.On("Insert", mock.Anything, existingUser).Return(models.User{}, storageerrors.ErrAlreadyExists)
 
 	svc := newTestService(mockStorage)
 
@@ -101,7 +306,6 @@ func TestRegister_InsertError(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	newUser := models.User{Login: "newuser", Password: "pass123"}
 
-	mockStorage.On("GetUsers", mock.Anything).Return([]models.User{}, nil)
 	mockStorage.On("Insert", mock.Anything, newUser).Return(models.User{}, errors.New("insert error"))
 
 	svc := newTestService(mockStorage)
@@ -7,4 +7,10 @@ var (
 	ErrAlreadyExists    = errors.New("resource already exists")
 	ErrInvalidArgument  = errors.New("invalid argument")
 	ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenReused  = errors.New("refresh token reused")
+	ErrTokenRevoked = errors.New("token revoked")
 )
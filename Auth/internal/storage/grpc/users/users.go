@@ -44,43 +44,53 @@ func (s *GRPCUsersStorage) Close() {
 	}
 }
 
-// GetUsers implements users.IUsersStorage.
-func (s *GRPCUsersStorage) GetUsers(ctx context.Context) ([]models.User, error) {
-	const op = "storage.grpc.users.GetUsers"
+// GetUserById implements users.IUsersStorage.
+func (s *GRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "storage.grpc.users.GetUserById"
 	log := s.Log.With(
 		"op", op,
 	)
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
 	default:
 	}
 
 	c := umv1.NewUsersManagerClient(s.conn)
-	res, err := c.GetUsers(ctx, nil)
+	res, err := c.GetUserById(ctx, &umv1.GetUserByIdRequest{
+		Id: uid.String(),
+	})
 	if err != nil {
-		log.Error("Failed to get users", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	var resUsers = make([]models.User, 0, len(res.GetUsers()))
-	for _, pbUser := range res.GetUsers() {
-		convertedUser, err := umprofiles.ProtoUsrToUsr(pbUser)
-		if err != nil {
-			log.Warn("Error converting user", sl.Err(err))
-			continue
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.DeadlineExceeded:
+			log.Warn("Deadline exceeded", sl.Err(storageerrors.ErrDeadlineExceeded))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrDeadlineExceeded, err)
+		case codes.InvalidArgument:
+			log.Warn("Invalid argument", sl.Err(storageerrors.ErrInvalidArgument))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrInvalidArgument, err)
+		case codes.NotFound:
+			log.Warn("User not found", sl.Err(storageerrors.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrNotFound, err)
+		default:
+			log.Error("Cannot retrieve user by id", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
 		}
+	}
 
-		resUsers = append(resUsers, convertedUser)
+	convertedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
+	if err != nil {
+		log.Error("Error converting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return resUsers, nil
+	return convertedUser, nil
 }
 
-// GetUserById implements users.IUsersStorage.
-func (s *GRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
-	const op = "storage.grpc.users.GetUserById"
+// Insert implements users.IUsersStorage.
+func (s *GRPCUsersStorage) Insert(ctx context.Context, userForInsert models.User) (models.User, error) {
+	const op = "storage.grpc.users.Insert"
 	log := s.Log.With(
 		"op", op,
 	)
@@ -92,40 +102,38 @@ func (s *GRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 	}
 
 	c := umv1.NewUsersManagerClient(s.conn)
-	res, err := c.GetUserById(ctx, &umv1.GetUserByIdRequest{
-		Id: uid.String(),
+	res, err := c.Insert(ctx, &umv1.InsertRequest{
+		User: umprofiles.UsrToProtoUsr(userForInsert),
 	})
 	if err != nil {
-		if st, ok := status.FromError(err); ok {
-			switch st.Code() {
-			case codes.DeadlineExceeded:
-				log.Warn("Deadline exceeded", sl.Err(storageerrors.ErrDeadlineExceeded))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrDeadlineExceeded)
-			case codes.InvalidArgument:
-				log.Warn("Invalid argument", sl.Err(storageerrors.ErrInvalidArgument))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrInvalidArgument)
-			case codes.NotFound:
-				log.Warn("User not found", sl.Err(storageerrors.ErrNotFound))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrNotFound)
-			default:
-				log.Error("Cannot retrieve user by id", sl.Err(err))
-				return models.User{}, fmt.Errorf("%s; %w", op, err)
-			}
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.DeadlineExceeded:
+			log.Warn("Deadline exceeded", sl.Err(storageerrors.ErrDeadlineExceeded))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrDeadlineExceeded, err)
+		case codes.InvalidArgument:
+			log.Warn("Invalid argument", sl.Err(storageerrors.ErrInvalidArgument))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrInvalidArgument, err)
+		case codes.AlreadyExists:
+			log.Warn("User already exists", sl.Err(storageerrors.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrAlreadyExists, err)
+		default:
+			log.Error("Cannot insert user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
 		}
 	}
 
-	convertedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
+	insertedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
 	if err != nil {
 		log.Error("Error converting user", sl.Err(err))
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return convertedUser, nil
+	return insertedUser, nil
 }
 
-// Insert implements users.IUsersStorage.
-func (s *GRPCUsersStorage) Insert(ctx context.Context, userForInsert models.User) (models.User, error) {
-	const op = "storage.grpc.users.Insert"
+// VerifyCredentials implements users.IUsersStorage.
+func (s *GRPCUsersStorage) VerifyCredentials(ctx context.Context, login string, password string) (models.User, error) {
+	const op = "storage.grpc.users.VerifyCredentials"
 	log := s.Log.With(
 		"op", op,
 	)
@@ -137,32 +145,33 @@ func (s *GRPCUsersStorage) Insert(ctx context.Context, userForInsert models.User
 	}
 
 	c := umv1.NewUsersManagerClient(s.conn)
-	res, err := c.Insert(ctx, &umv1.InsertRequest{
-		User: umprofiles.UsrToProtoUsr(userForInsert),
+	res, err := c.VerifyCredentials(ctx, &umv1.VerifyCredentialsRequest{
+		Login:    login,
+		Password: password,
 	})
 	if err != nil {
-		if st, ok := status.FromError(err); ok {
-			switch st.Code() {
-			case codes.DeadlineExceeded:
-				log.Warn("Deadline exceeded", sl.Err(storageerrors.ErrDeadlineExceeded))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrDeadlineExceeded)
-			case codes.InvalidArgument:
-				log.Warn("Invalid argument", sl.Err(storageerrors.ErrInvalidArgument))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrInvalidArgument)
-			case codes.AlreadyExists:
-				log.Warn("User already exists", sl.Err(storageerrors.ErrAlreadyExists))
-				return models.User{}, fmt.Errorf("%s: %w", op, storageerrors.ErrAlreadyExists)
-			default:
-				log.Error("Cannot retrieve user by id", sl.Err(err))
-				return models.User{}, fmt.Errorf("%s; %w", op, err)
-			}
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.DeadlineExceeded:
+			log.Warn("Deadline exceeded", sl.Err(storageerrors.ErrDeadlineExceeded))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrDeadlineExceeded, err)
+		case codes.InvalidArgument:
+			log.Warn("Invalid argument", sl.Err(storageerrors.ErrInvalidArgument))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrInvalidArgument, err)
+		case codes.Unauthenticated:
+			log.Warn("Invalid credentials", sl.Err(storageerrors.ErrInvalidCredentials))
+			return models.User{}, fmt.Errorf("%s: %w: %w", op, storageerrors.ErrInvalidCredentials, err)
+		default:
+			log.Error("Cannot verify credentials", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
 		}
 	}
 
-	insertedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
+	verifiedUser, err := umprofiles.ProtoUsrToUsr(res.GetUser())
 	if err != nil {
 		log.Error("Error converting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return insertedUser, nil
+	return verifiedUser, nil
 }
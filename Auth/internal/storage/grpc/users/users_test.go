@@ -2,7 +2,6 @@ package grpcusers_test
 
 import (
 	"context"
-	"errors"
 	"testing"
 
 	"auth/internal/domain/models"
@@ -27,11 +26,6 @@ type MockUsersManagerClient struct {
 	mock.Mock
 }
 
-func (m *MockUsersManagerClient) GetUsers(ctx context.Context, in *umv1.GetUsersRequest, opts ...interface{}) (*umv1.GetUsersResponse, error) {
-	args := m.Called(ctx, in)
-	return args.Get(0).(*umv1.GetUsersResponse), args.Error(1)
-}
-
 func (m *MockUsersManagerClient) GetUserById(ctx context.Context, in *umv1.GetUserByIdRequest, opts ...interface{}) (*umv1.GetUserByIdResponse, error) {
 	args := m.Called(ctx, in)
 	return args.Get(0).(*umv1.GetUserByIdResponse), args.Error(1)
@@ -58,34 +52,6 @@ func NewMockGRPCUsersStorage(log *slog.Logger, client *MockUsersManagerClient) *
 
 // Override methods to use mockClient instead of real grpc client
 
-func (s *MockGRPCUsersStorage) GetUsers(ctx context.Context) ([]models.User, error) {
-	const op = "storage.grpc.users.GetUsers"
-	log := s.Log.With("op", op)
-
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
-	}
-
-	res, err := s.mockClient.GetUsers(ctx, &umv1.GetUsersRequest{})
-	if err != nil {
-		log.Error("Failed to get users", slog.String("error", err.Error()))
-		return nil, err
-	}
-
-	var resUsers []models.User
-	for _, pbUser := range res.GetUsers() {
-		u, err := umprofiles.ProtoUsrToUsr(pbUser)
-		if err != nil {
-			log.Warn("Error converting user", slog.String("error", err.Error()))
-			continue
-		}
-		resUsers = append(resUsers, u)
-	}
-	return resUsers, nil
-}
-
 func (s *MockGRPCUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	const op = "storage.grpc.users.GetUserById"
 	log := s.Log.With("op", op)
@@ -135,37 +101,6 @@ func (s *MockGRPCUsersStorage) Insert(ctx context.Context, userForInsert models.
 
 // --- Tests ---
 
-func TestGetUsers_Success(t *testing.T) {
-	log := logger.SetupLogger("local")
-	mockClient := new(MockUsersManagerClient)
-	storage := NewMockGRPCUsersStorage(log, mockClient)
-
-	users := []*umv1.User{
-		{Id: uuid.New().String(), Login: "user1"},
-		{Id: uuid.New().String(), Login: "user2"},
-	}
-	mockClient.On("GetUsers", mock.Anything, &umv1.GetUsersRequest{}).
-		Return(&umv1.GetUsersResponse{Users: users}, nil)
-
-	got, err := storage.GetUsers(context.Background())
-	assert.NoError(t, err)
-	assert.Len(t, got, 2)
-	mockClient.AssertExpectations(t)
-}
-
-func TestGetUsers_Error(t *testing.T) {
-	log := logger.SetupLogger("local")
-	mockClient := new(MockUsersManagerClient)
-	storage := NewMockGRPCUsersStorage(log, mockClient)
-
-	mockClient.On("GetUsers", mock.Anything, &umv1.GetUsersRequest{}).
-		Return((*umv1.GetUsersResponse)(nil), errors.New("some grpc error"))
-
-	_, err := storage.GetUsers(context.Background())
-	assert.Error(t, err)
-	mockClient.AssertExpectations(t)
-}
-
 func TestGetUserById_Success(t *testing.T) {
 	log := logger.SetupLogger("local")
 	mockClient := new(MockUsersManagerClient)
@@ -0,0 +1,166 @@
+package memorytokens
+
+import (
+	"auth/internal/domain/models"
+	storageerrors "auth/internal/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokensMemoryStorage keeps refresh token families and the access token
+// denylist in process memory. State is lost on restart, which logs every
+// user out and forgets revoked access tokens, so it is only a fallback for
+// the redis storage.
+type TokensMemoryStorage struct {
+	log      *slog.Logger
+	mu       sync.Mutex
+	families map[uuid.UUID]models.TokenFamily
+	revoked  map[uuid.UUID]time.Time
+}
+
+func New(log *slog.Logger) *TokensMemoryStorage {
+	return &TokensMemoryStorage{
+		log:      log,
+		families: make(map[uuid.UUID]models.TokenFamily),
+		revoked:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+// SaveFamily implements authservice.ITokensStorage.
+func (t *TokensMemoryStorage) SaveFamily(ctx context.Context, family models.TokenFamily) error {
+	const op = "storage.memory.tokens.SaveFamily"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(time.Now())
+	t.families[family.Id] = family
+
+	return nil
+}
+
+// RotateFamily implements authservice.ITokensStorage.
+func (t *TokensMemoryStorage) RotateFamily(ctx context.Context, familyId uuid.UUID, usedId uuid.UUID, nextId uuid.UUID, expiresAt time.Time) error {
+	const op = "storage.memory.tokens.RotateFamily"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	family, ok := t.families[familyId]
+	if !ok || time.Now().After(family.ExpiresAt) {
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrNotFound)
+	}
+
+	if family.Revoked {
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrTokenRevoked)
+	}
+
+	if family.CurrentId != usedId {
+		family.Revoked = true
+		t.families[familyId] = family
+
+		log.Warn("Refresh token reused, family revoked", slog.String("family", familyId.String()))
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrTokenReused)
+	}
+
+	family.CurrentId = nextId
+	family.ExpiresAt = expiresAt
+	t.families[familyId] = family
+
+	return nil
+}
+
+// RevokeFamily implements authservice.ITokensStorage.
+func (t *TokensMemoryStorage) RevokeFamily(ctx context.Context, familyId uuid.UUID) error {
+	const op = "storage.memory.tokens.RevokeFamily"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	family, ok := t.families[familyId]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrNotFound)
+	}
+
+	family.Revoked = true
+	t.families[familyId] = family
+
+	return nil
+}
+
+// RevokeToken implements authservice.ITokensStorage.
+func (t *TokensMemoryStorage) RevokeToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error {
+	const op = "storage.memory.tokens.RevokeToken"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(time.Now())
+	t.revoked[jti] = expiresAt
+
+	return nil
+}
+
+// IsTokenRevoked implements authservice.ITokensStorage.
+func (t *TokensMemoryStorage) IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	const op = "storage.memory.tokens.IsTokenRevoked"
+
+	select {
+	case <-ctx.Done():
+		return false, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expiresAt, ok := t.revoked[jti]
+
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// sweep drops expired entries, callers must hold mu.
+func (t *TokensMemoryStorage) sweep(now time.Time) {
+	for id, family := range t.families {
+		if now.After(family.ExpiresAt) {
+			delete(t.families, id)
+		}
+	}
+	for jti, expiresAt := range t.revoked {
+		if now.After(expiresAt) {
+			delete(t.revoked, jti)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package memorytokens_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth/internal/domain/models"
+	storageerrors "auth/internal/storage"
+	memorytokens "auth/internal/storage/memory/tokens"
+	"auth/pkg/lib/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFamily(t *testing.T, storage *memorytokens.TokensMemoryStorage, ttl time.Duration) models.TokenFamily {
+	family := models.TokenFamily{
+		Id:        uuid.New(),
+		UserId:    uuid.New(),
+		CurrentId: uuid.New(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	assert.NoError(t, storage.SaveFamily(context.Background(), family))
+
+	return family
+}
+
+func TestRotateFamily_Success(t *testing.T) {
+	storage := memorytokens.New(logger.SetupLogger("local"))
+	family := newFamily(t, storage, time.Hour)
+
+	next := uuid.New()
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, next, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	err = storage.RotateFamily(context.Background(), family.Id, next, uuid.New(), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestRotateFamily_ReuseRevokes(t *testing.T) {
+	storage := memorytokens.New(logger.SetupLogger("local"))
+	family := newFamily(t, storage, time.Hour)
+
+	next := uuid.New()
+	assert.NoError(t, storage.RotateFamily(context.Background(), family.Id, family.CurrentId, next, time.Now().Add(time.Hour)))
+
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenReused)
+
+	err = storage.RotateFamily(context.Background(), family.Id, next, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenRevoked)
+}
+
+func TestRotateFamily_NotFound(t *testing.T) {
+	storage := memorytokens.New(logger.SetupLogger("local"))
+	expired := newFamily(t, storage, -time.Second)
+
+	tests := []struct {
+		name     string
+		familyId uuid.UUID
+		usedId   uuid.UUID
+	}{
+		{"Unknown", uuid.New(), uuid.New()},
+		{"Expired", expired.Id, expired.CurrentId},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.RotateFamily(context.Background(), tt.familyId, tt.usedId, uuid.New(), time.Now().Add(time.Hour))
+			assert.ErrorIs(t, err, storageerrors.ErrNotFound)
+		})
+	}
+}
+
+func TestRevokeFamily(t *testing.T) {
+	storage := memorytokens.New(logger.SetupLogger("local"))
+	family := newFamily(t, storage, time.Hour)
+
+	assert.NoError(t, storage.RevokeFamily(context.Background(), family.Id))
+
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenRevoked)
+
+	err = storage.RevokeFamily(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, storageerrors.ErrNotFound)
+}
+
+func TestRevokeToken(t *testing.T) {
+	storage := memorytokens.New(logger.SetupLogger("local"))
+	jti, expired := uuid.New(), uuid.New()
+
+	assert.NoError(t, storage.RevokeToken(context.Background(), jti, time.Now().Add(time.Hour)))
+	assert.NoError(t, storage.RevokeToken(context.Background(), expired, time.Now().Add(-time.Second)))
+
+	revoked, err := storage.IsTokenRevoked(context.Background(), jti)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = storage.IsTokenRevoked(context.Background(), expired)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = storage.IsTokenRevoked(context.Background(), uuid.New())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
@@ -0,0 +1,229 @@
+package redistokens
+
+import (
+	"auth/internal/domain/models"
+	storageerrors "auth/internal/storage"
+	"auth/pkg/lib/logger/sl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	familyKeyPrefix  = "auth:family:"
+	revokedKeyPrefix = "auth:revoked:"
+)
+
+// rotateScript swaps the current refresh token id of a family in one step,
+// so two concurrent refreshes with the same token cannot both succeed.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'current')
+if not current then
+	return 'missing'
+end
+if redis.call('HGET', KEYS[1], 'revoked') == '1' then
+	return 'revoked'
+end
+if current ~= ARGV[1] then
+	redis.call('HSET', KEYS[1], 'revoked', '1')
+	return 'reused'
+end
+redis.call('HSET', KEYS[1], 'current', ARGV[2])
+redis.call('PEXPIREAT', KEYS[1], ARGV[3])
+return 'ok'
+`)
+
+var revokeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'revoked', '1')
+return 1
+`)
+
+type TokensRedisStorage struct {
+	log *slog.Logger
+	rds *redis.Client
+}
+
+func New(log *slog.Logger, host string, port int, password string) (*TokensRedisStorage, error) {
+	rds := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+		DB:       0,
+	})
+
+	if err := rds.Ping(context.Background()).Err(); err != nil {
+		rds.Close()
+		return nil, err
+	}
+
+	return &TokensRedisStorage{
+		log: log,
+		rds: rds,
+	}, nil
+}
+
+func (t *TokensRedisStorage) Close() {
+	if err := t.rds.Close(); err != nil {
+		t.log.Warn("Cannot close redis connection", sl.Err(err))
+	}
+}
+
+// SaveFamily implements authservice.ITokensStorage.
+func (t *TokensRedisStorage) SaveFamily(ctx context.Context, family models.TokenFamily) error {
+	const op = "storage.redis.tokens.SaveFamily"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	key := familyKeyPrefix + family.Id.String()
+	revoked := "0"
+	if family.Revoked {
+		revoked = "1"
+	}
+
+	_, err := t.rds.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"user", family.UserId.String(),
+			"current", family.CurrentId.String(),
+			"revoked", revoked,
+		)
+		pipe.ExpireAt(ctx, key, family.ExpiresAt)
+		return nil
+	})
+	if err != nil {
+		log.Error("Cannot save token family", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RotateFamily implements authservice.ITokensStorage.
+func (t *TokensRedisStorage) RotateFamily(ctx context.Context, familyId uuid.UUID, usedId uuid.UUID, nextId uuid.UUID, expiresAt time.Time) error {
+	const op = "storage.redis.tokens.RotateFamily"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	res, err := rotateScript.Run(ctx, t.rds,
+		[]string{familyKeyPrefix + familyId.String()},
+		usedId.String(), nextId.String(), expiresAt.UnixMilli(),
+	).Text()
+	if err != nil {
+		log.Error("Cannot rotate token family", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch res {
+	case "ok":
+		return nil
+	case "missing":
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrNotFound)
+	case "revoked":
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrTokenRevoked)
+	case "reused":
+		log.Warn("Refresh token reused, family revoked", slog.String("family", familyId.String()))
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrTokenReused)
+	default:
+		return fmt.Errorf("%s: unexpected script result %q", op, res)
+	}
+}
+
+// RevokeFamily implements authservice.ITokensStorage.
+func (t *TokensRedisStorage) RevokeFamily(ctx context.Context, familyId uuid.UUID) error {
+	const op = "storage.redis.tokens.RevokeFamily"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	found, err := revokeScript.Run(ctx, t.rds, []string{familyKeyPrefix + familyId.String()}).Int()
+	if err != nil {
+		log.Error("Cannot revoke token family", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if found == 0 {
+		return fmt.Errorf("%s: %w", op, storageerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// RevokeToken implements authservice.ITokensStorage.
+func (t *TokensRedisStorage) RevokeToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error {
+	const op = "storage.redis.tokens.RevokeToken"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	// an expired token is rejected anyway
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	err := t.rds.SetArgs(ctx, revokedKeyPrefix+jti.String(), "1", redis.SetArgs{
+		ExpireAt: expiresAt,
+	}).Err()
+	if err != nil {
+		log.Error("Cannot revoke token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// IsTokenRevoked implements authservice.ITokensStorage.
+func (t *TokensRedisStorage) IsTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	const op = "storage.redis.tokens.IsTokenRevoked"
+	log := t.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return false, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	err := t.rds.Get(ctx, revokedKeyPrefix+jti.String()).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		log.Error("Cannot check token", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
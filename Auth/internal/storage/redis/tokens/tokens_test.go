@@ -0,0 +1,114 @@
+package redistokens_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"auth/internal/domain/models"
+	storageerrors "auth/internal/storage"
+	redistokens "auth/internal/storage/redis/tokens"
+	"auth/pkg/lib/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) (*redistokens.TokensRedisStorage, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	storage, err := redistokens.New(logger.SetupLogger("local"), mr.Host(), mustPort(t, mr), "")
+	require.NoError(t, err)
+	t.Cleanup(storage.Close)
+
+	return storage, mr
+}
+
+func mustPort(t *testing.T, mr *miniredis.Miniredis) int {
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+
+	return port
+}
+
+func newFamily(t *testing.T, storage *redistokens.TokensRedisStorage) models.TokenFamily {
+	family := models.TokenFamily{
+		Id:        uuid.New(),
+		UserId:    uuid.New(),
+		CurrentId: uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, storage.SaveFamily(context.Background(), family))
+
+	return family
+}
+
+func TestNew_Unavailable(t *testing.T) {
+	_, err := redistokens.New(logger.SetupLogger("local"), "127.0.0.1", 1, "")
+	assert.Error(t, err)
+}
+
+func TestRotateFamily(t *testing.T) {
+	storage, _ := newTestStorage(t)
+	family := newFamily(t, storage)
+
+	next := uuid.New()
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, next, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	err = storage.RotateFamily(context.Background(), family.Id, family.CurrentId, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenReused)
+
+	err = storage.RotateFamily(context.Background(), family.Id, next, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenRevoked)
+
+	err = storage.RotateFamily(context.Background(), uuid.New(), uuid.New(), uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrNotFound)
+}
+
+func TestSaveFamily_Expires(t *testing.T) {
+	storage, mr := newTestStorage(t)
+	family := newFamily(t, storage)
+
+	mr.FastForward(2 * time.Hour)
+
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrNotFound)
+}
+
+func TestRevokeFamily(t *testing.T) {
+	storage, _ := newTestStorage(t)
+	family := newFamily(t, storage)
+
+	assert.NoError(t, storage.RevokeFamily(context.Background(), family.Id))
+
+	err := storage.RotateFamily(context.Background(), family.Id, family.CurrentId, uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storageerrors.ErrTokenRevoked)
+
+	err = storage.RevokeFamily(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, storageerrors.ErrNotFound)
+}
+
+func TestRevokeToken(t *testing.T) {
+	storage, mr := newTestStorage(t)
+	jti := uuid.New()
+
+	assert.NoError(t, storage.RevokeToken(context.Background(), jti, time.Now().Add(time.Minute)))
+
+	revoked, err := storage.IsTokenRevoked(context.Background(), jti)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = storage.IsTokenRevoked(context.Background(), uuid.New())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	mr.FastForward(2 * time.Minute)
+
+	revoked, err = storage.IsTokenRevoked(context.Background(), jti)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
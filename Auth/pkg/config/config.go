@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -15,6 +16,22 @@ type Config struct {
 	Port             int    `yaml:"port" env:"PORT" env-default:"50051"`
 	GrpcUsersAPIHost string `yaml:"grpc_users_api_host" env:"GRPC_USERS_API_HOST" env-default:"usersservice"`
 	GrpcUsersAPIPort int    `yaml:"grpc_users_api_port" env:"GRPC_USERS_API_PORT" env-default:"50051"`
+
+	// empty RedisHost keeps token families and the denylist in memory
+	RedisHost     string `yaml:"redis_host" env:"REDIS_HOST" env-default:""`
+	RedisPort     int    `yaml:"redis_port" env:"REDIS_PORT" env-default:"6379"`
+	RedisPassword string `yaml:"redis_password" env:"REDIS_PASSWORD" json:"-"`
+
+	JWTIssuer       string        `yaml:"jwt_issuer" env:"JWT_ISSUER" env-default:"auth"`
+	JWTAudience     string        `yaml:"jwt_audience" env:"JWT_AUDIENCE" env-default:"users-connector"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" env-default:"168h"`
+	// kid of the key new tokens are signed with, other keys only verify
+	JWTSigningKeyId string `yaml:"jwt_signing_key_id" env:"JWT_SIGNING_KEY_ID" env-default:"default"`
+	// directory with <kid>.pem (RS256, ES256, EdDSA) and <kid>.secret (HS256) files
+	JWTKeysDir string `yaml:"jwt_keys_dir" env:"JWT_KEYS_DIR"`
+	// HS256 secret registered under JWTSigningKeyId
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" json:"-"`
 }
 
 func MustLoad() *Config {
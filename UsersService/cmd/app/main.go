@@ -1,11 +1,18 @@
 package main
 
 import (
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"usersservice/internal/app"
+	"usersservice/internal/lib/migrator"
+	"usersservice/internal/lib/password"
+	usersmemorystorage "usersservice/internal/storage/memory/users"
+	usersmongostorage "usersservice/internal/storage/mongo/users"
 	userspsqlstorage "usersservice/internal/storage/psql/users"
+	userssqlitestorage "usersservice/internal/storage/sqlite/users"
 	"usersservice/pkg/config"
 	"usersservice/pkg/lib/logger"
 )
@@ -15,22 +22,76 @@ func main() {
 
 	log := logger.SetupLogger(cfg.Env)
 
-	// storage := usersstorage.New(log, cfg.MongoDBHost, cfg.MongoDBPort, cfg.MongoDBDBName, cfg.MongoDBUsersCollection)
-	storage := userspsqlstorage.New(log, cfg.PsqlConnStr, cfg.PsqlUsersTableName)
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(cfg, log, args))
+	}
 
-	application := app.New(log, cfg.Port, storage)
+	storage := mustOpenStorage(cfg, log)
+	log.Info("Storage opened", slog.String("driver", cfg.StorageDriver))
+
+	hasher := password.MustNew(password.Params{
+		Algorithm:     cfg.PasswordHashAlgorithm,
+		BcryptCost:    cfg.BcryptCost,
+		Argon2Time:    cfg.Argon2Time,
+		Argon2Memory:  cfg.Argon2Memory,
+		Argon2Threads: cfg.Argon2Threads,
+		Pepper:        cfg.PasswordPepper,
+	})
+
+	application := app.New(log, cfg.Port, storage, hasher, cfg.PurgeInterval, cfg.DeletedRetention)
 	go func() {
 		application.GRPCServer.MustRun()
 	}()
+	go application.Purger.Run()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
 	<-stop
 
+	application.Purger.Stop()
+	log.Info("Purger stopped")
+
 	storage.Close()
-	log.Info("Database connection closed")
+	log.Info("Storage closed")
 
 	application.GRPCServer.Stop()
 	log.Info("application stoped")
 }
+
+type usersStorage interface {
+	app.IUsersStorage
+	Close()
+}
+
+func mustOpenStorage(cfg *config.Config, log *slog.Logger) usersStorage {
+	switch cfg.StorageDriver {
+	case "postgres":
+		storage := userspsqlstorage.New(log, cfg.PsqlConnStr, cfg.PsqlUsersTableName)
+		if cfg.AutoMigrate {
+			mustMigrate(log, storage.DB, migrator.DriverPostgres)
+		}
+		return storage
+	case "mongo":
+		return usersmongostorage.New(log, usersmongostorage.Config{
+			URI:            cfg.MongoDBURI,
+			Host:           cfg.MongoDBHost,
+			Port:           cfg.MongoDBPort,
+			Username:       cfg.MongoDBUsername,
+			Password:       cfg.MongoDBPassword,
+			AuthSource:     cfg.MongoDBAuthSource,
+			DatabaseName:   cfg.MongoDBDBName,
+			CollectionName: cfg.MongoDBUsersCollection,
+		})
+	case "sqlite":
+		storage := userssqlitestorage.New(log, cfg.SqlitePath)
+		if cfg.AutoMigrate {
+			mustMigrate(log, storage.DB, migrator.DriverSqlite)
+		}
+		return storage
+	case "memory":
+		return usersmemorystorage.New(log)
+	default:
+		panic("unknown storage driver: " + cfg.StorageDriver)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"usersservice/internal/lib/migrator"
+	userssqlitestorage "usersservice/internal/storage/sqlite/users"
+	"usersservice/pkg/config"
+	"usersservice/pkg/lib/logger/sl"
+)
+
+const usage = "usage: cli [--config=path] migrate up|down|status|redo"
+
+// runCommand executes a subcommand given after the flags and returns the
+// process exit code.
+func runCommand(cfg *config.Config, log *slog.Logger, args []string) int {
+	if len(args) != 2 || args[0] != "migrate" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	db, driver, err := openMigrationDB(cfg)
+	if err != nil {
+		log.Error("Cannot open database", sl.Err(err))
+		return 1
+	}
+	defer db.Close()
+
+	m, err := migrator.New(log, db, driver)
+	if err != nil {
+		log.Error("Cannot create migrator", sl.Err(err))
+		return 1
+	}
+
+	if err := m.Run(context.Background(), args[1], os.Stdout); err != nil {
+		if errors.Is(err, migrator.ErrUnknownCommand) {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+
+		log.Error("Migration failed", sl.Err(err))
+		return 1
+	}
+
+	return 0
+}
+
+func openMigrationDB(cfg *config.Config) (*sql.DB, string, error) {
+	switch cfg.StorageDriver {
+	case "postgres":
+		db, err := sql.Open("postgres", cfg.PsqlConnStr)
+		return db, migrator.DriverPostgres, err
+	case "sqlite":
+		db, err := sql.Open("sqlite", userssqlitestorage.DSN(cfg.SqlitePath))
+		return db, migrator.DriverSqlite, err
+	default:
+		return nil, "", fmt.Errorf("storage driver %q has no migrations", cfg.StorageDriver)
+	}
+}
+
+// mustMigrate applies pending migrations on startup when AUTO_MIGRATE is set.
+func mustMigrate(log *slog.Logger, db *sql.DB, driver string) {
+	m, err := migrator.New(log, db, driver)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := m.Up(context.Background()); err != nil {
+		panic(err)
+	}
+}
@@ -3,8 +3,11 @@ package app
 import (
 	"context"
 	"log/slog"
+	"time"
 	grpcapp "usersservice/internal/app/grpc"
+	purgerapp "usersservice/internal/app/purger"
 	"usersservice/internal/domain/models"
+	"usersservice/internal/lib/password"
 	usersservice "usersservice/internal/service/users"
 
 	"github.com/google/uuid"
@@ -12,21 +15,31 @@ import (
 
 type App struct {
 	GRPCServer *grpcapp.App
+	Purger     *purgerapp.App
 }
 
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
-	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
+	Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error
+	SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error
+	GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error)
 }
 
-func New(log *slog.Logger, port int, storage IUsersStorage) *App {
-	usersService := usersservice.New(log, storage)
+func New(log *slog.Logger, port int, storage IUsersStorage, hasher *password.Hasher, purgeInterval time.Duration, deletedRetention time.Duration) *App {
+	usersService := usersservice.MustNew(log, storage, storage, hasher)
 	grpcapp := grpcapp.New(log, usersService, port)
+	purger := purgerapp.New(log, usersService, purgeInterval, deletedRetention)
 
 	return &App{
 		GRPCServer: grpcapp,
+		Purger:     purger,
 	}
 }
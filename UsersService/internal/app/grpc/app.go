@@ -19,11 +19,16 @@ type App struct {
 }
 
 type IUsersService interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
 	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID) (models.User, error)
+	VerifyCredentials(ctx context.Context, login string, password string) (models.User, error)
+	ListUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error)
 }
 
 func New(log *slog.Logger, usersService IUsersService, port int) *App {
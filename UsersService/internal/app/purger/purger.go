@@ -0,0 +1,100 @@
+package purgerapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"usersservice/pkg/lib/logger/sl"
+)
+
+type IUsersPurger interface {
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// App periodically removes users that stayed soft-deleted longer than the
+// retention. Every replica may run it, the deletes are idempotent.
+type App struct {
+	log       *slog.Logger
+	purger    IUsersPurger
+	interval  time.Duration
+	retention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(log *slog.Logger, purger IUsersPurger, interval time.Duration, retention time.Duration) *App {
+	return &App{
+		log:       log,
+		purger:    purger,
+		interval:  interval,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Run purges once right away and then every interval until Stop is called.
+// A non-positive interval or retention disables purging.
+func (a *App) Run() {
+	const op = "purgerapp.Run"
+	log := a.log.With(
+		"op", op,
+	)
+
+	defer close(a.done)
+
+	if a.interval <= 0 || a.retention <= 0 {
+		log.Info("Purging of deleted users is disabled")
+		return
+	}
+
+	log.Info("Starting purger",
+		slog.Duration("interval", a.interval),
+		slog.Duration("retention", a.retention),
+	)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.purge()
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) purge() {
+	const op = "purgerapp.purge"
+	log := a.log.With(
+		"op", op,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	purged, err := a.purger.PurgeDeleted(ctx, a.retention)
+	if err != nil {
+		log.Error("Cannot purge deleted users", sl.Err(err))
+		return
+	}
+
+	if purged > 0 {
+		log.Info("Deleted users purged", slog.Int64("count", purged))
+	}
+}
+
+// Stop waits for a running purge to finish.
+func (a *App) Stop() {
+	const op = "purgerapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stoping purger")
+
+	close(a.stop)
+	<-a.done
+}
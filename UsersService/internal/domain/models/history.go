@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operations recorded in the user history.
+const (
+	OperationInsert  = "insert"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	OperationPurge   = "purge"
+)
+
+// FieldChange is one field of a user before and after an operation, empty
+// values stand for unset ones.
+type FieldChange struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before" bson:"before"`
+	After  string `json:"after" bson:"after"`
+}
+
+// HistoryEntry records one write of a user. Ids are UUIDv7, so they sort
+// in the order the entries were written.
+type HistoryEntry struct {
+	Id        uuid.UUID     `json:"id" bson:"id"`
+	UserId    uuid.UUID     `json:"user_id" bson:"user_id"`
+	Actor     string        `json:"actor" bson:"actor"`
+	Operation string        `json:"operation" bson:"operation"`
+	ChangedAt time.Time     `json:"changed_at" bson:"changed_at"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+}
+
+// HistoryPageRequest is what clients send: the cursor is an opaque token.
+type HistoryPageRequest struct {
+	UserId   uuid.UUID
+	PageSize int
+	Cursor   string
+}
+
+// HistoryQuery is what storages receive, entries come newest first and
+// strictly before After unless it is uuid.Nil.
+type HistoryQuery struct {
+	UserId uuid.UUID
+	After  uuid.UUID
+	Limit  int
+}
+
+type HistoryPage struct {
+	Entries    []HistoryEntry
+	NextCursor string
+}
+
+// Audit builds the history entry of a write from the user before and after
+// it. Storages call it inside the write and keep the entry only if the
+// write is kept, a nil Audit records nothing.
+type Audit func(before User, after User) HistoryEntry
@@ -0,0 +1,48 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	SortById    = "id"
+	SortByLogin = "login"
+)
+
+type UsersFilter struct {
+	Role        string `json:"role,omitempty"`
+	LoginPrefix string `json:"login_prefix,omitempty"`
+}
+
+type UsersSort struct {
+	By   string
+	Desc bool
+}
+
+// UsersPageRequest is what clients send: the cursor is an opaque token.
+type UsersPageRequest struct {
+	Filter   UsersFilter
+	Sort     UsersSort
+	PageSize int
+	Cursor   string
+}
+
+// UsersCursor is the keyset position of the last user on the previous page.
+// The sort and the filter it was issued for must not change between pages.
+type UsersCursor struct {
+	Id     uuid.UUID   `json:"id"`
+	Login  string      `json:"login,omitempty"`
+	Sort   UsersSort   `json:"sort"`
+	Filter UsersFilter `json:"filter"`
+}
+
+// UsersQuery is what storages receive.
+type UsersQuery struct {
+	Filter UsersFilter
+	Sort   UsersSort
+	After  *UsersCursor
+	Limit  int
+}
+
+type UsersPage struct {
+	Users      []User
+	NextCursor string
+}
@@ -1,10 +1,82 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
-	Id       uuid.UUID `json:"id,omitempty"`
-	Login    string    `json:"login"`
-	Password string    `json:"password"`
-	Role     string    `json:"role"`
+	Id          uuid.UUID `json:"id,omitempty" bson:"id"`
+	Login       string    `json:"login" bson:"login"`
+	Password    string    `json:"password" bson:"password"`
+	Role        string    `json:"role" bson:"role"`
+	Email       string    `json:"email" bson:"email"`
+	DisplayName string    `json:"display_name" bson:"display_name"`
+	Locale      string    `json:"locale" bson:"locale"`
+	Timezone    string    `json:"timezone" bson:"timezone"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+	// LastLoginAt is zero until the first successful login.
+	LastLoginAt time.Time `json:"last_login_at" bson:"last_login_at,omitempty"`
+	// DeletedAt is set while the user is soft-deleted.
+	DeletedAt time.Time `json:"deleted_at" bson:"deleted_at,omitempty"`
+	// Version starts at 1 and grows with every change except logins.
+	Version int64 `json:"version" bson:"version"`
+}
+
+// Fields of User that Update can change, named as in the API and the
+// storages.
+const (
+	FieldLogin       = "login"
+	FieldPassword    = "password"
+	FieldRole        = "role"
+	FieldEmail       = "email"
+	FieldDisplayName = "display_name"
+	FieldLocale      = "locale"
+	FieldTimezone    = "timezone"
+)
+
+// UpdatableFields lists every field Update can change.
+var UpdatableFields = []string{
+	FieldLogin, FieldPassword, FieldRole, FieldEmail, FieldDisplayName, FieldLocale, FieldTimezone,
+}
+
+// FieldValue returns the value of one of UpdatableFields.
+func (u User) FieldValue(field string) string {
+	if f := u.field(field); f != nil {
+		return *f
+	}
+
+	return ""
+}
+
+// CopyFields sets the given UpdatableFields of u to their values in src.
+func (u *User) CopyFields(src User, fields []string) {
+	for _, field := range fields {
+		if f := u.field(field); f != nil {
+			*f = src.FieldValue(field)
+		}
+	}
+}
+
+func (u *User) field(field string) *string {
+	switch field {
+	case FieldLogin:
+		return &u.Login
+	case FieldPassword:
+		return &u.Password
+	case FieldRole:
+		return &u.Role
+	case FieldEmail:
+		return &u.Email
+	case FieldDisplayName:
+		return &u.DisplayName
+	case FieldLocale:
+		return &u.Locale
+	case FieldTimezone:
+		return &u.Timezone
+	}
+
+	return nil
 }
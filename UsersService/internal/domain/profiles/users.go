@@ -1,31 +1,112 @@
 package profiles
 
 import (
+	"time"
 	"usersservice/internal/domain/models"
 
 	umv1 "github.com/chas3air/protos/gen/go/usersManager"
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// UsrToProtoUsr never copies the password: hashes must not leave the service.
 func UsrToProtoUsr(user models.User) *umv1.User {
 	return &umv1.User{
-		Id:       user.Id.String(),
-		Login:    user.Login,
-		Password: user.Password,
-		Role:     user.Role,
+		Id:          user.Id.String(),
+		Login:       user.Login,
+		Role:        user.Role,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		CreatedAt:   timeToProto(user.CreatedAt),
+		UpdatedAt:   timeToProto(user.UpdatedAt),
+		LastLoginAt: timeToProto(user.LastLoginAt),
+		DeletedAt:   timeToProto(user.DeletedAt),
+		Version:     user.Version,
 	}
 }
 
+// ProtoUsrToUsr ignores deleted_at and version, the storages maintain them.
 func ProtoUsrToUsr(proto_usr *umv1.User) (models.User, error) {
-	parsedUUID, err := uuid.Parse(proto_usr.GetId())
+	parsedUUID, err := protoToId(proto_usr.GetId())
 	if err != nil {
 		return models.User{}, err
 	}
 
 	return models.User{
-		Id:       parsedUUID,
-		Login:    proto_usr.GetLogin(),
-		Password: proto_usr.GetPassword(),
-		Role:     proto_usr.GetRole(),
+		Id:          parsedUUID,
+		Login:       proto_usr.GetLogin(),
+		Password:    proto_usr.GetPassword(),
+		Role:        proto_usr.GetRole(),
+		Email:       proto_usr.GetEmail(),
+		DisplayName: proto_usr.GetDisplayName(),
+		Locale:      proto_usr.GetLocale(),
+		Timezone:    proto_usr.GetTimezone(),
+		CreatedAt:   protoToTime(proto_usr.GetCreatedAt()),
+		UpdatedAt:   protoToTime(proto_usr.GetUpdatedAt()),
+		LastLoginAt: protoToTime(proto_usr.GetLastLoginAt()),
 	}, nil
 }
+
+func ProtoReqToPageReq(req *umv1.GetUsersRequest) models.UsersPageRequest {
+	return models.UsersPageRequest{
+		Filter: models.UsersFilter{
+			Role:        req.GetRole(),
+			LoginPrefix: req.GetLoginPrefix(),
+		},
+		Sort: models.UsersSort{
+			By:   req.GetSortBy(),
+			Desc: req.GetDescending(),
+		},
+		PageSize: int(req.GetPageSize()),
+		Cursor:   req.GetCursor(),
+	}
+}
+
+func HistoryEntryToProto(entry models.HistoryEntry) *umv1.UserHistoryEntry {
+	changes := make([]*umv1.UserFieldChange, 0, len(entry.Changes))
+	for _, change := range entry.Changes {
+		changes = append(changes, &umv1.UserFieldChange{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+
+	return &umv1.UserHistoryEntry{
+		Id:        entry.Id.String(),
+		UserId:    entry.UserId.String(),
+		Actor:     entry.Actor,
+		Operation: entry.Operation,
+		ChangedAt: timeToProto(entry.ChangedAt),
+		Changes:   changes,
+	}
+}
+
+// protoToId reads an optional id, an empty string stands for uuid.Nil.
+func protoToId(id string) (uuid.UUID, error) {
+	if id == "" {
+		return uuid.Nil, nil
+	}
+
+	return uuid.Parse(id)
+}
+
+// timeToProto leaves unset timestamps out of the message.
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+// protoToTime maps a missing timestamp to the zero time.
+func protoToTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
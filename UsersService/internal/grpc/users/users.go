@@ -4,24 +4,29 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"time"
 	"usersservice/internal/domain/models"
 	"usersservice/internal/domain/profiles"
 	serviceerror "usersservice/internal/service"
 	"usersservice/pkg/lib/logger/sl"
 
+	"github.com/chas3air/protos/apierror"
 	umv1 "github.com/chas3air/protos/gen/go/usersManager"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type IUsersService interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
 	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID) (models.User, error)
+	VerifyCredentials(ctx context.Context, login string, password string) (models.User, error)
+	ListUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error)
 }
 
 type ServerAPI struct {
@@ -50,24 +55,30 @@ func (s *ServerAPI) GetUsers(ctx context.Context, req *umv1.GetUsersRequest) (*u
 	select {
 	case <-ctx.Done():
 		log.Error("Request time out")
-		return nil, status.Error(codes.DeadlineExceeded, "request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
 	default:
 	}
 
-	users, err := s.Service.GetUsers(ctx)
+	page, err := s.Service.GetUsers(ctx, profiles.ProtoReqToPageReq(req))
 	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			return nil, invalidArgument(err, "invalid page request")
+		}
+
 		log.Error("Error fetching users", sl.Err(err))
-		return nil, status.Error(codes.Internal, "error fetching users")
+		return nil, apierror.New(apierror.Internal, "error fetching users")
 	}
 
-	var responseUsers = make([]*umv1.User, 0, len(users))
-	for _, user := range users {
+	var responseUsers = make([]*umv1.User, 0, len(page.Users))
+	for _, user := range page.Users {
 		profiledUser := profiles.UsrToProtoUsr(user)
 		responseUsers = append(responseUsers, profiledUser)
 	}
 
 	return &umv1.GetUsersResponse{
-		Users: responseUsers,
+		Users:      responseUsers,
+		NextCursor: page.NextCursor,
 	}, nil
 }
 
@@ -81,25 +92,25 @@ func (s *ServerAPI) GetUserById(ctx context.Context, req *umv1.GetUserByIdReques
 	select {
 	case <-ctx.Done():
 		log.Error("Request time out")
-		return nil, status.Error(codes.DeadlineExceeded, "request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
 	default:
 	}
 
 	uid, err := uuid.Parse(req.GetId())
 	if err != nil {
 		log.Error("Cannot parse request uid", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "invalid uid")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
 	}
 
 	user, err := s.Service.GetUserById(ctx, uid)
 	if err != nil {
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, apierror.New(apierror.UserNotFound, "user not found")
 		}
 
 		log.Error("Error fetching user by id", sl.Err(err))
-		return nil, status.Error(codes.Internal, "error fetching user by id")
+		return nil, apierror.New(apierror.Internal, "error fetching user by id")
 	}
 
 	return &umv1.GetUserByIdResponse{
@@ -107,6 +118,41 @@ func (s *ServerAPI) GetUserById(ctx context.Context, req *umv1.GetUserByIdReques
 	}, nil
 }
 
+// GetUserByLogin implements umv1.UsersManagerServer.
+func (s *ServerAPI) GetUserByLogin(ctx context.Context, req *umv1.GetUserByLoginRequest) (*umv1.GetUserByLoginResponse, error) {
+	const op = "grpc.users.GetUserByLogin"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Error("Request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
+	default:
+	}
+
+	if req.GetLogin() == "" {
+		log.Error("Login is required")
+		return nil, apierror.New(apierror.ValidationFailed, "invalid request", apierror.FieldViolation{Field: "login", Description: "required"})
+	}
+
+	user, err := s.Service.GetUserByLogin(ctx, req.GetLogin())
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
+			return nil, apierror.New(apierror.UserNotFound, "user not found")
+		}
+
+		log.Error("Error fetching user by login", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "error fetching user by login")
+	}
+
+	return &umv1.GetUserByLoginResponse{
+		User: profiles.UsrToProtoUsr(user),
+	}, nil
+}
+
 // Insert implements umv1.UsersManagerServer.
 func (s *ServerAPI) Insert(ctx context.Context, req *umv1.InsertRequest) (*umv1.InsertResponse, error) {
 	const op = "grpc.users.Insert"
@@ -117,25 +163,41 @@ func (s *ServerAPI) Insert(ctx context.Context, req *umv1.InsertRequest) (*umv1.
 	select {
 	case <-ctx.Done():
 		log.Error("Request time out")
-		return nil, status.Error(codes.DeadlineExceeded, "request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
 	default:
 	}
 
 	userForInsert, err := profiles.ProtoUsrToUsr(req.GetUser())
 	if err != nil {
 		log.Error("Error parse pb_user to user", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "invalid user")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid user")
+	}
+
+	// is_import is trusted, only the admin-gated gateway import sets it
+	if userForInsert.Id != uuid.Nil && !req.GetIsImport() {
+		log.Warn("Client-chosen id rejected", slog.String("id", userForInsert.Id.String()))
+		return nil, apierror.New(apierror.ValidationFailed, "invalid user", apierror.FieldViolation{Field: "id", Description: "assigned by the server"})
+	}
+
+	// timestamps are the service's business unless history is being imported
+	if !req.GetIsImport() {
+		userForInsert.CreatedAt = time.Time{}
+		userForInsert.LastLoginAt = time.Time{}
 	}
 
 	insertedUser, err := s.Service.Insert(ctx, userForInsert)
 	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			return nil, invalidArgument(err, "invalid user")
+		}
 		if errors.Is(err, serviceerror.ErrAlreadyExists) {
 			log.Warn("User already exists", sl.Err(serviceerror.ErrAlreadyExists))
-			return nil, status.Error(codes.AlreadyExists, "user already exists")
+			return nil, apierror.New(apierror.UserAlreadyExists, "user already exists")
 		}
 
 		log.Error("Error inserting user", sl.Err(err))
-		return nil, status.Error(codes.Internal, "error inserting user")
+		return nil, apierror.New(apierror.Internal, "error inserting user")
 	}
 
 	return &umv1.InsertResponse{
@@ -153,31 +215,44 @@ func (s *ServerAPI) Update(ctx context.Context, req *umv1.UpdateRequest) (*umv1.
 	select {
 	case <-ctx.Done():
 		log.Error("Request time out")
-		return nil, status.Error(codes.DeadlineExceeded, "request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
 	default:
 	}
 
 	uid, err := uuid.Parse(req.GetId())
 	if err != nil {
 		log.Error("Cannot parse request uid", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "invalid uid")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
 	}
 
 	userForUpdate, err := profiles.ProtoUsrToUsr(req.GetUser())
 	if err != nil {
 		log.Error("Error parse pb_user to user", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "invalid user")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid user")
 	}
 
-	updatedUser, err := s.Service.Update(ctx, uid, userForUpdate)
+	// without update_mask the whole user is replaced
+	updatedUser, err := s.Service.Update(ctx, uid, userForUpdate, req.GetUpdateMask().GetPaths(), req.GetExpectedVersion())
 	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid user", sl.Err(err))
+			return nil, invalidArgument(err, "invalid user")
+		}
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, apierror.New(apierror.UserNotFound, "user not found")
+		}
+		if errors.Is(err, serviceerror.ErrConflict) {
+			log.Warn("Version mismatch", sl.Err(serviceerror.ErrConflict))
+			return nil, apierror.New(apierror.VersionConflict, "user was changed concurrently, re-read it and retry")
+		}
+		if errors.Is(err, serviceerror.ErrAlreadyExists) {
+			log.Warn("Login already taken", sl.Err(serviceerror.ErrAlreadyExists))
+			return nil, apierror.New(apierror.UserAlreadyExists, "login already taken")
 		}
 
 		log.Error("Error updating user", sl.Err(err))
-		return nil, status.Error(codes.Internal, "error updating user")
+		return nil, apierror.New(apierror.Internal, "error updating user")
 	}
 
 	return &umv1.UpdateResponse{
@@ -195,28 +270,199 @@ func (s *ServerAPI) Delete(ctx context.Context, req *umv1.DeleteRequest) (*umv1.
 	select {
 	case <-ctx.Done():
 		log.Error("Request time out")
-		return nil, status.Error(codes.DeadlineExceeded, "request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
 	default:
 	}
 
 	uid, err := uuid.Parse(req.GetId())
 	if err != nil {
 		log.Error("Cannot parse request uid", sl.Err(err))
-		return nil, status.Error(codes.InvalidArgument, "invalid uid")
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
 	}
 
-	deletedUser, err := s.Service.Delete(ctx, uid)
+	deletedUser, err := s.Service.Delete(ctx, uid, req.GetExpectedVersion())
 	if err != nil {
 		if errors.Is(err, serviceerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, apierror.New(apierror.UserNotFound, "user not found")
+		}
+		if errors.Is(err, serviceerror.ErrConflict) {
+			log.Warn("Version mismatch", sl.Err(serviceerror.ErrConflict))
+			return nil, apierror.New(apierror.VersionConflict, "user was changed concurrently, re-read it and retry")
 		}
 
 		log.Error("Error deleting user", sl.Err(err))
-		return nil, status.Error(codes.Internal, "error deleting user")
+		return nil, apierror.New(apierror.Internal, "error deleting user")
 	}
 
 	return &umv1.DeleteResponse{
 		User: profiles.UsrToProtoUsr(deletedUser),
 	}, nil
 }
+
+// Restore implements umv1.UsersManagerServer.
+func (s *ServerAPI) Restore(ctx context.Context, req *umv1.RestoreRequest) (*umv1.RestoreResponse, error) {
+	const op = "grpc.users.Restore"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Error("Request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
+	default:
+	}
+
+	uid, err := uuid.Parse(req.GetId())
+	if err != nil {
+		log.Error("Cannot parse request uid", sl.Err(err))
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
+	}
+
+	restoredUser, err := s.Service.Restore(ctx, uid)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("Deleted user not found", sl.Err(serviceerror.ErrNotFound))
+			return nil, apierror.New(apierror.UserNotFound, "deleted user not found")
+		}
+
+		log.Error("Error restoring user", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "error restoring user")
+	}
+
+	return &umv1.RestoreResponse{
+		User: profiles.UsrToProtoUsr(restoredUser),
+	}, nil
+}
+
+// Purge implements umv1.UsersManagerServer.
+func (s *ServerAPI) Purge(ctx context.Context, req *umv1.PurgeRequest) (*umv1.PurgeResponse, error) {
+	const op = "grpc.users.Purge"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Error("Request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
+	default:
+	}
+
+	uid, err := uuid.Parse(req.GetId())
+	if err != nil {
+		log.Error("Cannot parse request uid", sl.Err(err))
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
+	}
+
+	purgedUser, err := s.Service.Purge(ctx, uid)
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
+			return nil, apierror.New(apierror.UserNotFound, "user not found")
+		}
+
+		log.Error("Error purging user", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "error purging user")
+	}
+
+	return &umv1.PurgeResponse{
+		User: profiles.UsrToProtoUsr(purgedUser),
+	}, nil
+}
+
+// VerifyCredentials implements umv1.UsersManagerServer.
+func (s *ServerAPI) VerifyCredentials(ctx context.Context, req *umv1.VerifyCredentialsRequest) (*umv1.VerifyCredentialsResponse, error) {
+	const op = "grpc.users.VerifyCredentials"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Error("Request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
+	default:
+	}
+
+	if req.GetLogin() == "" || req.GetPassword() == "" {
+		log.Error("Login and password are required")
+		return nil, apierror.New(apierror.ValidationFailed, "login and password are required")
+	}
+
+	user, err := s.Service.VerifyCredentials(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidCredentials) {
+			log.Warn("Invalid credentials", sl.Err(serviceerror.ErrInvalidCredentials))
+			return nil, apierror.New(apierror.InvalidCredentials, "invalid credentials")
+		}
+
+		log.Error("Error verifying credentials", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "error verifying credentials")
+	}
+
+	return &umv1.VerifyCredentialsResponse{
+		User: profiles.UsrToProtoUsr(user),
+	}, nil
+}
+
+// ListUserHistory implements umv1.UsersManagerServer.
+func (s *ServerAPI) ListUserHistory(ctx context.Context, req *umv1.ListUserHistoryRequest) (*umv1.ListUserHistoryResponse, error) {
+	const op = "grpc.users.ListUserHistory"
+	log := s.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		log.Error("Request time out")
+		return nil, apierror.New(apierror.DeadlineExceeded, "request time out")
+	default:
+	}
+
+	uid, err := uuid.Parse(req.GetId())
+	if err != nil {
+		log.Error("Cannot parse request uid", sl.Err(err))
+		return nil, apierror.New(apierror.MalformedRequest, "invalid uid")
+	}
+
+	page, err := s.Service.ListUserHistory(ctx, models.HistoryPageRequest{
+		UserId:   uid,
+		PageSize: int(req.GetPageSize()),
+		Cursor:   req.GetCursor(),
+	})
+	if err != nil {
+		if errors.Is(err, serviceerror.ErrInvalidArgument) {
+			log.Warn("Invalid page request", sl.Err(err))
+			return nil, invalidArgument(err, "invalid page request")
+		}
+
+		log.Error("Error fetching user history", sl.Err(err))
+		return nil, apierror.New(apierror.Internal, "error fetching user history")
+	}
+
+	entries := make([]*umv1.UserHistoryEntry, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		entries = append(entries, profiles.HistoryEntryToProto(entry))
+	}
+
+	return &umv1.ListUserHistoryResponse{
+		Entries:    entries,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// invalidArgument describes a request the service rejected, naming the
+// offending field when the service did.
+func invalidArgument(err error, message string) error {
+	var fieldErr *serviceerror.FieldError
+	if errors.As(err, &fieldErr) {
+		return apierror.New(apierror.ValidationFailed, message, apierror.FieldViolation{
+			Field:       fieldErr.Field,
+			Description: fieldErr.Description,
+		})
+	}
+
+	return apierror.New(apierror.ValidationFailed, message)
+}
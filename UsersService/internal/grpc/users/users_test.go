@@ -2,19 +2,23 @@ package usersgrpc_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 	"usersservice/internal/domain/models"
 	"usersservice/internal/domain/profiles"
 	usersgrpc "usersservice/internal/grpc/users"
 	serviceerror "usersservice/internal/service"
 	"usersservice/pkg/lib/logger"
 
+	"github.com/chas3air/protos/apierror"
 	umv1 "github.com/chas3air/protos/gen/go/usersManager"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // --- Mock IUsersService ---
@@ -23,9 +27,9 @@ type MockUsersService struct {
 	mock.Mock
 }
 
-func (m *MockUsersService) GetUsers(ctx context.Context) ([]models.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]models.User), args.Error(1)
+func (m *MockUsersService) GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(models.UsersPage), args.Error(1)
 }
 
 func (m *MockUsersService) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
@@ -33,21 +37,46 @@ func (m *MockUsersService) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+func (m *MockUsersService) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	args := m.Called(ctx, login)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
 func (m *MockUsersService) Insert(ctx context.Context, user models.User) (models.User, error) {
 	args := m.Called(ctx, user)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUsersService) Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error) {
-	args := m.Called(ctx, uid, user)
+func (m *MockUsersService) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (models.User, error) {
+	args := m.Called(ctx, uid, user, fields, expectedVersion)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUsersService) Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error) {
+	args := m.Called(ctx, uid, expectedVersion)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUsersService) Restore(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	args := m.Called(ctx, uid)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+func (m *MockUsersService) Purge(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	args := m.Called(ctx, uid)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+func (m *MockUsersService) VerifyCredentials(ctx context.Context, login string, password string) (models.User, error) {
+	args := m.Called(ctx, login, password)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUsersService) ListUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(models.HistoryPage), args.Error(1)
+}
+
 // --- Helpers ---
 
 func newTestServer(t *testing.T, service *MockUsersService) *usersgrpc.ServerAPI {
@@ -66,18 +95,45 @@ func TestGetUsers_Success(t *testing.T) {
 		{Id: uuid.New(), Login: "user1"},
 		{Id: uuid.New(), Login: "user2"},
 	}
-	mockSvc.On("GetUsers", mock.Anything).Return(users, nil)
+	req := models.UsersPageRequest{
+		Filter:   models.UsersFilter{Role: "user", LoginPrefix: "us"},
+		Sort:     models.UsersSort{By: models.SortByLogin, Desc: true},
+		PageSize: 2,
+		Cursor:   "cursor",
+	}
+	mockSvc.On("GetUsers", mock.Anything, req).Return(models.UsersPage{Users: users, NextCursor: "next"}, nil)
 
 	srv := newTestServer(t, mockSvc)
-	resp, err := srv.GetUsers(context.Background(), &umv1.GetUsersRequest{})
+	resp, err := srv.GetUsers(context.Background(), &umv1.GetUsersRequest{
+		PageSize:    2,
+		Cursor:      "cursor",
+		Role:        "user",
+		LoginPrefix: "us",
+		SortBy:      models.SortByLogin,
+		Descending:  true,
+	})
 
 	assert.NoError(t, err)
 	assert.Len(t, resp.Users, 2)
+	assert.Equal(t, "next", resp.GetNextCursor())
 	transferedUser, _ := profiles.ProtoUsrToUsr(resp.GetUsers()[0])
 	assert.Equal(t, users[0].Login, transferedUser.Login)
 	mockSvc.AssertExpectations(t)
 }
 
+func TestGetUsers_InvalidArgument(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	mockSvc.On("GetUsers", mock.Anything, mock.Anything).Return(models.UsersPage{}, serviceerror.ErrInvalidArgument)
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.GetUsers(context.Background(), &umv1.GetUsersRequest{SortBy: "password"})
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
 func TestGetUsers_ContextCanceled(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	srv := newTestServer(t, mockSvc)
@@ -138,24 +194,127 @@ func TestGetUserById_NotFound(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
-func TestInsert_Success(t *testing.T) {
+func TestGetUserByLogin_Success(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	user := models.User{Id: uuid.New(), Login: "user1"}
 
-	mockSvc.On("Insert", mock.Anything, user).Return(user, nil)
+	mockSvc.On("GetUserByLogin", mock.Anything, "User1").Return(user, nil)
 
 	srv := newTestServer(t, mockSvc)
-	pbUser := profiles.UsrToProtoUsr(user)
-	req := &umv1.InsertRequest{User: pbUser}
+	resp, err := srv.GetUserByLogin(context.Background(), &umv1.GetUserByLoginRequest{Login: "User1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id.String(), resp.GetUser().GetId())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGetUserByLogin_NotFound(t *testing.T) {
+	mockSvc := new(MockUsersService)
+
+	mockSvc.On("GetUserByLogin", mock.Anything, "user1").Return(models.User{}, serviceerror.ErrNotFound)
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.GetUserByLogin(context.Background(), &umv1.GetUserByLoginRequest{Login: "user1"})
+
+	assert.Error(t, err)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestInsert_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	user := models.User{Login: "user1"}
+	inserted := models.User{Id: uuid.New(), Login: "user1"}
+
+	mockSvc.On("Insert", mock.Anything, user).Return(inserted, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.InsertRequest{User: &umv1.User{Login: "user1"}}
 
 	resp, err := srv.Insert(context.Background(), req)
 	assert.NoError(t, err)
 
 	transferedUser, _ := profiles.ProtoUsrToUsr(resp.User)
+	assert.Equal(t, inserted.Id, transferedUser.Id)
 	assert.Equal(t, user.Login, transferedUser.Login)
 	mockSvc.AssertExpectations(t)
 }
 
+func TestInsert_ClientIdRejected(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	srv := newTestServer(t, mockSvc)
+
+	req := &umv1.InsertRequest{User: profiles.UsrToProtoUsr(models.User{Id: uuid.New(), Login: "user1"})}
+	_, err := srv.Insert(context.Background(), req)
+
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	mockSvc.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func TestInsert_ImportKeepsId(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	user := models.User{Id: uuid.New(), Login: "user1"}
+
+	mockSvc.On("Insert", mock.Anything, user).Return(user, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.InsertRequest{User: profiles.UsrToProtoUsr(user), IsImport: true}
+
+	resp, err := srv.Insert(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id.String(), resp.GetUser().GetId())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestInsert_TimestampsOnlyFromImports(t *testing.T) {
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := models.User{Login: "user1", Email: "user1@example.com", CreatedAt: createdAt, LastLoginAt: createdAt}
+
+	mockSvc := new(MockUsersService)
+	mockSvc.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.Email == "user1@example.com" && u.CreatedAt.IsZero() && u.LastLoginAt.IsZero()
+	})).Return(models.User{Id: uuid.New(), Login: "user1", CreatedAt: time.Now()}, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.InsertRequest{User: profiles.UsrToProtoUsr(user)}
+
+	resp, err := srv.Insert(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.GetUser().GetCreatedAt())
+	assert.Nil(t, resp.GetUser().GetLastLoginAt())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestInsert_InvalidProfile(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	mockSvc.On("Insert", mock.Anything, mock.Anything).
+		Return(models.User{}, fmt.Errorf("%w: malformed email", serviceerror.ErrInvalidArgument))
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Insert(context.Background(), &umv1.InsertRequest{User: &umv1.User{Login: "user1", Email: "nope"}})
+
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestInsert_InvalidField_CarriesViolation(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	mockSvc.On("Insert", mock.Anything, mock.Anything).
+		Return(models.User{}, fmt.Errorf("service.users.Insert: %w", &serviceerror.FieldError{Field: models.FieldEmail, Description: "malformed email"}))
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Insert(context.Background(), &umv1.InsertRequest{User: &umv1.User{Login: "user1", Email: "nope"}})
+
+	apiErr, ok := apierror.FromError(status.ErrorProto(status.Convert(err).Proto()))
+	assert.True(t, ok)
+	assert.Equal(t, apierror.ValidationFailed, apiErr.Reason)
+	assert.Equal(t, []apierror.FieldViolation{{Field: models.FieldEmail, Description: "malformed email"}}, apiErr.Violations)
+	mockSvc.AssertExpectations(t)
+}
+
 func TestInsert_InvalidUser(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	srv := newTestServer(t, mockSvc)
@@ -170,12 +329,12 @@ func TestInsert_InvalidUser(t *testing.T) {
 
 func TestInsert_AlreadyExists(t *testing.T) {
 	mockSvc := new(MockUsersService)
-	user := models.User{Id: uuid.New(), Login: "user1"}
+	user := models.User{Login: "user1"}
 
 	mockSvc.On("Insert", mock.Anything, user).Return(models.User{}, serviceerror.ErrAlreadyExists)
 
 	srv := newTestServer(t, mockSvc)
-	req := &umv1.InsertRequest{User: profiles.UsrToProtoUsr(user)}
+	req := &umv1.InsertRequest{User: &umv1.User{Login: "user1"}}
 
 	_, err := srv.Insert(context.Background(), req)
 	assert.Error(t, err)
@@ -189,7 +348,7 @@ func TestUpdate_Success(t *testing.T) {
 	id := uuid.New()
 	user := models.User{Id: id, Login: "user1"}
 
-	mockSvc.On("Update", mock.Anything, id, user).Return(user, nil)
+	mockSvc.On("Update", mock.Anything, id, user, []string(nil), int64(0)).Return(user, nil)
 
 	srv := newTestServer(t, mockSvc)
 	req := &umv1.UpdateRequest{
@@ -205,6 +364,27 @@ func TestUpdate_Success(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
+func TestUpdate_FieldMask(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+	user := models.User{Role: "admin"}
+	stored := models.User{Id: id, Login: "user1", Role: "admin"}
+
+	mockSvc.On("Update", mock.Anything, id, user, []string{"role"}, int64(0)).Return(stored, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.UpdateRequest{
+		Id:         id.String(),
+		User:       profiles.UsrToProtoUsr(user),
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"role"}},
+	}
+
+	resp, err := srv.Update(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", resp.GetUser().GetLogin())
+	mockSvc.AssertExpectations(t)
+}
+
 func TestUpdate_InvalidUUID(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	srv := newTestServer(t, mockSvc)
@@ -221,7 +401,7 @@ func TestUpdate_NotFound(t *testing.T) {
 	id := uuid.New()
 	user := models.User{Id: id, Login: "user1"}
 
-	mockSvc.On("Update", mock.Anything, id, user).Return(models.User{}, serviceerror.ErrNotFound)
+	mockSvc.On("Update", mock.Anything, id, user, []string(nil), int64(0)).Return(models.User{}, serviceerror.ErrNotFound)
 
 	srv := newTestServer(t, mockSvc)
 	req := &umv1.UpdateRequest{
@@ -235,12 +415,31 @@ func TestUpdate_NotFound(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
-func TestDelete_Success(t *testing.T) {
+func TestUpdate_VersionConflict(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	id := uuid.New()
 	user := models.User{Id: id, Login: "user1"}
 
-	mockSvc.On("Delete", mock.Anything, id).Return(user, nil)
+	mockSvc.On("Update", mock.Anything, id, user, []string(nil), int64(7)).Return(models.User{}, serviceerror.ErrConflict)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.UpdateRequest{
+		Id:              id.String(),
+		User:            profiles.UsrToProtoUsr(user),
+		ExpectedVersion: 7,
+	}
+	_, err := srv.Update(context.Background(), req)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.Aborted, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestDelete_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1", DeletedAt: time.Now().UTC()}
+
+	mockSvc.On("Delete", mock.Anything, id, int64(0)).Return(user, nil)
 
 	srv := newTestServer(t, mockSvc)
 	req := &umv1.DeleteRequest{Id: id.String()}
@@ -250,6 +449,7 @@ func TestDelete_Success(t *testing.T) {
 
 	transferedUser, _ := profiles.ProtoUsrToUsr(resp.User)
 	assert.Equal(t, user.Login, transferedUser.Login)
+	assert.True(t, resp.User.GetDeletedAt().AsTime().Equal(user.DeletedAt))
 	mockSvc.AssertExpectations(t)
 }
 
@@ -268,7 +468,7 @@ func TestDelete_NotFound(t *testing.T) {
 	mockSvc := new(MockUsersService)
 	id := uuid.New()
 
-	mockSvc.On("Delete", mock.Anything, id).Return(models.User{}, serviceerror.ErrNotFound)
+	mockSvc.On("Delete", mock.Anything, id, int64(0)).Return(models.User{}, serviceerror.ErrNotFound)
 
 	srv := newTestServer(t, mockSvc)
 	req := &umv1.DeleteRequest{Id: id.String()}
@@ -278,3 +478,147 @@ func TestDelete_NotFound(t *testing.T) {
 	assert.Equal(t, codes.NotFound, st.Code())
 	mockSvc.AssertExpectations(t)
 }
+
+func TestDelete_VersionConflict(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+
+	mockSvc.On("Delete", mock.Anything, id, int64(7)).Return(models.User{}, serviceerror.ErrConflict)
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Delete(context.Background(), &umv1.DeleteRequest{Id: id.String(), ExpectedVersion: 7})
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.Aborted, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRestore_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1"}
+
+	mockSvc.On("Restore", mock.Anything, id).Return(user, nil)
+
+	srv := newTestServer(t, mockSvc)
+	resp, err := srv.Restore(context.Background(), &umv1.RestoreRequest{Id: id.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, user.Login, resp.User.GetLogin())
+	assert.Nil(t, resp.User.GetDeletedAt())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRestore_NotFound(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+
+	mockSvc.On("Restore", mock.Anything, id).Return(models.User{}, serviceerror.ErrNotFound)
+
+	srv := newTestServer(t, mockSvc)
+	_, err := srv.Restore(context.Background(), &umv1.RestoreRequest{Id: id.String()})
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestPurge_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1"}
+
+	mockSvc.On("Purge", mock.Anything, id).Return(user, nil)
+
+	srv := newTestServer(t, mockSvc)
+	resp, err := srv.Purge(context.Background(), &umv1.PurgeRequest{Id: id.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, id.String(), resp.User.GetId())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestPurge_InvalidUUID(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	srv := newTestServer(t, mockSvc)
+
+	_, err := srv.Purge(context.Background(), &umv1.PurgeRequest{Id: "bad-uuid"})
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+}
+
+func TestVerifyCredentials_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "hash", Role: "user"}
+
+	mockSvc.On("VerifyCredentials", mock.Anything, "user1", "pass").Return(user, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.VerifyCredentialsRequest{Login: "user1", Password: "pass"}
+
+	resp, err := srv.VerifyCredentials(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id.String(), resp.GetUser().GetId())
+	assert.Empty(t, resp.GetUser().GetPassword())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_Invalid(t *testing.T) {
+	mockSvc := new(MockUsersService)
+
+	mockSvc.On("VerifyCredentials", mock.Anything, "user1", "wrong").Return(models.User{}, serviceerror.ErrInvalidCredentials)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.VerifyCredentialsRequest{Login: "user1", Password: "wrong"}
+
+	_, err := srv.VerifyCredentials(context.Background(), req)
+	assert.Error(t, err)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.Unauthenticated, st.Code())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_EmptyArguments(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	srv := newTestServer(t, mockSvc)
+
+	_, err := srv.VerifyCredentials(context.Background(), &umv1.VerifyCredentialsRequest{Login: "user1"})
+	assert.Error(t, err)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+}
+
+func TestListUserHistory_Success(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	id := uuid.New()
+	entry := models.HistoryEntry{
+		Id:        uuid.New(),
+		UserId:    id,
+		Actor:     "admin-id",
+		Operation: models.OperationUpdate,
+		Changes:   []models.FieldChange{{Field: models.FieldRole, Before: "user", After: "admin"}},
+	}
+
+	mockSvc.On("ListUserHistory", mock.Anything, models.HistoryPageRequest{UserId: id, PageSize: 10, Cursor: "c"}).
+		Return(models.HistoryPage{Entries: []models.HistoryEntry{entry}, NextCursor: "next"}, nil)
+
+	srv := newTestServer(t, mockSvc)
+	req := &umv1.ListUserHistoryRequest{Id: id.String(), PageSize: 10, Cursor: "c"}
+
+	resp, err := srv.ListUserHistory(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "next", resp.GetNextCursor())
+	if assert.Len(t, resp.GetEntries(), 1) {
+		assert.Equal(t, "admin-id", resp.GetEntries()[0].GetActor())
+		assert.Equal(t, "admin", resp.GetEntries()[0].GetChanges()[0].GetAfter())
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListUserHistory_InvalidCursor(t *testing.T) {
+	mockSvc := new(MockUsersService)
+	mockSvc.On("ListUserHistory", mock.Anything, mock.Anything).Return(models.HistoryPage{}, serviceerror.ErrInvalidArgument)
+
+	srv := newTestServer(t, mockSvc)
+
+	_, err := srv.ListUserHistory(context.Background(), &umv1.ListUserHistoryRequest{Id: uuid.NewString(), Cursor: "bad"})
+	assert.Error(t, err)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+}
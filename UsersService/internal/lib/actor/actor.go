@@ -0,0 +1,27 @@
+// Package actor tells who a request to UsersService is made for. Callers
+// are trusted services, they name the acting user in gRPC metadata.
+package actor
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata the actor is read from.
+const MetadataKey = "x-actor-id"
+
+// FromContext returns the actor of an incoming request, empty if the
+// caller did not name one.
+func FromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(MetadataKey); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
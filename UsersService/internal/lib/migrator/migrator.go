@@ -0,0 +1,156 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"usersservice/migrations"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+)
+
+const (
+	CommandUp     = "up"
+	CommandDown   = "down"
+	CommandStatus = "status"
+	CommandRedo   = "redo"
+)
+
+var ErrUnknownCommand = errors.New("unknown migrate command")
+
+// Migrator applies the embedded migrations of a storage driver. On Postgres
+// every run holds a session advisory lock, so replicas starting together
+// apply the migrations once and the others wait for them.
+type Migrator struct {
+	log      *slog.Logger
+	provider *goose.Provider
+}
+
+func New(log *slog.Logger, db *sql.DB, driver string) (*Migrator, error) {
+	var (
+		provider *goose.Provider
+		err      error
+	)
+
+	switch driver {
+	case DriverPostgres:
+		locker, lockErr := lock.NewPostgresSessionLocker()
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		provider, err = goose.NewProvider(goose.DialectPostgres, db, migrations.Postgres(), goose.WithSessionLocker(locker))
+	case DriverSqlite:
+		provider, err = goose.NewProvider(goose.DialectSQLite3, db, migrations.SQLite())
+	default:
+		return nil, fmt.Errorf("driver %q has no migrations", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		log:      log,
+		provider: provider,
+	}, nil
+}
+
+// Run executes one of the migrate subcommands, status is written to w.
+func (m *Migrator) Run(ctx context.Context, command string, w io.Writer) error {
+	switch command {
+	case CommandUp:
+		return m.Up(ctx)
+	case CommandDown:
+		return m.Down(ctx)
+	case CommandStatus:
+		return m.Status(ctx, w)
+	case CommandRedo:
+		return m.Redo(ctx)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownCommand, command)
+	}
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	m.logResults(results...)
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+
+	if len(results) == 0 {
+		m.log.Info("No pending migrations")
+	}
+
+	return nil
+}
+
+// Down rolls back the latest migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	if result != nil {
+		m.logResults(result)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate down: %w", err)
+	}
+
+	return nil
+}
+
+// Redo rolls back the latest migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) error {
+	if err := m.Down(ctx); err != nil {
+		return err
+	}
+
+	result, err := m.provider.UpByOne(ctx)
+	if result != nil {
+		m.logResults(result)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate redo: %w", err)
+	}
+
+	return nil
+}
+
+// Status writes every known migration with its state.
+func (m *Migrator) Status(ctx context.Context, w io.Writer) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate status: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+
+	return tw.Flush()
+}
+
+func (m *Migrator) logResults(results ...*goose.MigrationResult) {
+	for _, result := range results {
+		m.log.Info("Migration applied",
+			slog.Int64("version", result.Source.Version),
+			slog.String("direction", result.Direction),
+			slog.Duration("duration", result.Duration),
+		)
+	}
+}
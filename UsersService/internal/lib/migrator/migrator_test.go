@@ -0,0 +1,125 @@
+package migrator_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"usersservice/internal/lib/migrator"
+	userssqlitestorage "usersservice/internal/storage/sqlite/users"
+	"usersservice/pkg/lib/logger"
+)
+
+func newMigrator(t *testing.T) (*migrator.Migrator, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", userssqlitestorage.DSN(filepath.Join(t.TempDir(), "users.db")))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrator.New(logger.SetupLogger("local"), db, migrator.DriverSqlite)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %s", err)
+	}
+
+	return m, db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?`, name).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to query sqlite_master: %s", err)
+	}
+
+	return count == 1
+}
+
+func TestUp_AppliesEmbeddedMigrations(t *testing.T) {
+	m, db := newMigrator(t)
+	ctx := context.Background()
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !tableExists(t, db, "users") {
+		t.Fatalf("users table was not created")
+	}
+
+	// a second run has nothing to apply
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("unexpected error on second up: %s", err)
+	}
+}
+
+func TestStatus_ListsMigrations(t *testing.T) {
+	m, _ := newMigrator(t)
+	ctx := context.Background()
+
+	var before bytes.Buffer
+	if err := m.Run(ctx, migrator.CommandStatus, &before); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(before.String(), "applied") {
+		t.Errorf("expected only pending migrations, got:\n%s", before.String())
+	}
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var after bytes.Buffer
+	if err := m.Run(ctx, migrator.CommandStatus, &after); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(after.String(), "pending") || !strings.Contains(after.String(), "applied") {
+		t.Errorf("expected only applied migrations, got:\n%s", after.String())
+	}
+}
+
+func TestDownAndRedo(t *testing.T) {
+	m, db := newMigrator(t)
+	ctx := context.Background()
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := m.Run(ctx, migrator.CommandRedo, nil); err != nil {
+		t.Fatalf("unexpected error on redo: %s", err)
+	}
+	if !tableExists(t, db, "users") {
+		t.Fatalf("users table missing after redo")
+	}
+
+	for {
+		var status bytes.Buffer
+		if err := m.Status(ctx, &status); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(status.String(), "applied") {
+			break
+		}
+		if err := m.Run(ctx, migrator.CommandDown, nil); err != nil {
+			t.Fatalf("unexpected error on down: %s", err)
+		}
+	}
+	if tableExists(t, db, "users") {
+		t.Errorf("users table still exists after rolling everything back")
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	m, _ := newMigrator(t)
+
+	err := m.Run(context.Background(), "sideways", nil)
+	if !errors.Is(err, migrator.ErrUnknownCommand) {
+		t.Errorf("expected ErrUnknownCommand, got %v", err)
+	}
+}
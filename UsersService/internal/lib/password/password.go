@@ -0,0 +1,234 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Params configures the hasher. Zero values fall back to sane defaults.
+type Params struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+	Pepper        string
+}
+
+// Hasher produces and verifies encoded password hashes.
+// Stored values without a known prefix are treated as legacy plaintext,
+// they still verify but always report that they need a rehash.
+type Hasher struct {
+	params Params
+}
+
+func New(params Params) (*Hasher, error) {
+	if params.Algorithm == "" {
+		params.Algorithm = AlgorithmBcrypt
+	}
+
+	switch params.Algorithm {
+	case AlgorithmBcrypt:
+		if params.BcryptCost == 0 {
+			params.BcryptCost = bcrypt.DefaultCost
+		}
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be in [%d, %d]", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if params.Argon2Time == 0 {
+			params.Argon2Time = 1
+		}
+		if params.Argon2Memory == 0 {
+			params.Argon2Memory = 64 * 1024
+		}
+		if params.Argon2Threads == 0 {
+			params.Argon2Threads = 4
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, params.Algorithm)
+	}
+
+	return &Hasher{params: params}, nil
+}
+
+func MustNew(params Params) *Hasher {
+	h, err := New(params)
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// Hash encodes password with the configured algorithm.
+func (h *Hasher) Hash(password string) (string, error) {
+	peppered := h.pepper(password)
+
+	switch h.params.Algorithm {
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword(peppered, h.params.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+
+		return string(hash), nil
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey(peppered, salt, h.params.Argon2Time, h.params.Argon2Memory, h.params.Argon2Threads, argon2KeyLen)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			h.params.Argon2Memory, h.params.Argon2Time, h.params.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", ErrUnknownAlgorithm
+	}
+}
+
+// Verify reports whether password matches the encoded value.
+func (h *Hasher) Verify(password string, encoded string) (bool, error) {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), h.pepper(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	case isArgon2id(encoded):
+		hash, err := parseArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+
+		key := argon2.IDKey(h.pepper(password), hash.salt, hash.time, hash.memory, hash.threads, uint32(len(hash.key)))
+
+		return subtle.ConstantTimeCompare(key, hash.key) == 1, nil
+	default:
+		return subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1, nil
+	}
+}
+
+// NeedsRehash reports whether encoded was produced with other algorithm or
+// parameters than the configured ones (including legacy plaintext values).
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch {
+	case isBcrypt(encoded):
+		if h.params.Algorithm != AlgorithmBcrypt {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.params.BcryptCost
+	case isArgon2id(encoded):
+		if h.params.Algorithm != AlgorithmArgon2id {
+			return true
+		}
+
+		hash, err := parseArgon2id(encoded)
+		return err != nil ||
+			hash.time != h.params.Argon2Time ||
+			hash.memory != h.params.Argon2Memory ||
+			hash.threads != h.params.Argon2Threads
+	default:
+		return true
+	}
+}
+
+// IsHashed reports whether encoded looks like a value produced by Hash.
+func IsHashed(encoded string) bool {
+	return isBcrypt(encoded) || isArgon2id(encoded)
+}
+
+// pepper mixes the server-side secret into the password. HMAC keeps the
+// input at a fixed length, so bcrypt's 72 byte limit is never hit.
+func (h *Hasher) pepper(password string) []byte {
+	if h.params.Pepper == "" {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.params.Pepper))
+	mac.Write([]byte(password))
+
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func isArgon2id(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+type argon2idHash struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2id(encoded string) (argon2idHash, error) {
+	// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	var hash argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.memory, &hash.time, &hash.threads); err != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	return hash, nil
+}
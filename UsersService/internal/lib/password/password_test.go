@@ -0,0 +1,69 @@
+package password_test
+
+import (
+	"testing"
+
+	"usersservice/internal/lib/password"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	cases := []password.Params{
+		{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4},
+		{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4, Pepper: "pepper"},
+		{Algorithm: password.AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1},
+		{Algorithm: password.AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1, Pepper: "pepper"},
+	}
+
+	for _, params := range cases {
+		t.Run(params.Algorithm, func(t *testing.T) {
+			h, err := password.New(params)
+			require.NoError(t, err)
+
+			encoded, err := h.Hash("secret")
+			require.NoError(t, err)
+			assert.True(t, password.IsHashed(encoded))
+			assert.False(t, h.NeedsRehash(encoded))
+
+			ok, err := h.Verify("secret", encoded)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = h.Verify("wrong", encoded)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestVerify_LegacyPlaintext(t *testing.T) {
+	h := password.MustNew(password.Params{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+
+	ok, err := h.Verify("secret", "secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash("secret"))
+}
+
+func TestNeedsRehash_ParamsChanged(t *testing.T) {
+	old := password.MustNew(password.Params{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	encoded, err := old.Hash("secret")
+	require.NoError(t, err)
+
+	stronger := password.MustNew(password.Params{Algorithm: password.AlgorithmBcrypt, BcryptCost: 5})
+	assert.True(t, stronger.NeedsRehash(encoded))
+
+	argon := password.MustNew(password.Params{Algorithm: password.AlgorithmArgon2id, Argon2Memory: 1024})
+	assert.True(t, argon.NeedsRehash(encoded))
+
+	ok, err := argon.Verify("secret", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestNew_UnknownAlgorithm(t *testing.T) {
+	_, err := password.New(password.Params{Algorithm: "md5"})
+	assert.ErrorIs(t, err, password.ErrUnknownAlgorithm)
+}
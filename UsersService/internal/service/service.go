@@ -1,8 +1,29 @@
 package serviceerror
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrNotFound      = errors.New("resource not found")
-	ErrAlreadyExists = errors.New("resource already exists")
+	ErrNotFound        = errors.New("resource not found")
+	ErrAlreadyExists   = errors.New("resource already exists")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrConflict        = errors.New("resource version conflict")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
 )
+
+// FieldError is an ErrInvalidArgument caused by one field of the request.
+type FieldError struct {
+	Field       string
+	Description string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrInvalidArgument, e.Field, e.Description)
+}
+
+func (e *FieldError) Unwrap() error {
+	return ErrInvalidArgument
+}
@@ -0,0 +1,152 @@
+package usersservice
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"usersservice/internal/domain/models"
+	"usersservice/internal/lib/actor"
+	serviceerror "usersservice/internal/service"
+	"usersservice/pkg/lib/logger/sl"
+
+	"github.com/google/uuid"
+)
+
+// redacted replaces passwords in the history, even hashes stay inside the
+// users table.
+const redacted = "[redacted]"
+
+type IHistoryStorage interface {
+	GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error)
+}
+
+// ListUserHistory implements grpcapp.IUsersService.
+// Entries come newest first, they outlive the user when it is purged.
+func (u *UsersService) ListUserHistory(ctx context.Context, req models.HistoryPageRequest) (models.HistoryPage, error) {
+	const op = "service.users.ListUserHistory"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	query := models.HistoryQuery{
+		UserId: req.UserId,
+		Limit:  req.PageSize,
+	}
+	switch {
+	case query.Limit <= 0:
+		query.Limit = DefaultPageSize
+	case query.Limit > MaxPageSize:
+		query.Limit = MaxPageSize
+	}
+
+	if req.Cursor != "" {
+		after, err := decodeHistoryCursor(req.Cursor)
+		if err != nil {
+			log.Warn("Invalid history cursor", sl.Err(err))
+			return models.HistoryPage{}, fmt.Errorf("%s: %w", op, &serviceerror.FieldError{Field: "cursor", Description: "malformed cursor"})
+		}
+		query.After = after
+	}
+
+	pageSize := query.Limit
+	query.Limit++ // one extra entry tells whether there is a next page
+
+	entries, err := u.history.GetUserHistory(ctx, query)
+	if err != nil {
+		log.Error("Error fetching user history", sl.Err(err))
+		return models.HistoryPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.HistoryPage{Entries: entries}
+	if len(entries) > pageSize {
+		page.Entries = entries[:pageSize]
+		page.NextCursor = encodeHistoryCursor(page.Entries[pageSize-1].Id)
+	}
+
+	return page, nil
+}
+
+// audit returns what a write passes to the storage to build its history
+// entry. The storage stores the entry with the write, so a write that
+// cannot be recorded fails. Id, actor and time are fixed up front.
+func (u *UsersService) audit(ctx context.Context, operation string, uid uuid.UUID) (models.Audit, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate history id: %w", err)
+	}
+
+	by, changedAt := actor.FromContext(ctx), now()
+
+	return func(before models.User, after models.User) models.HistoryEntry {
+		return models.HistoryEntry{
+			Id:        id,
+			UserId:    uid,
+			Actor:     by,
+			Operation: operation,
+			ChangedAt: changedAt,
+			Changes:   diffUsers(before, after),
+		}
+	}, nil
+}
+
+// diffUsers lists the fields that differ between two states of a user.
+func diffUsers(before models.User, after models.User) []models.FieldChange {
+	changes := []models.FieldChange{}
+	for _, field := range models.UpdatableFields {
+		b, a := before.FieldValue(field), after.FieldValue(field)
+		if b == a {
+			continue
+		}
+		if field == models.FieldPassword {
+			b, a = redact(b), redact(a)
+		}
+
+		changes = append(changes, models.FieldChange{Field: field, Before: b, After: a})
+	}
+
+	if !before.DeletedAt.Equal(after.DeletedAt) {
+		changes = append(changes, models.FieldChange{
+			Field:  "deleted_at",
+			Before: formatTime(before.DeletedAt),
+			After:  formatTime(after.DeletedAt),
+		})
+	}
+
+	return changes
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func encodeHistoryCursor(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func decodeHistoryCursor(token string) (uuid.UUID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.FromBytes(b)
+}
@@ -0,0 +1,154 @@
+package usersservice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"usersservice/internal/domain/models"
+	"usersservice/internal/lib/actor"
+	serviceerror "usersservice/internal/service"
+	usersservice "usersservice/internal/service/users"
+	usersmemorystorage "usersservice/internal/storage/memory/users"
+	"usersservice/pkg/lib/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// newHistoryService runs the service against the memory storage, which
+// stores history entries together with the writes like the databases do.
+func newHistoryService(t *testing.T) (*usersservice.UsersService, models.User) {
+	log := logger.SetupLogger("local")
+	storage := usersmemorystorage.New(log)
+	svc := usersservice.MustNew(log, storage, storage, fakeHasher{})
+
+	user, err := svc.Insert(context.Background(), models.User{Login: "user1", Password: "old", Role: "user"})
+	require.NoError(t, err)
+
+	return svc, user
+}
+
+func listHistory(t *testing.T, svc *usersservice.UsersService, uid uuid.UUID) []models.HistoryEntry {
+	page, err := svc.ListUserHistory(context.Background(), models.HistoryPageRequest{UserId: uid, PageSize: usersservice.MaxPageSize})
+	require.NoError(t, err)
+
+	return page.Entries
+}
+
+func TestInsert_RecordsHistory(t *testing.T) {
+	svc, user := newHistoryService(t)
+
+	entries := listHistory(t, svc, user.Id)
+
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.OperationInsert, entries[0].Operation)
+		assert.Contains(t, entries[0].Changes, models.FieldChange{Field: models.FieldLogin, After: "user1"})
+		assert.Contains(t, entries[0].Changes, models.FieldChange{Field: models.FieldPassword, After: "[redacted]"})
+	}
+}
+
+func TestUpdate_RecordsHistory(t *testing.T) {
+	svc, user := newHistoryService(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(actor.MetadataKey, "admin-id"))
+	_, err := svc.Update(ctx, user.Id, models.User{Password: "new", Role: "admin"}, []string{models.FieldPassword, models.FieldRole}, 0)
+	require.NoError(t, err)
+
+	entries := listHistory(t, svc, user.Id)
+	if assert.Len(t, entries, 2) {
+		entry := entries[0]
+		assert.Equal(t, user.Id, entry.UserId)
+		assert.Equal(t, "admin-id", entry.Actor)
+		assert.Equal(t, models.OperationUpdate, entry.Operation)
+		assert.Equal(t, []models.FieldChange{
+			{Field: models.FieldPassword, Before: "[redacted]", After: "[redacted]"},
+			{Field: models.FieldRole, Before: "user", After: "admin"},
+		}, entry.Changes)
+	}
+}
+
+func TestUpdate_FailureRecordsNothing(t *testing.T) {
+	svc, user := newHistoryService(t)
+
+	_, err := svc.Update(context.Background(), user.Id, models.User{Role: "admin"}, []string{models.FieldRole}, user.Version+1)
+
+	assert.ErrorIs(t, err, serviceerror.ErrConflict)
+	assert.Len(t, listHistory(t, svc, user.Id), 1)
+}
+
+func TestDelete_RecordsHistory(t *testing.T) {
+	svc, user := newHistoryService(t)
+
+	deleted, err := svc.Delete(context.Background(), user.Id, 0)
+	require.NoError(t, err)
+
+	entries := listHistory(t, svc, user.Id)
+	if assert.Len(t, entries, 2) {
+		entry := entries[0]
+		assert.Equal(t, models.OperationDelete, entry.Operation)
+		assert.Empty(t, entry.Actor)
+		assert.Equal(t, []models.FieldChange{
+			{Field: "deleted_at", After: deleted.DeletedAt.UTC().Format(time.RFC3339Nano)},
+		}, entry.Changes)
+	}
+}
+
+func TestRestore_RecordsDeletionTime(t *testing.T) {
+	svc, user := newHistoryService(t)
+	_, err := svc.Delete(context.Background(), user.Id, 0)
+	require.NoError(t, err)
+
+	_, err = svc.Restore(context.Background(), user.Id)
+	require.NoError(t, err)
+
+	entries := listHistory(t, svc, user.Id)
+	if assert.Len(t, entries, 3) {
+		restore, deletion := entries[0], entries[1]
+		assert.Equal(t, models.OperationRestore, restore.Operation)
+		assert.Equal(t, []models.FieldChange{
+			{Field: "deleted_at", Before: deletion.Changes[0].After},
+		}, restore.Changes)
+	}
+}
+
+func TestPurge_RecordsHistory(t *testing.T) {
+	svc, user := newHistoryService(t)
+
+	_, err := svc.Purge(context.Background(), user.Id)
+	require.NoError(t, err)
+
+	entries := listHistory(t, svc, user.Id)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.OperationPurge, entries[0].Operation)
+		assert.Contains(t, entries[0].Changes, models.FieldChange{Field: models.FieldLogin, Before: "user1"})
+	}
+}
+
+func TestListUserHistory_Pages(t *testing.T) {
+	svc, user := newHistoryService(t)
+	_, err := svc.Delete(context.Background(), user.Id, 0)
+	require.NoError(t, err)
+	_, err = svc.Restore(context.Background(), user.Id)
+	require.NoError(t, err)
+	all := listHistory(t, svc, user.Id)
+
+	first, err := svc.ListUserHistory(context.Background(), models.HistoryPageRequest{UserId: user.Id, PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, all[:2], first.Entries)
+	assert.NotEmpty(t, first.NextCursor)
+
+	second, err := svc.ListUserHistory(context.Background(), models.HistoryPageRequest{UserId: user.Id, PageSize: 2, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, all[2:], second.Entries)
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestListUserHistory_InvalidCursor(t *testing.T) {
+	svc := newTestService(new(MockUsersStorage))
+
+	_, err := svc.ListUserHistory(context.Background(), models.HistoryPageRequest{UserId: uuid.New(), Cursor: "%%%"})
+
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+}
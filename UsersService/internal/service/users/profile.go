@@ -0,0 +1,61 @@
+package usersservice
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"usersservice/internal/domain/models"
+	serviceerror "usersservice/internal/service"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	maxEmailLength       = 254
+	maxDisplayNameLength = 100
+)
+
+// normalizeLogin lowercases a login. Logins are unique whatever their case
+// and SQLite only lowercases ASCII, so they are stored lowercased already.
+func normalizeLogin(login string) string {
+	return strings.ToLower(login)
+}
+
+// normalizeProfile lowercases the login and validates the optional contact
+// fields. Locales are stored as canonical BCP 47 tags, timezones must be
+// IANA names.
+func normalizeProfile(user models.User) (models.User, error) {
+	user.Login = normalizeLogin(user.Login)
+
+	user.Email = strings.TrimSpace(user.Email)
+	if user.Email != "" {
+		addr, err := mail.ParseAddress(user.Email)
+		if err != nil || addr.Address != user.Email || len(user.Email) > maxEmailLength {
+			return models.User{}, &serviceerror.FieldError{Field: models.FieldEmail, Description: "malformed email"}
+		}
+	}
+
+	user.DisplayName = strings.TrimSpace(user.DisplayName)
+	if utf8.RuneCountInString(user.DisplayName) > maxDisplayNameLength {
+		return models.User{}, &serviceerror.FieldError{Field: models.FieldDisplayName, Description: fmt.Sprintf("longer than %d characters", maxDisplayNameLength)}
+	}
+
+	if user.Locale != "" {
+		tag, err := language.Parse(user.Locale)
+		if err != nil {
+			return models.User{}, &serviceerror.FieldError{Field: models.FieldLocale, Description: fmt.Sprintf("unknown locale %q", user.Locale)}
+		}
+		user.Locale = tag.String()
+	}
+
+	if user.Timezone != "" {
+		// "Local" would mean the timezone of whichever host reads it
+		if _, err := time.LoadLocation(user.Timezone); err != nil || user.Timezone == "Local" {
+			return models.User{}, &serviceerror.FieldError{Field: models.FieldTimezone, Description: fmt.Sprintf("unknown timezone %q", user.Timezone)}
+		}
+	}
+
+	return user, nil
+}
@@ -2,9 +2,13 @@ package usersservice
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 	"usersservice/internal/domain/models"
 	serviceerror "usersservice/internal/service"
 	storageerror "usersservice/internal/storage"
@@ -13,28 +17,73 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 type IUsersStorage interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error)
 	GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error)
-	Insert(ctx context.Context, user models.User) (models.User, error)
-	Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error)
-	Delete(ctx context.Context, uid uuid.UUID) (models.User, error)
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
+	Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error)
+	Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error)
+	Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error)
+	Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error)
+	Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error
+	SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error
+}
+
+type IPasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
 }
 
 type UsersService struct {
 	log     *slog.Logger
 	storage IUsersStorage
+	history IHistoryStorage
+	hasher  IPasswordHasher
+
+	// dummy is verified against for unknown logins. It is made with the
+	// current parameters, so it costs as much as a real hash.
+	dummy string
 }
 
-func New(log *slog.Logger, storage IUsersStorage) *UsersService {
+// New fails if the hasher cannot make the dummy hash, without it unknown
+// logins would be told apart by timing.
+func New(log *slog.Logger, storage IUsersStorage, history IHistoryStorage, hasher IPasswordHasher) (*UsersService, error) {
+	const op = "service.users.New"
+
+	dummy, err := hasher.Hash(uuid.NewString())
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot hash dummy password: %w", op, err)
+	}
+
 	return &UsersService{
 		log:     log,
 		storage: storage,
+		history: history,
+		hasher:  hasher,
+		dummy:   dummy,
+	}, nil
+}
+
+func MustNew(log *slog.Logger, storage IUsersStorage, history IHistoryStorage, hasher IPasswordHasher) *UsersService {
+	u, err := New(log, storage, history, hasher)
+	if err != nil {
+		panic(err)
 	}
+
+	return u
 }
 
 // GetUsers implements grpcapp.IUsersService.
-func (u *UsersService) GetUsers(ctx context.Context) ([]models.User, error) {
+// It returns one keyset page; NextCursor is empty on the last page.
+func (u *UsersService) GetUsers(ctx context.Context, req models.UsersPageRequest) (models.UsersPage, error) {
 	const op = "service.users.GetUsers"
 	log := u.log.With(
 		"op", op,
@@ -42,17 +91,43 @@ func (u *UsersService) GetUsers(ctx context.Context) ([]models.User, error) {
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, ctx.Err())
 	default:
 	}
 
-	users, err := u.storage.GetUsers(ctx)
+	query, err := buildUsersQuery(req)
+	if err != nil {
+		log.Warn("Invalid page request", sl.Err(err))
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pageSize := query.Limit
+	query.Limit++ // one extra row tells whether there is a next page
+
+	users, err := u.storage.GetUsers(ctx, query)
 	if err != nil {
 		log.Error("Error fetching users", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return users, nil
+	page := models.UsersPage{Users: users}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+
+		last := page.Users[pageSize-1]
+		page.NextCursor, err = encodeCursor(models.UsersCursor{
+			Id:     last.Id,
+			Login:  last.Login,
+			Sort:   query.Sort,
+			Filter: query.Filter,
+		})
+		if err != nil {
+			log.Error("Cannot encode cursor", sl.Err(err))
+			return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return page, nil
 }
 
 // GetUserById implements grpcapp.IUsersService.
@@ -82,6 +157,33 @@ func (u *UsersService) GetUserById(ctx context.Context, uid uuid.UUID) (models.U
 	return user, nil
 }
 
+// GetUserByLogin implements grpcapp.IUsersService.
+func (u *UsersService) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const op = "service.users.GetUserByLogin"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	user, err := u.storage.GetUserByLogin(ctx, normalizeLogin(login))
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
+		}
+
+		log.Error("Error fetching user by login", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
 // Insert implements grpcapp.IUsersService.
 func (u *UsersService) Insert(ctx context.Context, userForInsert models.User) (models.User, error) {
 	const op = "service.users.Insert"
@@ -95,7 +197,44 @@ func (u *UsersService) Insert(ctx context.Context, userForInsert models.User) (m
 	default:
 	}
 
-	insertedUser, err := u.storage.Insert(ctx, userForInsert)
+	// v7 ids are time-ordered, so new rows are appended to the primary key
+	// index instead of landing on random pages
+	if userForInsert.Id == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			log.Error("Cannot generate id", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		userForInsert.Id = id
+	}
+
+	userForInsert, err := normalizeProfile(userForInsert)
+	if err != nil {
+		log.Warn("Invalid profile", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// imports keep their original creation time
+	if userForInsert.CreatedAt.IsZero() {
+		userForInsert.CreatedAt = now()
+	}
+	userForInsert.UpdatedAt = userForInsert.CreatedAt
+	userForInsert.Version = 1
+
+	hashedPassword, err := u.hasher.Hash(userForInsert.Password)
+	if err != nil {
+		log.Error("Cannot hash password", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	userForInsert.Password = hashedPassword
+
+	audit, err := u.audit(ctx, models.OperationInsert, userForInsert.Id)
+	if err != nil {
+		log.Error("Cannot prepare history entry", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insertedUser, err := u.storage.Insert(ctx, userForInsert, audit)
 	if err != nil {
 		if errors.Is(err, storageerror.ErrAlreadyExists) {
 			log.Warn("User already exists", sl.Err(serviceerror.ErrAlreadyExists))
@@ -110,7 +249,10 @@ func (u *UsersService) Insert(ctx context.Context, userForInsert models.User) (m
 }
 
 // Update implements grpcapp.IUsersService.
-func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate models.User) (models.User, error) {
+// Only the given fields are changed, no fields replace the whole user but
+// keep the password if it is empty. A non-zero expectedVersion makes the
+// update fail with ErrConflict if the user has changed since it was read.
+func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate models.User, fields []string, expectedVersion int64) (models.User, error) {
 	const op = "service.users.Update"
 	log := u.log.With(
 		"op", op,
@@ -122,12 +264,48 @@ func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate
 	default:
 	}
 
-	updatedUser, err := u.storage.Update(ctx, uid, userForUpdate)
+	fields, err := updateFields(userForUpdate, fields)
+	if err != nil {
+		log.Warn("Invalid update fields", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userForUpdate, err = normalizeProfile(userForUpdate)
+	if err != nil {
+		log.Warn("Invalid profile", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	userForUpdate.UpdatedAt = now()
+
+	if slices.Contains(fields, models.FieldPassword) {
+		hashedPassword, err := u.hasher.Hash(userForUpdate.Password)
+		if err != nil {
+			log.Error("Cannot hash password", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		userForUpdate.Password = hashedPassword
+	}
+
+	audit, err := u.audit(ctx, models.OperationUpdate, uid)
+	if err != nil {
+		log.Error("Cannot prepare history entry", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updatedUser, err := u.storage.Update(ctx, uid, userForUpdate, fields, expectedVersion, audit)
 	if err != nil {
 		if errors.Is(err, storageerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
 			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
 		}
+		if errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User version has changed", sl.Err(serviceerror.ErrConflict))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrConflict)
+		}
+		if errors.Is(err, storageerror.ErrAlreadyExists) {
+			log.Warn("Login already taken", sl.Err(serviceerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrAlreadyExists)
+		}
 
 		log.Error("Error updating user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
@@ -137,7 +315,10 @@ func (u *UsersService) Update(ctx context.Context, uid uuid.UUID, userForUpdate
 }
 
 // Delete implements grpcapp.IUsersService.
-func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+// The user is soft-deleted: it disappears from reads and cannot log in, but
+// can be restored until it is purged. A non-zero expectedVersion works as
+// in Update.
+func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error) {
 	const op = "service.users.Delete"
 	log := u.log.With(
 		"op", op,
@@ -149,12 +330,22 @@ func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User,
 	default:
 	}
 
-	deletedUser, err := u.storage.Delete(ctx, uid)
+	audit, err := u.audit(ctx, models.OperationDelete, uid)
+	if err != nil {
+		log.Error("Cannot prepare history entry", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deletedUser, err := u.storage.Delete(ctx, uid, now(), expectedVersion, audit)
 	if err != nil {
 		if errors.Is(err, storageerror.ErrNotFound) {
 			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
 			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
 		}
+		if errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User version has changed", sl.Err(serviceerror.ErrConflict))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrConflict)
+		}
 
 		log.Error("Error deleting user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
@@ -162,3 +353,279 @@ func (u *UsersService) Delete(ctx context.Context, uid uuid.UUID) (models.User,
 
 	return deletedUser, nil
 }
+
+// Restore implements grpcapp.IUsersService.
+func (u *UsersService) Restore(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "service.users.Restore"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	audit, err := u.audit(ctx, models.OperationRestore, uid)
+	if err != nil {
+		log.Error("Cannot prepare history entry", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	restoredUser, err := u.storage.Restore(ctx, uid, now(), audit)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("Deleted user not found", sl.Err(serviceerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
+		}
+
+		log.Error("Error restoring user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return restoredUser, nil
+}
+
+// Purge implements grpcapp.IUsersService.
+// The user is removed for good, whether it was soft-deleted or not.
+func (u *UsersService) Purge(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "service.users.Purge"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	audit, err := u.audit(ctx, models.OperationPurge, uid)
+	if err != nil {
+		log.Error("Cannot prepare history entry", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purgedUser, err := u.storage.Purge(ctx, uid, audit)
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			log.Warn("User not found", sl.Err(serviceerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrNotFound)
+		}
+
+		log.Error("Error purging user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("User purged", slog.String("uid", uid.String()))
+
+	return purgedUser, nil
+}
+
+// PurgeDeleted removes users that were soft-deleted longer than retention
+// ago and returns their number. It works in bulk and leaves no history
+// entries, the earlier ones of the users are kept.
+func (u *UsersService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "service.users.PurgeDeleted"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	purged, err := u.storage.PurgeDeleted(ctx, now().Add(-retention))
+	if err != nil {
+		log.Error("Error purging deleted users", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// VerifyCredentials implements grpcapp.IUsersService.
+// Legacy or outdated hashes are upgraded transparently on success.
+func (u *UsersService) VerifyCredentials(ctx context.Context, login string, password string) (models.User, error) {
+	const op = "service.users.VerifyCredentials"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	verifiedUser, err := u.storage.GetUserByLogin(ctx, normalizeLogin(login))
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) {
+			// an unknown login takes as long as a wrong password, so the
+			// timing does not tell which logins exist
+			u.hasher.Verify(password, u.dummy)
+
+			log.Warn("Invalid credentials", sl.Err(serviceerror.ErrInvalidCredentials))
+			return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrInvalidCredentials)
+		}
+
+		log.Error("Error fetching user by login", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ok, err := u.hasher.Verify(password, verifiedUser.Password)
+	if err != nil {
+		log.Error("Cannot verify password", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		log.Warn("Invalid credentials", sl.Err(serviceerror.ErrInvalidCredentials))
+		return models.User{}, fmt.Errorf("%s: %w", op, serviceerror.ErrInvalidCredentials)
+	}
+
+	if u.hasher.NeedsRehash(verifiedUser.Password) {
+		u.rehash(ctx, verifiedUser, password)
+	}
+
+	// a failed write must not break the login either
+	loggedInAt := now()
+	if err := u.storage.SetLastLogin(ctx, verifiedUser.Id, loggedInAt); err != nil {
+		log.Warn("Cannot record last login", sl.Err(err))
+	} else {
+		verifiedUser.LastLoginAt = loggedInAt
+	}
+
+	return verifiedUser, nil
+}
+
+// rehash stores password hashed with the current parameters.
+// Failure must not break the login. Only the hash is replaced, and only if
+// it is still the one that was verified, so the version stays as it is and
+// a concurrent password change is never overwritten.
+func (u *UsersService) rehash(ctx context.Context, user models.User, password string) {
+	const op = "service.users.rehash"
+	log := u.log.With(
+		"op", op,
+	)
+
+	hashedPassword, err := u.hasher.Hash(password)
+	if err != nil {
+		log.Warn("Cannot hash password", sl.Err(err))
+		return
+	}
+
+	if err := u.storage.SetPassword(ctx, user.Id, user.Password, hashedPassword); err != nil {
+		log.Warn("Cannot rehash user password", sl.Err(err))
+		return
+	}
+
+	log.Info("User password rehashed", slog.String("uid", user.Id.String()))
+}
+
+// now is truncated to milliseconds, the precision every storage keeps.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func buildUsersQuery(req models.UsersPageRequest) (models.UsersQuery, error) {
+	query := models.UsersQuery{
+		Filter: req.Filter,
+		Sort:   req.Sort,
+		Limit:  req.PageSize,
+	}
+	query.Filter.LoginPrefix = normalizeLogin(query.Filter.LoginPrefix)
+
+	switch {
+	case query.Limit <= 0:
+		query.Limit = DefaultPageSize
+	case query.Limit > MaxPageSize:
+		query.Limit = MaxPageSize
+	}
+
+	switch query.Sort.By {
+	case "":
+		query.Sort.By = models.SortById
+	case models.SortById, models.SortByLogin:
+	default:
+		return models.UsersQuery{}, &serviceerror.FieldError{Field: "sort_by", Description: fmt.Sprintf("unknown sort field %q", query.Sort.By)}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return models.UsersQuery{}, &serviceerror.FieldError{Field: "cursor", Description: "malformed cursor"}
+		}
+		if cursor.Sort != query.Sort {
+			return models.UsersQuery{}, &serviceerror.FieldError{Field: "cursor", Description: "issued for another sort order"}
+		}
+		if cursor.Filter != query.Filter {
+			return models.UsersQuery{}, &serviceerror.FieldError{Field: "cursor", Description: "issued for another filter"}
+		}
+
+		query.After = &cursor
+	}
+
+	return query, nil
+}
+
+func encodeCursor(cursor models.UsersCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(token string) (models.UsersCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.UsersCursor{}, err
+	}
+
+	var cursor models.UsersCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return models.UsersCursor{}, err
+	}
+
+	return cursor, nil
+}
+
+// updateFields checks the fields of an update. No fields stand for all of
+// them except an empty password. Login, password and role cannot be
+// cleared by naming them.
+func updateFields(user models.User, fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		for _, field := range models.UpdatableFields {
+			if field != models.FieldPassword || user.Password != "" {
+				fields = append(fields, field)
+			}
+		}
+
+		return fields, nil
+	}
+
+	checked := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !slices.Contains(models.UpdatableFields, field) {
+			return nil, &serviceerror.FieldError{Field: field, Description: "unknown field"}
+		}
+		if slices.Contains(checked, field) {
+			continue
+		}
+
+		switch field {
+		case models.FieldLogin, models.FieldPassword, models.FieldRole:
+			if user.FieldValue(field) == "" {
+				return nil, &serviceerror.FieldError{Field: field, Description: "cannot be empty"}
+			}
+		}
+
+		checked = append(checked, field)
+	}
+
+	return checked, nil
+}
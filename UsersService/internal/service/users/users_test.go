@@ -2,7 +2,11 @@ package usersservice_test
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 	"usersservice/internal/domain/models"
 	serviceerror "usersservice/internal/service"
 	usersservice "usersservice/internal/service/users"
@@ -20,8 +24,8 @@ type MockUsersStorage struct {
 	mock.Mock
 }
 
-func (m *MockUsersStorage) GetUsers(ctx context.Context) ([]models.User, error) {
-	args := m.Called(ctx)
+func (m *MockUsersStorage) GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error) {
+	args := m.Called(ctx, query)
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
@@ -30,26 +34,99 @@ func (m *MockUsersStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUsersStorage) Insert(ctx context.Context, user models.User) (models.User, error) {
+func (m *MockUsersStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	args := m.Called(ctx, login)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+// The write methods leave audit out of the recorded call, history is
+// covered against the memory storage in history_test.go.
+func (m *MockUsersStorage) Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error) {
 	args := m.Called(ctx, user)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUsersStorage) Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error) {
-	args := m.Called(ctx, uid, user)
+func (m *MockUsersStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error) {
+	args := m.Called(ctx, uid, user, fields, expectedVersion)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUsersStorage) Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error) {
+	args := m.Called(ctx, uid, at, expectedVersion)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUsersStorage) Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error) {
+	args := m.Called(ctx, uid, at)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUsersStorage) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+func (m *MockUsersStorage) Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error) {
 	args := m.Called(ctx, uid)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+func (m *MockUsersStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUsersStorage) SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, uid, at)
+	return args.Error(0)
+}
+
+func (m *MockUsersStorage) SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error {
+	args := m.Called(ctx, uid, current, hashed)
+	return args.Error(0)
+}
+
+func (m *MockUsersStorage) GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]models.HistoryEntry), args.Error(1)
+}
+
+// --- Fake IPasswordHasher ---
+
+type fakeHasher struct{}
+
+func (fakeHasher) Hash(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (fakeHasher) Verify(password string, encoded string) (bool, error) {
+	return encoded == "hashed:"+password || encoded == password, nil
+}
+
+func (fakeHasher) NeedsRehash(encoded string) bool {
+	return !strings.HasPrefix(encoded, "hashed:")
+}
+
+// countingHasher records which hashes were verified.
+type countingHasher struct {
+	fakeHasher
+	verified *[]string
+}
+
+func (c countingHasher) Verify(password string, encoded string) (bool, error) {
+	*c.verified = append(*c.verified, encoded)
+	return c.fakeHasher.Verify(password, encoded)
+}
+
+// failingHasher cannot hash anything.
+type failingHasher struct {
+	fakeHasher
+}
+
+func (failingHasher) Hash(password string) (string, error) {
+	return "", errors.New("no entropy")
+}
+
 // --- Tests ---
 
 func newTestService(storage *MockUsersStorage) *usersservice.UsersService {
 	logger := logger.SetupLogger("local")
-	return usersservice.New(logger, storage)
+	return usersservice.MustNew(logger, storage, storage, fakeHasher{})
 }
 
 func TestGetUsers_Success(t *testing.T) {
@@ -58,13 +135,120 @@ func TestGetUsers_Success(t *testing.T) {
 		{Id: uuid.New(), Login: "user1"},
 		{Id: uuid.New(), Login: "user2"},
 	}
-	mockStorage.On("GetUsers", mock.Anything).Return(users, nil)
+	query := models.UsersQuery{
+		Sort:  models.UsersSort{By: models.SortById},
+		Limit: usersservice.DefaultPageSize + 1,
+	}
+	mockStorage.On("GetUsers", mock.Anything, query).Return(users, nil)
 
 	svc := newTestService(mockStorage)
-	got, err := svc.GetUsers(context.Background())
+	got, err := svc.GetUsers(context.Background(), models.UsersPageRequest{})
 
 	assert.NoError(t, err)
-	assert.Equal(t, users, got)
+	assert.Equal(t, users, got.Users)
+	assert.Empty(t, got.NextCursor)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetUsers_NextPage(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	users := []models.User{
+		{Id: uuid.New(), Login: "a"},
+		{Id: uuid.New(), Login: "b"},
+		{Id: uuid.New(), Login: "c"},
+	}
+	sort := models.UsersSort{By: models.SortByLogin, Desc: true}
+	filter := models.UsersFilter{Role: "user"}
+
+	mockStorage.On("GetUsers", mock.Anything, models.UsersQuery{Filter: filter, Sort: sort, Limit: 3}).
+		Return(users, nil).Once()
+
+	svc := newTestService(mockStorage)
+	first, err := svc.GetUsers(context.Background(), models.UsersPageRequest{Filter: filter, Sort: sort, PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, users[:2], first.Users)
+	assert.NotEmpty(t, first.NextCursor)
+
+	after := &models.UsersCursor{Id: users[1].Id, Login: users[1].Login, Sort: sort, Filter: filter}
+	mockStorage.On("GetUsers", mock.Anything, models.UsersQuery{Filter: filter, Sort: sort, After: after, Limit: 3}).
+		Return(users[2:], nil).Once()
+
+	second, err := svc.GetUsers(context.Background(), models.UsersPageRequest{Filter: filter, Sort: sort, PageSize: 2, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, users[2:], second.Users)
+	assert.Empty(t, second.NextCursor)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetUsers_PageSizeClamped(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	query := models.UsersQuery{
+		Sort:  models.UsersSort{By: models.SortById},
+		Limit: usersservice.MaxPageSize + 1,
+	}
+	mockStorage.On("GetUsers", mock.Anything, query).Return([]models.User{}, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.GetUsers(context.Background(), models.UsersPageRequest{PageSize: 10_000})
+
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetUsers_InvalidArgument(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.UsersPageRequest
+	}{
+		{"UnknownSort", models.UsersPageRequest{Sort: models.UsersSort{By: "password"}}},
+		{"MalformedCursor", models.UsersPageRequest{Cursor: "not a cursor"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockUsersStorage)
+			svc := newTestService(mockStorage)
+
+			_, err := svc.GetUsers(context.Background(), tt.req)
+			assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+			mockStorage.AssertNotCalled(t, "GetUsers", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetUsers_CursorFromAnotherSort(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	users := []models.User{{Id: uuid.New(), Login: "a"}, {Id: uuid.New(), Login: "b"}}
+	mockStorage.On("GetUsers", mock.Anything, mock.Anything).Return(users, nil).Once()
+
+	svc := newTestService(mockStorage)
+	page, err := svc.GetUsers(context.Background(), models.UsersPageRequest{PageSize: 1})
+	assert.NoError(t, err)
+
+	_, err = svc.GetUsers(context.Background(), models.UsersPageRequest{
+		Sort:     models.UsersSort{By: models.SortByLogin},
+		PageSize: 1,
+		Cursor:   page.NextCursor,
+	})
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetUsers_CursorFromAnotherFilter(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	users := []models.User{{Id: uuid.New(), Login: "alice"}, {Id: uuid.New(), Login: "alex"}}
+	mockStorage.On("GetUsers", mock.Anything, mock.Anything).Return(users, nil).Once()
+
+	svc := newTestService(mockStorage)
+	page, err := svc.GetUsers(context.Background(), models.UsersPageRequest{Filter: models.UsersFilter{LoginPrefix: "al"}, PageSize: 1})
+	assert.NoError(t, err)
+
+	_, err = svc.GetUsers(context.Background(), models.UsersPageRequest{
+		Filter:   models.UsersFilter{LoginPrefix: "b"},
+		PageSize: 1,
+		Cursor:   page.NextCursor,
+	})
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
 	mockStorage.AssertExpectations(t)
 }
 
@@ -75,8 +259,8 @@ func TestGetUsers_ContextCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	users, err := svc.GetUsers(ctx)
-	assert.Nil(t, users)
+	page, err := svc.GetUsers(ctx, models.UsersPageRequest{})
+	assert.Empty(t, page.Users)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "context canceled")
 }
@@ -107,23 +291,109 @@ func TestGetUserById_NotFound(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+func TestGetUserByLogin_NotFound(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("GetUserByLogin", mock.Anything, "user1").Return(models.User{}, storageerror.ErrNotFound)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.GetUserByLogin(context.Background(), "user1")
+
+	assert.ErrorIs(t, err, serviceerror.ErrNotFound)
+	mockStorage.AssertExpectations(t)
+}
+
 func TestInsert_Success(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
-	user := models.User{Id: uuid.New(), Login: "user1"}
-	mockStorage.On("Insert", mock.Anything, user).Return(user, nil)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "pass", Email: " user1@example.com ", Locale: "en-us"}
+	stored := models.User{Id: user.Id, Login: "user1", Password: "hashed:pass", Email: "user1@example.com", Locale: "en-US"}
+	mockStorage.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.Password == "hashed:pass" && u.Email == "user1@example.com" && u.Locale == "en-US" &&
+			!u.CreatedAt.IsZero() && u.UpdatedAt.Equal(u.CreatedAt)
+	})).Return(stored, nil)
 
 	svc := newTestService(mockStorage)
 	got, err := svc.Insert(context.Background(), user)
 
 	assert.NoError(t, err)
-	assert.Equal(t, user, got)
+	assert.Equal(t, stored, got)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestInsert_LowercasesLogin(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.Login == "ünïcode"
+	})).Return(models.User{}, nil)
+	mockStorage.On("GetUserByLogin", mock.Anything, "ünïcode").Return(models.User{}, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Insert(context.Background(), models.User{Login: "ÜNÏCODE", Password: "pass"})
+	assert.NoError(t, err)
+	_, err = svc.GetUserByLogin(context.Background(), "ÜnÏcode")
+	assert.NoError(t, err)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestInsert_ImportKeepsCreatedAt(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockStorage.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.CreatedAt.Equal(createdAt) && u.UpdatedAt.Equal(createdAt)
+	})).Return(models.User{}, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Insert(context.Background(), models.User{Id: uuid.New(), Login: "user1", Password: "pass", CreatedAt: createdAt})
+
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestInsert_InvalidProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+	}{
+		{name: "email", user: models.User{Email: "not an email"}},
+		{name: "email with name", user: models.User{Email: "Bob <bob@example.com>"}},
+		{name: "locale", user: models.User{Locale: "not_a_locale!"}},
+		{name: "timezone", user: models.User{Timezone: "Mars/Olympus"}},
+		{name: "local timezone", user: models.User{Timezone: "Local"}},
+		{name: "display name", user: models.User{DisplayName: strings.Repeat("a", 101)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockUsersStorage)
+			tt.user.Login = "user1"
+			tt.user.Password = "pass"
+
+			svc := newTestService(mockStorage)
+			_, err := svc.Insert(context.Background(), tt.user)
+
+			assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+			mockStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestInsert_AssignsTimeOrderedId(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.Id.Version() == 7 && u.Password == "hashed:pass"
+	})).Return(models.User{}, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Insert(context.Background(), models.User{Login: "user1", Password: "pass"})
+
+	assert.NoError(t, err)
 	mockStorage.AssertExpectations(t)
 }
 
 func TestInsert_AlreadyExists(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	user := models.User{Id: uuid.New(), Login: "user1"}
-	mockStorage.On("Insert", mock.Anything, user).Return(models.User{}, storageerror.ErrAlreadyExists)
+	mockStorage.On("Insert", mock.Anything, mock.Anything).Return(models.User{}, storageerror.ErrAlreadyExists)
 
 	svc := newTestService(mockStorage)
 	_, err := svc.Insert(context.Background(), user)
@@ -133,40 +403,140 @@ func TestInsert_AlreadyExists(t *testing.T) {
 }
 
 func TestUpdate_Success(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1", Password: "pass", Timezone: "Europe/Berlin"}
+	stored := user
+	stored.Password = "hashed:pass"
+	mockStorage.On("Update", mock.Anything, id, mock.MatchedBy(func(u models.User) bool {
+		return u.Password == "hashed:pass" && u.Timezone == "Europe/Berlin" && !u.UpdatedAt.IsZero()
+	}), models.UpdatableFields, int64(0)).Return(stored, nil)
+
+	svc := newTestService(mockStorage)
+	got, err := svc.Update(context.Background(), id, user, nil, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, stored, got)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdate_InvalidProfile(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Update(context.Background(), uuid.New(), models.User{Login: "user1", Email: "@"}, nil, 0)
+
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdate_KeepsPasswordWhenEmpty(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	id := uuid.New()
 	user := models.User{Id: id, Login: "user1"}
-	mockStorage.On("Update", mock.Anything, id, user).Return(user, nil)
+	stored := user
+	stored.Password = "hashed:old"
+	mockStorage.On("Update", mock.Anything, id, mock.MatchedBy(func(u models.User) bool {
+		return !u.UpdatedAt.IsZero()
+	}), mock.MatchedBy(func(fields []string) bool {
+		return !slices.Contains(fields, models.FieldPassword) && len(fields) == len(models.UpdatableFields)-1
+	}), mock.Anything).Return(stored, nil)
 
 	svc := newTestService(mockStorage)
-	got, err := svc.Update(context.Background(), id, user)
+	got, err := svc.Update(context.Background(), id, user, nil, 0)
 
 	assert.NoError(t, err)
-	assert.Equal(t, user, got)
+	assert.Equal(t, stored, got)
 	mockStorage.AssertExpectations(t)
 }
 
 func TestUpdate_NotFound(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	id := uuid.New()
-	user := models.User{Id: id, Login: "user1"}
-	mockStorage.On("Update", mock.Anything, id, user).Return(models.User{}, storageerror.ErrNotFound)
+	user := models.User{Id: id, Login: "user1", Password: "pass"}
+	mockStorage.On("Update", mock.Anything, id, mock.Anything, mock.Anything, int64(0)).Return(models.User{}, storageerror.ErrNotFound)
 
 	svc := newTestService(mockStorage)
-	_, err := svc.Update(context.Background(), id, user)
+	_, err := svc.Update(context.Background(), id, user, nil, 0)
 
 	assert.ErrorIs(t, err, serviceerror.ErrNotFound)
 	mockStorage.AssertExpectations(t)
 }
 
+func TestUpdate_LoginTaken(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "taken", Password: "pass"}
+	mockStorage.On("Update", mock.Anything, id, mock.Anything, mock.Anything, int64(0)).Return(models.User{}, storageerror.ErrAlreadyExists)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Update(context.Background(), id, user, nil, 0)
+
+	assert.ErrorIs(t, err, serviceerror.ErrAlreadyExists)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdate_Conflict(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1", Password: "pass"}
+	mockStorage.On("Update", mock.Anything, id, mock.Anything, mock.Anything, int64(2)).Return(models.User{}, storageerror.ErrConflict).Once()
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Update(context.Background(), id, user, nil, 2)
+
+	assert.ErrorIs(t, err, serviceerror.ErrConflict)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdate_OnlyMaskedFields(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	user := models.User{Role: "admin"}
+	stored := models.User{Id: id, Login: "user1", Role: "admin", Version: 2}
+	mockStorage.On("Update", mock.Anything, id, mock.Anything, []string{models.FieldRole}, int64(0)).Return(stored, nil)
+
+	svc := newTestService(mockStorage)
+	got, err := svc.Update(context.Background(), id, user, []string{models.FieldRole, models.FieldRole}, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, stored, got)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdate_InvalidMask(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Update(context.Background(), uuid.New(), models.User{}, []string{"created_at"}, 0)
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+
+	_, err = svc.Update(context.Background(), uuid.New(), models.User{}, []string{models.FieldLogin}, 0)
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidArgument)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestInsert_StartsAtVersionOne(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("Insert", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.Version == 1
+	})).Return(models.User{Version: 1}, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Insert(context.Background(), models.User{Login: "user1", Password: "pass"})
+
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+}
+
 func TestDelete_Success(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	id := uuid.New()
-	user := models.User{Id: id, Login: "user1"}
-	mockStorage.On("Delete", mock.Anything, id).Return(user, nil)
+	user := models.User{Id: id, Login: "user1", DeletedAt: time.Now()}
+	mockStorage.On("Delete", mock.Anything, id, mock.AnythingOfType("time.Time"), int64(0)).Return(user, nil)
 
 	svc := newTestService(mockStorage)
-	got, err := svc.Delete(context.Background(), id)
+	got, err := svc.Delete(context.Background(), id, 0)
 
 	assert.NoError(t, err)
 	assert.Equal(t, user, got)
@@ -176,11 +546,158 @@ func TestDelete_Success(t *testing.T) {
 func TestDelete_NotFound(t *testing.T) {
 	mockStorage := new(MockUsersStorage)
 	id := uuid.New()
-	mockStorage.On("Delete", mock.Anything, id).Return(models.User{}, storageerror.ErrNotFound)
+	mockStorage.On("Delete", mock.Anything, id, mock.AnythingOfType("time.Time"), int64(0)).Return(models.User{}, storageerror.ErrNotFound)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Delete(context.Background(), id, 0)
+
+	assert.ErrorIs(t, err, serviceerror.ErrNotFound)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestDelete_Conflict(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	mockStorage.On("Delete", mock.Anything, id, mock.AnythingOfType("time.Time"), int64(4)).Return(models.User{}, storageerror.ErrConflict)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Delete(context.Background(), id, 4)
+
+	assert.ErrorIs(t, err, serviceerror.ErrConflict)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestRestore_Success(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1"}
+	mockStorage.On("Restore", mock.Anything, id, mock.AnythingOfType("time.Time")).Return(user, nil)
+
+	svc := newTestService(mockStorage)
+	got, err := svc.Restore(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestRestore_NotFound(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	mockStorage.On("Restore", mock.Anything, id, mock.AnythingOfType("time.Time")).Return(models.User{}, storageerror.ErrNotFound)
 
 	svc := newTestService(mockStorage)
-	_, err := svc.Delete(context.Background(), id)
+	_, err := svc.Restore(context.Background(), id)
 
 	assert.ErrorIs(t, err, serviceerror.ErrNotFound)
 	mockStorage.AssertExpectations(t)
 }
+
+func TestPurge_NotFound(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	id := uuid.New()
+	mockStorage.On("Purge", mock.Anything, id).Return(models.User{}, storageerror.ErrNotFound)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.Purge(context.Background(), id)
+
+	assert.ErrorIs(t, err, serviceerror.ErrNotFound)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestPurgeDeleted_UsesRetention(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	retention := 24 * time.Hour
+	cutoff := time.Now().Add(-retention)
+	mockStorage.On("PurgeDeleted", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return before.Sub(cutoff).Abs() < time.Minute
+	})).Return(int64(3), nil)
+
+	svc := newTestService(mockStorage)
+	purged, err := svc.PurgeDeleted(context.Background(), retention)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_Success(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "hashed:pass"}
+	mockStorage.On("GetUserByLogin", mock.Anything, "user1").Return(user, nil)
+	mockStorage.On("SetLastLogin", mock.Anything, user.Id, mock.AnythingOfType("time.Time")).Return(nil)
+
+	svc := newTestService(mockStorage)
+	got, err := svc.VerifyCredentials(context.Background(), "user1", "pass")
+
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, got.Id)
+	assert.False(t, got.LastLoginAt.IsZero())
+	mockStorage.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_LastLoginFailureIgnored(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "hashed:pass"}
+	mockStorage.On("GetUserByLogin", mock.Anything, "user1").Return(user, nil)
+	mockStorage.On("SetLastLogin", mock.Anything, user.Id, mock.Anything).Return(errors.New("db is down"))
+
+	svc := newTestService(mockStorage)
+	got, err := svc.VerifyCredentials(context.Background(), "user1", "pass")
+
+	assert.NoError(t, err)
+	assert.True(t, got.LastLoginAt.IsZero())
+	mockStorage.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_WrongPassword(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "hashed:pass"}
+	mockStorage.On("GetUserByLogin", mock.Anything, "user1").Return(user, nil)
+
+	svc := newTestService(mockStorage)
+	_, err := svc.VerifyCredentials(context.Background(), "user1", "wrong")
+
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidCredentials)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestVerifyCredentials_UnknownLogin(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	mockStorage.On("GetUserByLogin", mock.Anything, "nobody").Return(models.User{}, storageerror.ErrNotFound)
+
+	verified := []string{}
+	svc := usersservice.MustNew(logger.SetupLogger("local"), mockStorage, mockStorage, countingHasher{verified: &verified})
+	_, err := svc.VerifyCredentials(context.Background(), "nobody", "pass")
+
+	assert.ErrorIs(t, err, serviceerror.ErrInvalidCredentials)
+	// a dummy hash is verified, so unknown logins are not faster
+	if assert.Len(t, verified, 1) {
+		assert.True(t, strings.HasPrefix(verified[0], "hashed:"))
+	}
+	mockStorage.AssertExpectations(t)
+}
+
+func TestNew_DummyHashFailure(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+
+	_, err := usersservice.New(logger.SetupLogger("local"), mockStorage, mockStorage, failingHasher{})
+
+	assert.Error(t, err)
+}
+
+func TestVerifyCredentials_RehashesLegacyPlaintext(t *testing.T) {
+	mockStorage := new(MockUsersStorage)
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "pass", Version: 3}
+	mockStorage.On("GetUserByLogin", mock.Anything, "user1").Return(user, nil)
+	// only the verified hash is replaced, the version stays as it is
+	mockStorage.On("SetPassword", mock.Anything, user.Id, "pass", "hashed:pass").Return(nil)
+	mockStorage.On("SetLastLogin", mock.Anything, user.Id, mock.Anything).Return(nil)
+
+	svc := newTestService(mockStorage)
+	got, err := svc.VerifyCredentials(context.Background(), "user1", "pass")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), got.Version)
+	mockStorage.AssertExpectations(t)
+}
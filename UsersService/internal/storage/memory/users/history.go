@@ -0,0 +1,52 @@
+package usersmemorystorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"usersservice/internal/domain/models"
+
+	"github.com/google/uuid"
+)
+
+// addHistory appends the entry audit builds for a write, callers must hold
+// mu. Entries are kept per user in the order they were added, which is id
+// order.
+func (u *UsersMemoryStorage) addHistory(audit models.Audit, before models.User, after models.User) {
+	if audit == nil {
+		return
+	}
+
+	entry := audit(before, after)
+	entry.Changes = slices.Clone(entry.Changes)
+	u.history[entry.UserId] = append(u.history[entry.UserId], entry)
+}
+
+// GetUserHistory implements app.IUsersStorage.
+func (u *UsersMemoryStorage) GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error) {
+	const op = "storage.memory.users.GetUserHistory"
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	stored := u.history[query.UserId]
+	entries := make([]models.HistoryEntry, 0, min(len(stored), query.Limit))
+	for i := len(stored) - 1; i >= 0 && len(entries) < query.Limit; i-- {
+		entry := stored[i]
+		if query.After != uuid.Nil && bytes.Compare(entry.Id[:], query.After[:]) >= 0 {
+			continue
+		}
+
+		entry.Changes = slices.Clone(entry.Changes)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,402 @@
+package usersmemorystorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"usersservice/internal/domain/models"
+	storageerror "usersservice/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// UsersMemoryStorage keeps users in process memory. It follows the
+// semantics of the database storages, logins are unique case-insensitively
+// and stay taken by soft-deleted users until they are purged, so
+// UsersService can run in development and tests without a database.
+// Everything is lost on restart.
+type UsersMemoryStorage struct {
+	log     *slog.Logger
+	mu      sync.RWMutex
+	users   map[uuid.UUID]models.User
+	byLogin map[string]uuid.UUID
+	history map[uuid.UUID][]models.HistoryEntry
+}
+
+func New(log *slog.Logger) *UsersMemoryStorage {
+	return &UsersMemoryStorage{
+		log:     log,
+		users:   make(map[uuid.UUID]models.User),
+		byLogin: make(map[string]uuid.UUID),
+		history: make(map[uuid.UUID][]models.HistoryEntry),
+	}
+}
+
+func (u *UsersMemoryStorage) Close() {}
+
+// GetUsers implements app.IUsersStorage.
+func (u *UsersMemoryStorage) GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error) {
+	const op = "storage.memory.users.GetUsers"
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.RLock()
+	users := make([]models.User, 0, len(u.users))
+	for _, user := range u.users {
+		if matches(user, query) {
+			users = append(users, user)
+		}
+	}
+	u.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		return compare(users[i], users[j], query.Sort) < 0
+	})
+
+	if query.Limit > 0 && len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+
+	return users, nil
+}
+
+// matches applies the filter and the keyset position of query.
+func matches(user models.User, query models.UsersQuery) bool {
+	if !user.DeletedAt.IsZero() {
+		return false
+	}
+	if query.Filter.Role != "" && user.Role != query.Filter.Role {
+		return false
+	}
+	if query.Filter.LoginPrefix != "" && !strings.HasPrefix(strings.ToLower(user.Login), strings.ToLower(query.Filter.LoginPrefix)) {
+		return false
+	}
+
+	if query.After != nil {
+		after := models.User{Id: query.After.Id, Login: query.After.Login}
+		if compare(user, after, query.Sort) <= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compare orders users the way the sort asks for, id breaks ties.
+func compare(a models.User, b models.User, sort models.UsersSort) int {
+	c := 0
+	if sort.By == models.SortByLogin {
+		c = strings.Compare(a.Login, b.Login)
+	}
+	if c == 0 {
+		c = bytes.Compare(a.Id[:], b.Id[:])
+	}
+
+	if sort.Desc {
+		return -c
+	}
+
+	return c
+}
+
+// GetUserById implements app.IUsersStorage.
+func (u *UsersMemoryStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "storage.memory.users.GetUserById"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.active(uid)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	return user, nil
+}
+
+// GetUserByLogin implements app.IUsersStorage.
+func (u *UsersMemoryStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const op = "storage.memory.users.GetUserByLogin"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.active(u.byLogin[strings.ToLower(login)])
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	return user, nil
+}
+
+// Insert implements app.IUsersStorage.
+func (u *UsersMemoryStorage) Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error) {
+	const op = "storage.memory.users.Insert"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, idTaken := u.users[user.Id]
+	_, loginTaken := u.byLogin[strings.ToLower(user.Login)]
+	if idTaken || loginTaken {
+		log.Warn("User with current id or login already exists")
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+	}
+
+	u.users[user.Id] = user
+	u.byLogin[strings.ToLower(user.Login)] = user.Id
+	u.addHistory(audit, models.User{}, user)
+
+	return user, nil
+}
+
+// Update implements app.IUsersStorage.
+// Only the given fields and updated_at are written, the stored user is returned.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersMemoryStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error) {
+	const op = "storage.memory.users.Update"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.active(uid)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		log.Warn("User version has changed")
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	updated := current
+	updated.CopyFields(user, fields)
+	updated.UpdatedAt = user.UpdatedAt
+	updated.Version++
+
+	login := strings.ToLower(updated.Login)
+	if owner, taken := u.byLogin[login]; taken && owner != uid {
+		log.Warn("User with current login already exists")
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+	}
+
+	delete(u.byLogin, strings.ToLower(current.Login))
+	u.users[uid] = updated
+	u.byLogin[login] = uid
+	u.addHistory(audit, current, updated)
+
+	return updated, nil
+}
+
+// SetLastLogin implements app.IUsersStorage.
+func (u *UsersMemoryStorage) SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error {
+	const op = "storage.memory.users.SetLastLogin"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.active(uid)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	user.LastLoginAt = at
+	u.users[uid] = user
+
+	return nil
+}
+
+// SetPassword implements app.IUsersStorage.
+// Only the hash changes, the version and updated_at are left alone.
+func (u *UsersMemoryStorage) SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error {
+	const op = "storage.memory.users.SetPassword"
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.active(uid)
+	if !ok || user.Password != current {
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	user.Password = hashed
+	u.users[uid] = user
+
+	return nil
+}
+
+// Delete implements app.IUsersStorage.
+// The user is only marked as deleted, Restore brings it back until it is purged.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersMemoryStorage) Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error) {
+	const op = "storage.memory.users.Delete"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	before, ok := u.active(uid)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	user := before
+	user.DeletedAt = at
+	user.UpdatedAt = at
+	user.Version++
+	u.users[uid] = user
+	u.addHistory(audit, before, user)
+
+	return user, nil
+}
+
+// Restore implements app.IUsersStorage.
+func (u *UsersMemoryStorage) Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error) {
+	const op = "storage.memory.users.Restore"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	before, ok := u.users[uid]
+	if !ok || before.DeletedAt.IsZero() {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	user := before
+	user.DeletedAt = time.Time{}
+	user.UpdatedAt = at
+	user.Version++
+	u.users[uid] = user
+	u.addHistory(audit, before, user)
+
+	return user, nil
+}
+
+// Purge implements app.IUsersStorage.
+func (u *UsersMemoryStorage) Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error) {
+	const op = "storage.memory.users.Purge"
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[uid]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	u.remove(user)
+	u.addHistory(audit, user, models.User{})
+
+	return user, nil
+}
+
+// PurgeDeleted implements app.IUsersStorage.
+func (u *UsersMemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.memory.users.PurgeDeleted"
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var purged int64
+	for _, user := range u.users {
+		if !user.DeletedAt.IsZero() && user.DeletedAt.Before(before) {
+			u.remove(user)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// active returns the user unless it is missing or soft-deleted, callers
+// must hold mu.
+func (u *UsersMemoryStorage) active(uid uuid.UUID) (models.User, bool) {
+	user, ok := u.users[uid]
+	if !ok || !user.DeletedAt.IsZero() {
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// remove drops the user from both indexes, callers must hold mu.
+func (u *UsersMemoryStorage) remove(user models.User) {
+	delete(u.users, user.Id)
+	delete(u.byLogin, strings.ToLower(user.Login))
+}
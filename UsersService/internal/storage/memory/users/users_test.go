@@ -0,0 +1,367 @@
+package usersmemorystorage_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"usersservice/internal/domain/models"
+	storageerror "usersservice/internal/storage"
+	usersmemorystorage "usersservice/internal/storage/memory/users"
+	"usersservice/pkg/lib/logger"
+
+	"github.com/google/uuid"
+)
+
+func newTestStorage(t *testing.T, users ...models.User) *usersmemorystorage.UsersMemoryStorage {
+	storage := usersmemorystorage.New(logger.SetupLogger("local"))
+	for _, user := range users {
+		if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	return storage
+}
+
+func logins(users []models.User) []string {
+	res := make([]string, 0, len(users))
+	for _, user := range users {
+		res = append(res, user.Login)
+	}
+	return res
+}
+
+func TestGetUsers_FilterSortAndKeyset(t *testing.T) {
+	storage := newTestStorage(t,
+		models.User{Id: uuid.New(), Login: "carol", Role: "user"},
+		models.User{Id: uuid.New(), Login: "Alice", Role: "admin"},
+		models.User{Id: uuid.New(), Login: "alfred", Role: "user"},
+		models.User{Id: uuid.New(), Login: "bob", Role: "user"},
+	)
+
+	sortByLogin := models.UsersSort{By: models.SortByLogin}
+	page, err := storage.GetUsers(context.Background(), models.UsersQuery{Sort: sortByLogin, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := logins(page); len(got) != 2 || got[0] != "Alice" || got[1] != "alfred" {
+		t.Errorf("unexpected first page: %v", got)
+	}
+
+	last := page[len(page)-1]
+	page, err = storage.GetUsers(context.Background(), models.UsersQuery{
+		Sort:  sortByLogin,
+		After: &models.UsersCursor{Id: last.Id, Login: last.Login, Sort: sortByLogin},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := logins(page); len(got) != 2 || got[0] != "bob" || got[1] != "carol" {
+		t.Errorf("unexpected second page: %v", got)
+	}
+
+	page, err = storage.GetUsers(context.Background(), models.UsersQuery{
+		Filter: models.UsersFilter{Role: "user", LoginPrefix: "AL"},
+		Sort:   models.UsersSort{By: models.SortByLogin, Desc: true},
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := logins(page); len(got) != 1 || got[0] != "alfred" {
+		t.Errorf("unexpected filtered page: %v", got)
+	}
+}
+
+func TestGetUserByLogin_CaseInsensitive(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "Alice"}
+	storage := newTestStorage(t, user)
+
+	got, err := storage.GetUserByLogin(context.Background(), "aLiCe")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Id != user.Id {
+		t.Errorf("expected %v, got %v", user.Id, got.Id)
+	}
+
+	_, err = storage.GetUserByLogin(context.Background(), "bob")
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInsert_AlreadyExists(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice"}
+	storage := newTestStorage(t, user)
+
+	_, err := storage.Insert(context.Background(), models.User{Id: user.Id, Login: "bob"}, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for taken id, got %v", err)
+	}
+
+	_, err = storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "ALICE"}, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for taken login, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	createdAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	alice := models.User{Id: uuid.New(), Login: "alice"}
+	bob := models.User{Id: uuid.New(), Login: "bob", CreatedAt: createdAt, LastLoginAt: createdAt}
+	storage := newTestStorage(t, alice, bob)
+
+	_, err := storage.Update(context.Background(), bob.Id, models.User{Login: "Alice"}, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	updated, err := storage.Update(context.Background(), bob.Id, models.User{Login: "robert", Role: "admin"}, models.UpdatableFields, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Id != bob.Id {
+		t.Errorf("expected id %v, got %v", bob.Id, updated.Id)
+	}
+	if !updated.CreatedAt.Equal(createdAt) || !updated.LastLoginAt.Equal(createdAt) {
+		t.Errorf("created_at and last_login_at must be kept, got %v", updated)
+	}
+
+	if _, err := storage.GetUserByLogin(context.Background(), "bob"); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("old login must be released, got %v", err)
+	}
+
+	_, err = storage.Update(context.Background(), uuid.New(), models.User{Login: "carol"}, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUpdate_Versions(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice", Version: 1}
+	storage := newTestStorage(t, user)
+
+	updated, err := storage.Update(context.Background(), user.Id, models.User{Login: "alice", Role: "admin"}, models.UpdatableFields, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("expected version 2, got %d", updated.Version)
+	}
+
+	_, err = storage.Update(context.Background(), user.Id, models.User{Login: "alice"}, models.UpdatableFields, 1, nil)
+	if !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if _, err := storage.Delete(context.Background(), user.Id, time.Now(), 1, nil); !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestUpdate_Fields(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Email: "alice@example.com"}
+	storage := newTestStorage(t, user)
+
+	updated, err := storage.Update(context.Background(), user.Id, models.User{Role: "admin"}, []string{models.FieldRole}, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Role != "admin" || updated.Login != "alice" || updated.Password != "hash" || updated.Email != "alice@example.com" {
+		t.Errorf("only the role must change, got %v", updated)
+	}
+	if _, err := storage.GetUserByLogin(context.Background(), "alice"); err != nil {
+		t.Errorf("login must stay taken, got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice"}
+	storage := newTestStorage(t, user)
+
+	at := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	deleted, err := storage.Delete(context.Background(), user.Id, at, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted.Id != user.Id || !deleted.DeletedAt.Equal(at) {
+		t.Errorf("unexpected deleted user: %v", deleted)
+	}
+
+	if _, err := storage.GetUserById(context.Background(), user.Id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := storage.GetUserByLogin(context.Background(), "alice"); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if users, _ := storage.GetUsers(context.Background(), models.UsersQuery{Limit: 10}); len(users) != 0 {
+		t.Errorf("expected no users, got %v", users)
+	}
+	if _, err := storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "ALICE"}, nil); !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("login of a deleted user must stay taken, got %v", err)
+	}
+	if _, err := storage.Delete(context.Background(), user.Id, at, 0, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	restored, err := storage.Restore(context.Background(), user.Id, at.Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !restored.DeletedAt.IsZero() {
+		t.Errorf("expected deleted_at to be cleared, got %v", restored.DeletedAt)
+	}
+	if _, err := storage.GetUserByLogin(context.Background(), "alice"); err != nil {
+		t.Errorf("restored user must be readable, got %v", err)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	old := models.User{Id: uuid.New(), Login: "alice", DeletedAt: time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)}
+	recent := models.User{Id: uuid.New(), Login: "bob", DeletedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	active := models.User{Id: uuid.New(), Login: "carol"}
+	storage := newTestStorage(t, old, recent, active)
+
+	purged, err := storage.PurgeDeleted(context.Background(), time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged user, got %d", purged)
+	}
+
+	// the purged login is free again
+	if _, err := storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "alice"}, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := storage.Restore(context.Background(), recent.Id, time.Now(), nil); err != nil {
+		t.Errorf("recently deleted user must survive, got %v", err)
+	}
+	if _, err := storage.Purge(context.Background(), active.Id, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := storage.GetUserById(context.Background(), active.Id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetLastLogin(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice"}
+	storage := newTestStorage(t, user)
+
+	at := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	if err := storage.SetLastLogin(context.Background(), user.Id, at); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := storage.GetUserById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.LastLoginAt.Equal(at) {
+		t.Errorf("expected last login %v, got %v", at, got.LastLoginAt)
+	}
+
+	if err := storage.SetLastLogin(context.Background(), uuid.New(), at); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetPassword_KeepsVersion(t *testing.T) {
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "old", Version: 3}
+	storage := newTestStorage(t, user)
+
+	if err := storage.SetPassword(context.Background(), user.Id, "old", "new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := storage.GetUserById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Password != "new" || got.Version != 3 {
+		t.Errorf("expected new password at version 3, got %q at %d", got.Password, got.Version)
+	}
+
+	if err := storage.SetPassword(context.Background(), user.Id, "old", "newer"); !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestInsert_ConcurrentSameLogin(t *testing.T) {
+	storage := newTestStorage(t)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "alice"}, nil); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("expected exactly one insert to succeed, got %d", created)
+	}
+}
+
+// audit builds entries that keep the role before and after a write.
+func audit(uid uuid.UUID, operation string) models.Audit {
+	id := uuid.Must(uuid.NewV7())
+	return func(before models.User, after models.User) models.HistoryEntry {
+		return models.HistoryEntry{
+			Id:        id,
+			UserId:    uid,
+			Operation: operation,
+			Changes:   []models.FieldChange{{Field: models.FieldRole, Before: before.Role, After: after.Role}},
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	user := models.User{Id: uuid.New(), Login: "alice", Role: "user", Version: 1}
+
+	if _, err := storage.Insert(ctx, user, audit(user.Id, models.OperationInsert)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, role := range []string{"admin", "user"} {
+		if _, err := storage.Update(ctx, user.Id, models.User{Role: role}, []string{models.FieldRole}, 0, audit(user.Id, models.OperationUpdate)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// a failed write leaves no entry
+	if _, err := storage.Update(ctx, user.Id, models.User{Role: "admin"}, []string{models.FieldRole}, 1, audit(user.Id, models.OperationUpdate)); !errors.Is(err, storageerror.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	first, err := storage.GetUserHistory(ctx, models.HistoryQuery{UserId: user.Id, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 2 || first[0].Changes[0] != (models.FieldChange{Field: models.FieldRole, Before: "admin", After: "user"}) {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	second, err := storage.GetUserHistory(ctx, models.HistoryQuery{UserId: user.Id, After: first[1].Id, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(second) != 1 || second[0].Operation != models.OperationInsert || second[0].Changes[0].Before != "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+}
@@ -0,0 +1,64 @@
+package usersmongostorage
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+var tUUID = reflect.TypeOf(uuid.UUID{})
+
+// newRegistry stores uuid.UUID as BSON binary subtype 4, the standard UUID
+// representation. The default codec would write it as a plain byte array,
+// which other drivers and tools do not recognise as a UUID.
+func newRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeEncoder(tUUID, bsoncodec.ValueEncoderFunc(encodeUUID))
+	registry.RegisterTypeDecoder(tUUID, bsoncodec.ValueDecoderFunc(decodeUUID))
+
+	return registry
+}
+
+func encodeUUID(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tUUID {
+		return bsoncodec.ValueEncoderError{Name: "encodeUUID", Types: []reflect.Type{tUUID}, Received: val}
+	}
+
+	id := val.Interface().(uuid.UUID)
+	return vw.WriteBinaryWithSubtype(id[:], bson.TypeBinaryUUID)
+}
+
+func decodeUUID(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tUUID {
+		return bsoncodec.ValueDecoderError{Name: "decodeUUID", Types: []reflect.Type{tUUID}, Received: val}
+	}
+
+	var id uuid.UUID
+	switch vr.Type() {
+	case bson.TypeBinary:
+		data, subtype, err := vr.ReadBinary()
+		if err != nil {
+			return err
+		}
+		// subtype 0 is what the default codec wrote before, keep reading it
+		if subtype != bson.TypeBinaryUUID && subtype != bson.TypeBinaryGeneric {
+			return fmt.Errorf("cannot decode binary subtype %#x into a UUID", subtype)
+		}
+		if id, err = uuid.FromBytes(data); err != nil {
+			return err
+		}
+	case bson.TypeNull:
+		if err := vr.ReadNull(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot decode %v into a UUID", vr.Type())
+	}
+
+	val.Set(reflect.ValueOf(id))
+	return nil
+}
@@ -0,0 +1,104 @@
+package usersmongostorage
+
+import (
+	"testing"
+	"time"
+	"usersservice/internal/domain/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUUIDCodec_RoundTrip(t *testing.T) {
+	registry := newRegistry()
+	user := models.User{Id: uuid.New(), Login: "alice", Role: "user"}
+
+	data, err := bson.MarshalWithRegistry(registry, user)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	subtype, bytes := bson.Raw(data).Lookup("id").Binary()
+	if subtype != bson.TypeBinaryUUID {
+		t.Errorf("expected binary subtype %#x, got %#x", bson.TypeBinaryUUID, subtype)
+	}
+	if string(bytes) != string(user.Id[:]) {
+		t.Errorf("unexpected id bytes %x", bytes)
+	}
+
+	var decoded models.User
+	if err := bson.UnmarshalWithRegistry(registry, data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded != user {
+		t.Errorf("expected %v, got %v", user, decoded)
+	}
+}
+
+func TestUUIDCodec_ReadsLegacyGenericBinary(t *testing.T) {
+	id := uuid.New()
+
+	// documents written by the default codec store the id as subtype 0
+	data, err := bson.Marshal(bson.D{{Key: "id", Value: primitive.Binary{Subtype: 0x00, Data: id[:]}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded models.User
+	if err := bson.UnmarshalWithRegistry(newRegistry(), data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Id != id {
+		t.Errorf("expected %v, got %v", id, decoded.Id)
+	}
+
+	data, err = bson.Marshal(bson.D{{Key: "id", Value: primitive.Binary{Subtype: 0x80, Data: id[:]}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bson.UnmarshalWithRegistry(newRegistry(), data, &decoded); err == nil {
+		t.Error("expected an error for a user defined subtype")
+	}
+}
+
+func TestUserDocument_ProfileFields(t *testing.T) {
+	registry := newRegistry()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{
+		Id:          uuid.New(),
+		Login:       "alice",
+		DisplayName: "Alice",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	data, err := bson.MarshalWithRegistry(registry, user)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw := bson.Raw(data)
+	if got := raw.Lookup("display_name").StringValue(); got != "Alice" {
+		t.Errorf("expected display_name Alice, got %q", got)
+	}
+	if got := raw.Lookup("created_at").Time(); !got.Equal(now) {
+		t.Errorf("expected created_at %v, got %v", now, got)
+	}
+	// a user who never logged in has no last_login_at at all
+	if _, err := raw.LookupErr("last_login_at"); err == nil {
+		t.Errorf("expected last_login_at to be omitted")
+	}
+	// active users must match the {deleted_at: null} filter
+	if _, err := raw.LookupErr("deleted_at"); err == nil {
+		t.Errorf("expected deleted_at to be omitted")
+	}
+
+	var decoded models.User
+	if err := bson.UnmarshalWithRegistry(registry, data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(now) || !decoded.LastLoginAt.IsZero() {
+		t.Errorf("unexpected timestamps: %v", decoded)
+	}
+}
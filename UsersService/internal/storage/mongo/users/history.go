@@ -0,0 +1,91 @@
+package usersmongostorage
+
+import (
+	"context"
+	"fmt"
+	"usersservice/internal/domain/models"
+	"usersservice/pkg/lib/logger/sl"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// historyCollectionName lives next to the users collection. Nothing in the
+// storage updates or deletes its documents.
+const historyCollectionName = "user_history"
+
+func (u *UsersMongoStorage) historyCollection() *mongo.Collection {
+	return u.client.Database(u.databaseName).Collection(historyCollectionName)
+}
+
+// ensureHistoryIndexes mirrors the user_history migration.
+func (u *UsersMongoStorage) ensureHistoryIndexes(ctx context.Context) error {
+	_, err := u.historyCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetName("user_history_id_key").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "id", Value: -1}},
+			Options: options.Index().SetName("user_history_user_id_idx"),
+		},
+	})
+
+	return err
+}
+
+// addHistory stores the entry audit builds for a write, inside the write's
+// transaction.
+func (u *UsersMongoStorage) addHistory(ctx context.Context, audit models.Audit, before models.User, after models.User) error {
+	if audit == nil {
+		return nil
+	}
+
+	entry := audit(before, after)
+	if entry.Changes == nil {
+		entry.Changes = []models.FieldChange{}
+	}
+
+	if _, err := u.historyCollection().InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("inserting history entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserHistory implements usersservice.IUsersStorage.
+func (u *UsersMongoStorage) GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error) {
+	const op = "storage.mongo.users.GetUserHistory"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	filter := bson.M{"user_id": query.UserId}
+	if query.After != uuid.Nil {
+		filter["id"] = bson.M{"$lt": query.After}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}}).SetLimit(int64(query.Limit))
+
+	cursor, err := u.historyCollection().Find(ctx, filter, opts)
+	if err != nil {
+		log.Error("Error fetching history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	entries := make([]models.HistoryEntry, 0, query.Limit)
+	if err := cursor.All(ctx, &entries); err != nil {
+		log.Error("Error decode history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
@@ -2,43 +2,141 @@ package usersmongostorage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"time"
 	"usersservice/internal/domain/models"
 	storageerror "usersservice/internal/storage"
 	"usersservice/pkg/lib/logger/sl"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// loginCollation makes login comparisons case-insensitive.
+var loginCollation = &options.Collation{Locale: "en", Strength: 2}
+
+const connectTimeout = 10 * time.Second
+
+// Config describes the connection. URI takes precedence over Host and Port
+// and may carry any driver option (replicaSet, tls, ...). Credentials are
+// kept out of the URI so they can come from secrets. Writes run in
+// transactions together with their history entries, so the server must be
+// a replica set or a sharded cluster.
+type Config struct {
+	URI            string
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	AuthSource     string
+	DatabaseName   string
+	CollectionName string
+}
+
 type UsersMongoStorage struct {
-	log    *slog.Logger
-	client *mongo.Client
-	databaseName string
+	log            *slog.Logger
+	client         *mongo.Client
+	databaseName   string
 	collectionName string
 }
 
-func New(log *slog.Logger, host string, port int, databaseName string, collectionName string) *UsersMongoStorage {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(
-		fmt.Sprintf("mongodb://%s:%d", host, port),
-	))
+func New(log *slog.Logger, cfg Config) *UsersMongoStorage {
+	uri := cfg.URI
+	if uri == "" {
+		uri = fmt.Sprintf("mongodb://%s:%d", cfg.Host, cfg.Port)
+	}
+
+	opts := options.Client().ApplyURI(uri).SetRegistry(newRegistry())
+	if cfg.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username:   cfg.Username,
+			Password:   cfg.Password,
+			AuthSource: cfg.AuthSource,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := client.Ping(context.Background(), nil); err != nil {
+	if err := client.Ping(ctx, nil); err != nil {
 		panic(err)
 	}
 
-	return &UsersMongoStorage{
-		log:    log,
-		client: client,
-		databaseName: databaseName,
-		collectionName: collectionName,
+	u := &UsersMongoStorage{
+		log:            log,
+		client:         client,
+		databaseName:   cfg.DatabaseName,
+		collectionName: cfg.CollectionName,
+	}
+
+	if err := u.ensureIndexes(ctx); err != nil {
+		panic(err)
+	}
+	if err := u.ensureHistoryIndexes(ctx); err != nil {
+		panic(err)
+	}
+	if err := u.backfillVersions(ctx); err != nil {
+		panic(err)
 	}
+
+	return u
+}
+
+// ensureIndexes creates the indexes the Postgres migrations define: unique
+// id, unique case-insensitive login and the listing indexes.
+func (u *UsersMongoStorage) ensureIndexes(ctx context.Context) error {
+	_, err := u.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetName("users_id_key").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "login", Value: 1}},
+			Options: options.Index().SetName("users_login_lower_key").SetUnique(true).SetCollation(loginCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "login", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetName("users_login_id_idx"),
+		},
+		{
+			Keys:    bson.D{{Key: "role", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetName("users_role_id_idx"),
+		},
+		{
+			Keys: bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("users_deleted_at_idx").
+				SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$exists": true}}),
+		},
+	})
+
+	return err
+}
+
+// backfillVersions gives documents written before versioning version 1,
+// as the Postgres migration does with its column default.
+func (u *UsersMongoStorage) backfillVersions(ctx context.Context) error {
+	_, err := u.collection().UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+
+	return err
+}
+
+func (u *UsersMongoStorage) collection() *mongo.Collection {
+	return u.client.Database(u.databaseName).Collection(u.collectionName)
 }
 
 func (u *UsersMongoStorage) Close() {
@@ -48,7 +146,7 @@ func (u *UsersMongoStorage) Close() {
 }
 
 // GetUsers implements usersservice.IUsersStorage.
-func (u *UsersMongoStorage) GetUsers(ctx context.Context) ([]models.User, error) {
+func (u *UsersMongoStorage) GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error) {
 	const op = "storage.mongo.users.GetUsers"
 	log := u.log.With(
 		"op", op,
@@ -60,15 +158,16 @@ func (u *UsersMongoStorage) GetUsers(ctx context.Context) ([]models.User, error)
 	default:
 	}
 
-	collection := u.client.Database(u.databaseName).Collection(u.collectionName)
-	cursor, err := collection.Find(ctx, bson.M{})
+	filter, opts := buildListQuery(query)
+
+	cursor, err := u.collection().Find(ctx, filter, opts)
 	if err != nil {
 		log.Error("Error fetching usres", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer cursor.Close(ctx)
 
-	users := make([]models.User, 0, 10)
+	users := make([]models.User, 0, query.Limit)
 	if err := cursor.All(ctx, &users); err != nil {
 		log.Error("Error decode users", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -77,6 +176,47 @@ func (u *UsersMongoStorage) GetUsers(ctx context.Context) ([]models.User, error)
 	return users, nil
 }
 
+// notDeleted matches documents without deleted_at, a missing field and
+// null both count.
+var notDeleted = bson.M{"deleted_at": nil}
+
+func buildListQuery(query models.UsersQuery) (bson.M, *options.FindOptions) {
+	conditions := bson.A{notDeleted}
+
+	if query.Filter.Role != "" {
+		conditions = append(conditions, bson.M{"role": query.Filter.Role})
+	}
+	if query.Filter.LoginPrefix != "" {
+		conditions = append(conditions, bson.M{"login": primitive.Regex{
+			Pattern: "^" + regexp.QuoteMeta(query.Filter.LoginPrefix),
+			Options: "i",
+		}})
+	}
+
+	cmp, direction := "$gt", 1
+	if query.Sort.Desc {
+		cmp, direction = "$lt", -1
+	}
+
+	sort := bson.D{{Key: "id", Value: direction}}
+	if query.Sort.By == models.SortByLogin {
+		sort = bson.D{{Key: "login", Value: direction}, {Key: "id", Value: direction}}
+	}
+
+	if query.After != nil {
+		if query.Sort.By == models.SortByLogin {
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{"login": bson.M{cmp: query.After.Login}},
+				bson.M{"login": query.After.Login, "id": bson.M{cmp: query.After.Id}},
+			}})
+		} else {
+			conditions = append(conditions, bson.M{"id": bson.M{cmp: query.After.Id}})
+		}
+	}
+
+	return bson.M{"$and": conditions}, options.Find().SetSort(sort).SetLimit(int64(query.Limit))
+}
+
 // GetUserById implements usersservice.IUsersStorage.
 func (u *UsersMongoStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	const op = "storage.mongo.users.GetUserById"
@@ -90,12 +230,46 @@ func (u *UsersMongoStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mod
 	default:
 	}
 
-	collection := u.client.Database(u.databaseName).Collection(u.collectionName)
+	var user models.User
+
+	err := u.collection().FindOne(ctx, bson.M{"id": uid, "deleted_at": nil}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Warn("User with current id not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error finding user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// GetUserByLogin implements usersservice.IUsersStorage.
+func (u *UsersMongoStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const op = "storage.mongo.users.GetUserByLogin"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
 
 	var user models.User
 
-	err := collection.FindOne(ctx, bson.M{"id": uid}).Decode(&user)
+	err := u.collection().FindOne(ctx, bson.M{"login": login, "deleted_at": nil},
+		options.FindOne().SetCollation(loginCollation),
+	).Decode(&user)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Warn("User with current login not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
 		log.Error("Error finding user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -104,7 +278,7 @@ func (u *UsersMongoStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mod
 }
 
 // Insert implements usersservice.IUsersStorage.
-func (u *UsersMongoStorage) Insert(ctx context.Context, user models.User) (models.User, error) {
+func (u *UsersMongoStorage) Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error) {
 	const op = "storage.mongo.users.Insert"
 	log := u.log.With(
 		"op", op,
@@ -116,25 +290,31 @@ func (u *UsersMongoStorage) Insert(ctx context.Context, user models.User) (model
 	default:
 	}
 
-	collection := u.client.Database(u.databaseName).Collection(u.collectionName)
-
-	if err := collection.FindOne(ctx, bson.M{"id": user.Id}).Err(); err == nil {
-		log.Error("User already exists")
-		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
-	}
+	// duplicates are caught by the unique indexes, no lookup beforehand
+	err := u.inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := u.collection().InsertOne(ctx, user); err != nil {
+			return err
+		}
 
-	insertResult, err := collection.InsertOne(ctx, user)
+		return u.addHistory(ctx, audit, models.User{}, user)
+	})
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Error("User with current id or login already exists", sl.Err(storageerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+		}
+
 		log.Error("Error inserting user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("User OID", slog.Any("object_id", insertResult.InsertedID))
 	return user, nil
 }
 
 // Update implements usersservice.IUsersStorage.
-func (u *UsersMongoStorage) Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error) {
+// Only the given fields and updated_at are written, the stored user is returned.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersMongoStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error) {
 	const op = "storage.mongo.users.Update"
 	log := u.log.With(
 		"op", op,
@@ -146,26 +326,112 @@ func (u *UsersMongoStorage) Update(ctx context.Context, uid uuid.UUID, user mode
 	default:
 	}
 
-	collection := u.client.Database(u.databaseName).Collection(u.collectionName)
-
-	filter := bson.M{"id": uid}
-
-	if err := collection.FindOne(ctx, filter); err.Err() != nil {
-		log.Error("User don`t exists", sl.Err(fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)))
-		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	set := bson.M{"updated_at": user.UpdatedAt}
+	for _, field := range models.UpdatableFields {
+		if slices.Contains(fields, field) {
+			set[field] = user.FieldValue(field)
+		}
 	}
 
-	_, err := collection.UpdateOne(ctx, filter, bson.M{"$set": user})
+	var updatedUser models.User
+	err := u.inTransaction(ctx, func(ctx context.Context) error {
+		before, err := u.findActive(ctx, uid, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		err = u.collection().FindOneAndUpdate(ctx, versionFilter(uid, before.Version), bson.M{
+			"$set": set,
+			"$inc": bson.M{"version": 1},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updatedUser)
+		if err != nil {
+			return err
+		}
+
+		return u.addHistory(ctx, audit, before, updatedUser)
+	})
 	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) || errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User not updated", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			log.Error("User with current login already exists", sl.Err(storageerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+		}
+
 		log.Error("Error updating user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return user, nil
+	return updatedUser, nil
+}
+
+// SetLastLogin implements usersservice.IUsersStorage.
+func (u *UsersMongoStorage) SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error {
+	const op = "storage.mongo.users.SetLastLogin"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.collection().UpdateOne(ctx, bson.M{"id": uid, "deleted_at": nil}, bson.M{"$set": bson.M{
+		"last_login_at": at,
+	}})
+	if err != nil {
+		log.Error("Error updating last login", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.MatchedCount == 0 {
+		log.Error("Zero documents matched")
+		return fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetPassword implements usersservice.IUsersStorage.
+// Only the hash changes, the version and updated_at are left alone. The
+// write happens only while the stored hash is still current, otherwise it
+// returns ErrConflict.
+func (u *UsersMongoStorage) SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error {
+	const op = "storage.mongo.users.SetPassword"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.collection().UpdateOne(ctx, bson.M{"id": uid, "password": current, "deleted_at": nil}, bson.M{"$set": bson.M{
+		"password": hashed,
+	}})
+	if err != nil {
+		log.Error("Error updating password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.MatchedCount == 0 {
+		log.Warn("Password has changed since it was read")
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	return nil
 }
 
 // Delete implements usersservice.IUsersStorage.
-func (u *UsersMongoStorage) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+// The user is only marked as deleted, Restore brings it back until it is purged.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersMongoStorage) Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error) {
 	const op = "storage.mongo.users.Delete"
 	log := u.log.With(
 		"op", op,
@@ -177,16 +443,180 @@ func (u *UsersMongoStorage) Delete(ctx context.Context, uid uuid.UUID) (models.U
 	default:
 	}
 
-	collection := u.client.Database(u.databaseName).Collection(u.collectionName)
+	var user models.User
+	err := u.inTransaction(ctx, func(ctx context.Context) error {
+		before, err := u.findActive(ctx, uid, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		err = u.collection().FindOneAndUpdate(ctx, versionFilter(uid, before.Version), bson.M{
+			"$set": bson.M{
+				"deleted_at": at,
+				"updated_at": at,
+			},
+			"$inc": bson.M{"version": 1},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+		if err != nil {
+			return err
+		}
+
+		return u.addHistory(ctx, audit, before, user)
+	})
+	if err != nil {
+		if errors.Is(err, storageerror.ErrNotFound) || errors.Is(err, storageerror.ErrConflict) {
+			log.Warn("User not deleted", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("Error deleting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Restore implements usersservice.IUsersStorage.
+// Only soft-deleted users are matched, any other id is reported as not found.
+func (u *UsersMongoStorage) Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error) {
+	const op = "storage.mongo.users.Restore"
+	log := u.log.With(
+		"op", op,
+	)
 
-	filter := bson.M{"id": uid}
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
 
 	var user models.User
-	err := collection.FindOneAndDelete(ctx, filter).Decode(&user)
+	err := u.inTransaction(ctx, func(ctx context.Context) error {
+		var before models.User
+		err := u.collection().FindOneAndUpdate(ctx, bson.M{"id": uid, "deleted_at": bson.M{"$ne": nil}}, bson.M{
+			"$set":   bson.M{"updated_at": at},
+			"$unset": bson.M{"deleted_at": ""},
+			"$inc":   bson.M{"version": 1},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&before)
+		if err != nil {
+			return err
+		}
+
+		user = before
+		user.DeletedAt = time.Time{}
+		user.UpdatedAt = at
+		user.Version++
+
+		return u.addHistory(ctx, audit, before, user)
+	})
 	if err != nil {
-		log.Error("Error deleting user", sl.Err(err))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Warn("Deleted user not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error restoring user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Purge implements usersservice.IUsersStorage.
+// The document is removed for good, whether it was soft-deleted or not.
+func (u *UsersMongoStorage) Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error) {
+	const op = "storage.mongo.users.Purge"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	var user models.User
+	err := u.inTransaction(ctx, func(ctx context.Context) error {
+		if err := u.collection().FindOneAndDelete(ctx, bson.M{"id": uid}).Decode(&user); err != nil {
+			return err
+		}
+
+		return u.addHistory(ctx, audit, user, models.User{})
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Warn("User not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error purging user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, nil
 }
+
+// PurgeDeleted implements usersservice.IUsersStorage.
+// It removes users soft-deleted before the given time and returns their number.
+func (u *UsersMongoStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.mongo.users.PurgeDeleted"
+	log := u.log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.collection().DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": before}})
+	if err != nil {
+		log.Error("Error purging deleted users", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result.DeletedCount, nil
+}
+
+// versionFilter matches an active user with the given version.
+func versionFilter(uid uuid.UUID, version int64) bson.M {
+	return bson.M{"id": uid, "deleted_at": nil, "version": version}
+}
+
+// findActive reads the user a write is about to change inside its
+// transaction. A non-zero expectedVersion must match the stored one.
+func (u *UsersMongoStorage) findActive(ctx context.Context, uid uuid.UUID, expectedVersion int64) (models.User, error) {
+	var user models.User
+	err := u.collection().FindOne(ctx, bson.M{"id": uid, "deleted_at": nil}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.User{}, storageerror.ErrNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return models.User{}, storageerror.ErrConflict
+	}
+
+	return user, nil
+}
+
+// inTransaction runs fn in a transaction, so a user and its history entry
+// are written together or not at all. fn may run again on transient errors.
+func (u *UsersMongoStorage) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		return nil, fn(ctx)
+	})
+
+	return err
+}
@@ -0,0 +1,93 @@
+package userspsqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"usersservice/internal/domain/models"
+	"usersservice/pkg/lib/logger/sl"
+
+	"github.com/google/uuid"
+)
+
+// historyTableName is append-only, a trigger rejects updates and deletes.
+const historyTableName = "user_history"
+
+// commitWithHistory stores the entry audit builds for a write in the
+// write's transaction and commits it, the user and its history are kept
+// together or not at all.
+func (u *UsersPsqlStorage) commitWithHistory(ctx context.Context, tx *sql.Tx, audit models.Audit, before models.User, after models.User) error {
+	if audit != nil {
+		entry := audit(before, after)
+		changes, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO `+historyTableName+` (id, user_id, actor, operation, changed_at, changes)
+			VALUES ($1, $2, $3, $4, $5, $6);
+		`, entry.Id, entry.UserId, entry.Actor, entry.Operation, entry.ChangedAt, changes)
+		if err != nil {
+			return fmt.Errorf("inserting history entry: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUserHistory implements IUsersPsqlStorage.
+func (u *UsersPsqlStorage) GetUserHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryEntry, error) {
+	const op = "storage.psql.users.GetUserHistory"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	var after *uuid.UUID
+	if query.After != uuid.Nil {
+		after = &query.After
+	}
+
+	rows, err := u.DB.QueryContext(ctx, `
+		SELECT id, user_id, actor, operation, changed_at, changes FROM `+historyTableName+`
+		WHERE user_id=$1 AND ($2::UUID IS NULL OR id < $2)
+		ORDER BY id DESC LIMIT $3;
+	`, query.UserId, after, query.Limit)
+	if err != nil {
+		log.Error("Error fetching history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.HistoryEntry, 0, query.Limit)
+	for rows.Next() {
+		var (
+			entry   models.HistoryEntry
+			changes []byte
+		)
+		if err := rows.Scan(&entry.Id, &entry.UserId, &entry.Actor, &entry.Operation, &entry.ChangedAt, &changes); err != nil {
+			log.Error("Error scanning history entry", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			log.Error("Malformed history changes", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
@@ -6,17 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 	"usersservice/internal/domain/models"
 	storageerror "usersservice/internal/storage"
 	"usersservice/pkg/lib/logger/sl"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
-	"github.com/pressly/goose/v3"
 )
 
+// userColumns is the column order scanUser expects.
+const userColumns = "id, login, password, role, email, display_name, locale, timezone, created_at, updated_at, last_login_at, deleted_at, version"
+
 type UsersPsqlStorage struct {
 	Log       *slog.Logger
 	DB        *sql.DB
@@ -30,12 +33,6 @@ func New(log *slog.Logger, connStr string, tableName string) *UsersPsqlStorage {
 		panic(err)
 	}
 
-	wd, _ := os.Getwd()
-	migrationPath := filepath.Join(wd, "app", "migrations")
-	if err := applyMigrations(db, migrationPath); err != nil {
-		panic(err)
-	}
-
 	return &UsersPsqlStorage{
 		Log:       log,
 		DB:        db,
@@ -43,10 +40,6 @@ func New(log *slog.Logger, connStr string, tableName string) *UsersPsqlStorage {
 	}
 }
 
-func applyMigrations(db *sql.DB, migrationsPath string) error {
-	return goose.Up(db, migrationsPath)
-}
-
 func (u *UsersPsqlStorage) Close() {
 	if err := u.DB.Close(); err != nil {
 		panic(err)
@@ -54,7 +47,8 @@ func (u *UsersPsqlStorage) Close() {
 }
 
 // GetUsers implements IUsersPsqlStorage.
-func (u *UsersPsqlStorage) GetUsers(ctx context.Context) ([]models.User, error) {
+// Pages are read with keyset pagination on (sort column, id).
+func (u *UsersPsqlStorage) GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error) {
 	const op = "storage.psql.users.GetUsers"
 	log := u.Log.With(
 		"op", op,
@@ -66,20 +60,19 @@ func (u *UsersPsqlStorage) GetUsers(ctx context.Context) ([]models.User, error)
 	default:
 	}
 
-	rows, err := u.DB.QueryContext(ctx, `
-		SELECT * FROM `+u.TableName+`;
-	`)
+	statement, args := buildListQuery(u.TableName, query)
+
+	rows, err := u.DB.QueryContext(ctx, statement, args...)
 	if err != nil {
-		log.Error("Error retrieving all users", sl.Err(err))
+		log.Error("Error retrieving users", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	users := make([]models.User, 0, 5)
-	var user models.User
+	users := make([]models.User, 0, query.Limit)
 
 	for rows.Next() {
-		err := rows.Scan(&user.Id, &user.Login, &user.Password, &user.Role)
+		user, err := scanUser(rows)
 		if err != nil {
 			log.Warn("Error scanning row", sl.Err(err))
 			continue
@@ -88,9 +81,90 @@ func (u *UsersPsqlStorage) GetUsers(ctx context.Context) ([]models.User, error)
 		users = append(users, user)
 	}
 
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return users, nil
 }
 
+func buildListQuery(tableName string, query models.UsersQuery) (string, []any) {
+	var (
+		conditions = []string{"deleted_at IS NULL"}
+		args       []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.Filter.Role != "" {
+		conditions = append(conditions, "role = "+arg(query.Filter.Role))
+	}
+	if query.Filter.LoginPrefix != "" {
+		// logins are stored lowercased, so the pattern index serves the prefix
+		conditions = append(conditions, "login LIKE "+arg(escapeLike(query.Filter.LoginPrefix))+" || '%'")
+	}
+
+	cmp, direction := ">", "ASC"
+	if query.Sort.Desc {
+		cmp, direction = "<", "DESC"
+	}
+
+	orderBy := "id " + direction
+	if query.Sort.By == models.SortByLogin {
+		orderBy = "login " + direction + ", id " + direction
+	}
+
+	if query.After != nil {
+		if query.Sort.By == models.SortByLogin {
+			conditions = append(conditions, "(login, id) "+cmp+" ("+arg(query.After.Login)+", "+arg(query.After.Id)+")")
+		} else {
+			conditions = append(conditions, "id "+cmp+" "+arg(query.After.Id))
+		}
+	}
+
+	statement := "SELECT " + userColumns + " FROM " + tableName
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY " + orderBy + " LIMIT " + arg(query.Limit) + ";"
+
+	return statement, args
+}
+
+// buildUpdateQuery sets the given fields in the order of
+// models.UpdatableFields, anything else in fields is ignored.
+func buildUpdateQuery(tableName string, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (string, []any) {
+	var (
+		set  []string
+		args []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, field := range models.UpdatableFields {
+		if slices.Contains(fields, field) {
+			set = append(set, field+"="+arg(user.FieldValue(field)))
+		}
+	}
+	set = append(set, "updated_at="+arg(user.UpdatedAt), "version=version+1")
+
+	id, version := arg(uid), arg(expectedVersion)
+	statement := "UPDATE " + tableName + " SET " + strings.Join(set, ", ") +
+		" WHERE id=" + id + " AND deleted_at IS NULL AND (" + version + "::BIGINT = 0 OR version=" + version + ")" +
+		" RETURNING " + userColumns + ";"
+
+	return statement, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 // GetUserById implements IUsersPsqlStorage.
 func (u *UsersPsqlStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	const op = "storage.psql.users.GetUserById"
@@ -104,11 +178,10 @@ func (u *UsersPsqlStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 	default:
 	}
 
-	var user models.User
-	err := u.DB.QueryRowContext(ctx, `
-		SELECT * FROM `+u.TableName+`
-		WHERE id=$1;
-	`, uid).Scan(&user.Id, &user.Login, &user.Password, &user.Role)
+	user, err := scanUser(u.DB.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=$1 AND deleted_at IS NULL;
+	`, uid))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Warn("User with current id not found", sl.Err(storageerror.ErrNotFound))
@@ -122,8 +195,39 @@ func (u *UsersPsqlStorage) GetUserById(ctx context.Context, uid uuid.UUID) (mode
 	return user, nil
 }
 
+// GetUserByLogin implements IUsersPsqlStorage.
+// Logins are compared case-insensitively, matching the unique index on lower(login).
+func (u *UsersPsqlStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const op = "storage.psql.users.GetUserByLogin"
+	log := u.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	user, err := scanUser(u.DB.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE lower(login)=lower($1) AND deleted_at IS NULL;
+	`, login))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User with current login not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error scaning row", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
 // Insert implements IUsersPsqlStorage.
-func (u *UsersPsqlStorage) Insert(ctx context.Context, user models.User) (models.User, error) {
+func (u *UsersPsqlStorage) Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error) {
 	const op = "storage.psql.users.Insert"
 	log := u.Log.With(
 		"op", op,
@@ -135,13 +239,21 @@ func (u *UsersPsqlStorage) Insert(ctx context.Context, user models.User) (models
 	default:
 	}
 
-	_, err := u.DB.ExecContext(ctx, `
-		INSERT INTO `+u.TableName+` (id, login, password, role)
-		VALUES ($1, $2, $3, $4);
-	`, user.Id, user.Login, user.Password, user.Role)
+	tx, err := u.DB.BeginTx(ctx, nil)
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
-			log.Error("User with current id already exists", sl.Err(storageerror.ErrAlreadyExists))
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO `+u.TableName+` (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+	`, user.Id, user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone,
+		user.CreatedAt, user.UpdatedAt, nullTime(user.LastLoginAt), nullTime(user.DeletedAt), user.Version)
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Error("User with current id or login already exists", sl.Err(storageerror.ErrAlreadyExists))
 			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
 		}
 
@@ -149,11 +261,19 @@ func (u *UsersPsqlStorage) Insert(ctx context.Context, user models.User) (models
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := u.commitWithHistory(ctx, tx, audit, models.User{}, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return user, nil
 }
 
 // Update implements IUsersPsqlStorage.
-func (u *UsersPsqlStorage) Update(ctx context.Context, uid uuid.UUID, user models.User) (models.User, error) {
+// Only the given fields and updated_at are written, the stored row is returned.
+// A non-zero expectedVersion must match the stored one, otherwise ErrConflict
+// is returned.
+func (u *UsersPsqlStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error) {
 	const op = "storage.psql.users.Update"
 	log := u.Log.With(
 		slog.String("op", op),
@@ -165,32 +285,120 @@ func (u *UsersPsqlStorage) Update(ctx context.Context, uid uuid.UUID, user model
 	default:
 	}
 
-	result, err := u.DB.ExecContext(ctx, `
-		UPDATE `+u.TableName+`
-		SET login=$1, password=$2, role=$3
-		WHERE id=$4;
-	`, user.Login, user.Password, user.Role, user.Id)
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := u.lockActive(ctx, tx, uid, expectedVersion)
+	if err != nil {
+		log.Warn("User not updated", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statement, args := buildUpdateQuery(u.TableName, uid, user, fields, expectedVersion)
+	updatedUser, err := scanUser(tx.QueryRowContext(ctx, statement, args...))
 	if err != nil {
+		if isUniqueViolation(err) {
+			log.Error("User with current login already exists", sl.Err(storageerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+		}
+
 		log.Error("Error updating user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := u.commitWithHistory(ctx, tx, audit, before, updatedUser); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return updatedUser, nil
+}
+
+// SetLastLogin implements IUsersPsqlStorage.
+func (u *UsersPsqlStorage) SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error {
+	const op = "storage.psql.users.SetLastLogin"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET last_login_at=$1
+		WHERE id=$2 AND deleted_at IS NULL;
+	`, at, uid)
+	if err != nil {
+		log.Error("Error updating last login", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Error("Error get rows affected", sl.Err(err))
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	if rowsAffected == 0 {
 		log.Error("Zero rows affected")
-		return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		return fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
 	}
 
-	return user, nil
+	return nil
+}
+
+// SetPassword implements IUsersPsqlStorage.
+// Only the hash changes, the version and updated_at are left alone. The
+// write happens only while the stored hash is still current, otherwise it
+// returns ErrConflict.
+func (u *UsersPsqlStorage) SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error {
+	const op = "storage.psql.users.SetPassword"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET password=$1
+		WHERE id=$2 AND password=$3 AND deleted_at IS NULL;
+	`, hashed, uid, current)
+	if err != nil {
+		log.Error("Error updating password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error get rows affected", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		log.Warn("Password has changed since it was read")
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	return nil
 }
 
 // Delete implements IUsersPsqlStorage.
-func (u *UsersPsqlStorage) Delete(ctx context.Context, uid uuid.UUID) (models.User, error) {
+// The user is only marked as deleted, Restore brings it back until it is purged.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersPsqlStorage) Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error) {
 	const op = "storage.psql.users.Delete"
 	log := u.Log.With(
 		slog.String("op", op),
@@ -202,25 +410,221 @@ func (u *UsersPsqlStorage) Delete(ctx context.Context, uid uuid.UUID) (models.Us
 	default:
 	}
 
-	user, err := u.GetUserById(ctx, uid)
+	tx, err := u.DB.BeginTx(ctx, nil)
 	if err != nil {
-		if errors.Is(err, storageerror.ErrNotFound) {
-			log.Warn("User not found", sl.Err(storageerror.ErrNotFound))
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := u.lockActive(ctx, tx, uid, expectedVersion)
+	if err != nil {
+		log.Warn("User not deleted", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET deleted_at=$1, updated_at=$1, version=version+1
+		WHERE id=$2
+		RETURNING `+userColumns+`;
+	`, at, uid))
+	if err != nil {
+		log.Error("Error deleting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, before, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Restore implements IUsersPsqlStorage.
+// Only soft-deleted users are matched, any other id is reported as not found.
+func (u *UsersPsqlStorage) Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error) {
+	const op = "storage.psql.users.Restore"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanUser(tx.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=$1 AND deleted_at IS NOT NULL
+		FOR UPDATE;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Deleted user not found", sl.Err(storageerror.ErrNotFound))
 			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
 		}
 
-		log.Error("Error getting user before deliting", sl.Err(err))
+		log.Error("Error reading user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = u.DB.ExecContext(ctx, `
-		DELETE FROM `+u.TableName+` 
-		WHERE id = $1;
-	`, uid)
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET deleted_at=NULL, updated_at=$1, version=version+1
+		WHERE id=$2
+		RETURNING `+userColumns+`;
+	`, at, uid))
 	if err != nil {
-		log.Error("Error deleting user", sl.Err(err))
+		log.Error("Error restoring user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, before, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Purge implements IUsersPsqlStorage.
+// The row is removed for good, whether it was soft-deleted or not.
+func (u *UsersPsqlStorage) Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error) {
+	const op = "storage.psql.users.Purge"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		DELETE FROM `+u.TableName+`
+		WHERE id=$1
+		RETURNING `+userColumns+`;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error purging user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, user, models.User{}); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, nil
 }
+
+// PurgeDeleted implements IUsersPsqlStorage.
+// It removes users soft-deleted before the given time and returns their number.
+func (u *UsersPsqlStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.psql.users.PurgeDeleted"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		DELETE FROM `+u.TableName+`
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1;
+	`, before)
+	if err != nil {
+		log.Error("Error purging deleted users", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error get rows affected", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// lockActive reads the user a write is about to change and locks its row
+// until tx ends, so the history entry starts from the state the write
+// replaces. A non-zero expectedVersion must match the stored one.
+func (u *UsersPsqlStorage) lockActive(ctx context.Context, tx *sql.Tx, uid uuid.UUID, expectedVersion int64) (models.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=$1 AND deleted_at IS NULL
+		FOR UPDATE;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storageerror.ErrNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return models.User{}, storageerror.ErrConflict
+	}
+
+	return user, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var (
+		user        models.User
+		lastLoginAt sql.NullTime
+		deletedAt   sql.NullTime
+	)
+
+	err := row.Scan(&user.Id, &user.Login, &user.Password, &user.Role, &user.Email, &user.DisplayName,
+		&user.Locale, &user.Timezone, &user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &deletedAt, &user.Version)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.LastLoginAt = lastLoginAt.Time
+	user.DeletedAt = deletedAt.Time
+
+	return user, nil
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
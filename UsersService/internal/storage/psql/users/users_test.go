@@ -6,6 +6,7 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"usersservice/internal/domain/models"
 	storageerror "usersservice/internal/storage"
@@ -36,17 +37,50 @@ func newTestStorage(t *testing.T) (*userspsqlstorage.UsersPsqlStorage, sqlmock.S
 	return storage, mock, cleanup
 }
 
+const (
+	selectUser    = "SELECT id, login, password, role, email, display_name, locale, timezone, created_at, updated_at, last_login_at, deleted_at, version FROM users"
+	insertUser    = "INSERT INTO users (id, login, password, role, email, display_name, locale, timezone, created_at, updated_at, last_login_at, deleted_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);"
+	updateUser    = "UPDATE users SET login=$1, password=$2, role=$3, email=$4, display_name=$5, locale=$6, timezone=$7, updated_at=$8, version=version+1 WHERE id=$9 AND deleted_at IS NULL AND ($10::BIGINT = 0 OR version=$10) RETURNING " + returningUser
+	deleteUser    = "UPDATE users SET deleted_at=$1, updated_at=$1, version=version+1 WHERE id=$2 RETURNING " + returningUser
+	lockUser      = selectUser + " WHERE id=$1 AND deleted_at IS NULL FOR UPDATE;"
+	insertHistory = "INSERT INTO user_history (id, user_id, actor, operation, changed_at, changes) VALUES ($1, $2, $3, $4, $5, $6);"
+	returningUser = "id, login, password, role, email, display_name, locale, timezone, created_at, updated_at, last_login_at, deleted_at, version;"
+)
+
+var userColumns = []string{"id", "login", "password", "role", "email", "display_name", "locale", "timezone", "created_at", "updated_at", "last_login_at", "deleted_at", "version"}
+
+func userRows(users ...models.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows(userColumns)
+	for _, user := range users {
+		rows.AddRow(user.Id, user.Login, user.Password, user.Role, user.Email, user.DisplayName,
+			user.Locale, user.Timezone, user.CreatedAt, user.UpdatedAt, nullable(user.LastLoginAt), nullable(user.DeletedAt), user.Version)
+	}
+
+	return rows
+}
+
+func nullable(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
 func TestGetUsers(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
-	rows := sqlmock.NewRows([]string{"id", "login", "password", "role"}).
-		AddRow(uuid.New(), "user1", "pass1", "admin").
-		AddRow(uuid.New(), "user2", "pass2", "user")
+	rows := userRows(
+		models.User{Id: uuid.New(), Login: "user1", Password: "pass1", Role: "admin"},
+		models.User{Id: uuid.New(), Login: "user2", Password: "pass2", Role: "user"},
+	)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users;")).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE deleted_at IS NULL ORDER BY id ASC LIMIT $1;")).
+		WithArgs(21).
+		WillReturnRows(rows)
 
-	users, err := storage.GetUsers(context.Background())
+	users, err := storage.GetUsers(context.Background(), models.UsersQuery{Sort: models.UsersSort{By: models.SortById}, Limit: 21})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -59,15 +93,59 @@ func TestGetUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsers_FilteredByLoginAfterCursor(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	after := models.UsersCursor{Id: uuid.New(), Login: "bob"}
+	rows := userRows(models.User{Id: uuid.New(), Login: "al_ice", Password: "pass1", Role: "admin"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		selectUser+" WHERE deleted_at IS NULL AND role = $1 AND login LIKE $2 || '%' AND (login, id) < ($3, $4) ORDER BY login DESC, id DESC LIMIT $5;",
+	)).
+		WithArgs("admin", `al\_`, "bob", after.Id, 3).
+		WillReturnRows(rows)
+
+	users, err := storage.GetUsers(context.Background(), models.UsersQuery{
+		Filter: models.UsersFilter{Role: "admin", LoginPrefix: "al_"},
+		Sort:   models.UsersSort{By: models.SortByLogin, Desc: true},
+		After:  &after,
+		Limit:  3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("expected 1 user, got %d", len(users))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestGetUserById_Success(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
 	id := uuid.New()
-	row := sqlmock.NewRows([]string{"id", "login", "password", "role"}).
-		AddRow(id, "user1", "pass1", "admin")
-
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id=$1;")).
+	createdAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	lastLoginAt := createdAt.Add(time.Hour)
+	row := userRows(models.User{
+		Id:          id,
+		Login:       "user1",
+		Password:    "pass1",
+		Role:        "admin",
+		Email:       "user1@example.com",
+		DisplayName: "User One",
+		Locale:      "en-US",
+		Timezone:    "Europe/Berlin",
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		LastLoginAt: lastLoginAt,
+	})
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE id=$1 AND deleted_at IS NULL;")).
 		WithArgs(id).
 		WillReturnRows(row)
 
@@ -78,6 +156,12 @@ func TestGetUserById_Success(t *testing.T) {
 	if user.Id != id {
 		t.Errorf("expected id %v, got %v", id, user.Id)
 	}
+	if user.Email != "user1@example.com" || user.Timezone != "Europe/Berlin" {
+		t.Errorf("profile fields not scanned: %+v", user)
+	}
+	if !user.CreatedAt.Equal(createdAt) || !user.LastLoginAt.Equal(lastLoginAt) {
+		t.Errorf("timestamps not scanned: %+v", user)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -90,7 +174,7 @@ func TestGetUserById_NotFound(t *testing.T) {
 
 	id := uuid.New()
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id=$1;")).
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE id=$1 AND deleted_at IS NULL;")).
 		WithArgs(id).
 		WillReturnError(sql.ErrNoRows)
 
@@ -104,22 +188,71 @@ func TestGetUserById_NotFound(t *testing.T) {
 	}
 }
 
+func TestGetUserByLogin_CaseInsensitive(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+	row := userRows(models.User{Id: id, Login: "User1", Password: "pass1", Role: "admin"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE lower(login)=lower($1) AND deleted_at IS NULL;")).
+		WithArgs("user1").
+		WillReturnRows(row)
+
+	user, err := storage.GetUserByLogin(context.Background(), "user1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Id != id {
+		t.Errorf("expected id %v, got %v", id, user.Id)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetUserByLogin_NotFound(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE lower(login)=lower($1) AND deleted_at IS NULL;")).
+		WithArgs("user1").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := storage.GetUserByLogin(context.Background(), "user1")
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestInsert_Success(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 	user := models.User{
-		Id:       uuid.New(),
-		Login:    "user1",
-		Password: "pass1",
-		Role:     "admin",
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		Email:     "user1@example.com",
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, login, password, role) VALUES ($1, $2, $3, $4);")).
-		WithArgs(user.Id, user.Login, user.Password, user.Role).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(insertUser)).
+		WithArgs(user.Id, user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone,
+			user.CreatedAt, user.UpdatedAt, sql.NullTime{}, sql.NullTime{}, user.Version).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	insertedUser, err := storage.Insert(context.Background(), user)
+	insertedUser, err := storage.Insert(context.Background(), user, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -136,20 +269,27 @@ func TestInsert_AlreadyExists(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 	user := models.User{
-		Id:       uuid.New(),
-		Login:    "user1",
-		Password: "pass1",
-		Role:     "admin",
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		Email:     "user1@example.com",
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
 	pqErr := &pq.Error{Code: "23505"}
 
-	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, login, password, role) VALUES ($1, $2, $3, $4);")).
-		WithArgs(user.Id, user.Login, user.Password, user.Role).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(insertUser)).
+		WithArgs(user.Id, user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone,
+			user.CreatedAt, user.UpdatedAt, sql.NullTime{}, sql.NullTime{}, user.Version).
 		WillReturnError(pqErr)
+	mock.ExpectRollback()
 
-	_, err := storage.Insert(context.Background(), user)
+	_, err := storage.Insert(context.Background(), user, nil)
 	if !errors.Is(err, storageerror.ErrAlreadyExists) {
 		t.Errorf("expected ErrAlreadyExists, got %v", err)
 	}
@@ -163,18 +303,27 @@ func TestUpdate_Success(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 	user := models.User{
-		Id:       uuid.New(),
-		Login:    "user1",
-		Password: "pass1",
-		Role:     "admin",
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		Email:     "user1@example.com",
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET login=$1, password=$2, role=$3 WHERE id=$4;")).
-		WithArgs(user.Login, user.Password, user.Role, user.Id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(user.Id).
+		WillReturnRows(userRows(user))
+	mock.ExpectQuery(regexp.QuoteMeta(updateUser)).
+		WithArgs(user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone, user.UpdatedAt, user.Id, int64(0)).
+		WillReturnRows(userRows(user))
+	mock.ExpectCommit()
 
-	updatedUser, err := storage.Update(context.Background(), user.Id, user)
+	updatedUser, err := storage.Update(context.Background(), user.Id, user, models.UpdatableFields, 0, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -187,22 +336,144 @@ func TestUpdate_Success(t *testing.T) {
 	}
 }
 
+func TestUpdate_Fields(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{Id: uuid.New(), Login: "user1", Role: "admin", UpdatedAt: now}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(user.Id).
+		WillReturnRows(userRows(models.User{Id: user.Id, Login: "user1", Role: "user", Version: 3}))
+	// fields go in the order of models.UpdatableFields
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE users SET role=$1, timezone=$2, updated_at=$3, version=version+1 WHERE id=$4 AND deleted_at IS NULL AND ($5::BIGINT = 0 OR version=$5) RETURNING "+returningUser)).
+		WithArgs(user.Role, user.Timezone, user.UpdatedAt, user.Id, int64(3)).
+		WillReturnRows(userRows(user))
+	mock.ExpectCommit()
+
+	_, err := storage.Update(context.Background(), user.Id, user, []string{models.FieldTimezone, models.FieldRole}, 3, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdate_LoginTaken(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		Email:     "user1@example.com",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(user.Id).
+		WillReturnRows(userRows(user))
+	mock.ExpectQuery(regexp.QuoteMeta(updateUser)).
+		WithArgs(user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone, user.UpdatedAt, user.Id, int64(0)).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	_, err := storage.Update(context.Background(), user.Id, user, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestUpdate_NotFound(t *testing.T) {
 	storage, mock, cleanup := newTestStorage(t)
 	defer cleanup()
 
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 	user := models.User{
-		Id:       uuid.New(),
-		Login:    "user1",
-		Password: "pass1",
-		Role:     "admin",
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		Email:     "user1@example.com",
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET login=$1, password=$2, role=$3 WHERE id=$4;")).
-		WithArgs(user.Login, user.Password, user.Role, user.Id).
-		WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(user.Id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	_, err := storage.Update(context.Background(), user.Id, user)
+	_, err := storage.Update(context.Background(), user.Id, user, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdate_VersionConflict(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{
+		Id:        uuid.New(),
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		UpdatedAt: now,
+	}
+
+	stored := user
+	stored.Version = 4
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(user.Id).
+		WillReturnRows(userRows(stored))
+	mock.ExpectRollback()
+
+	_, err := storage.Update(context.Background(), user.Id, user, models.UpdatableFields, 3, nil)
+	if !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDelete_VersionedNotFound(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err := storage.Delete(context.Background(), id, at, 3, nil)
 	if !errors.Is(err, storageerror.ErrNotFound) {
 		t.Errorf("expected ErrNotFound, got %v", err)
 	}
@@ -217,30 +488,38 @@ func TestDelete_Success(t *testing.T) {
 	defer cleanup()
 
 	id := uuid.New()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 	user := models.User{
-		Id:       id,
-		Login:    "user1",
-		Password: "pass1",
-		Role:     "admin",
+		Id:        id,
+		Login:     "user1",
+		Password:  "pass1",
+		Role:      "admin",
+		UpdatedAt: at,
+		DeletedAt: at,
 	}
 
-	row := sqlmock.NewRows([]string{"id", "login", "password", "role"}).
-		AddRow(user.Id, user.Login, user.Password, user.Role)
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id=$1;")).
-		WithArgs(id).
-		WillReturnRows(row)
+	before := user
+	before.DeletedAt = time.Time{}
 
-	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
 		WithArgs(id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(userRows(before))
+	mock.ExpectQuery(regexp.QuoteMeta(deleteUser)).
+		WithArgs(at, id).
+		WillReturnRows(userRows(user))
+	mock.ExpectCommit()
 
-	deletedUser, err := storage.Delete(context.Background(), id)
+	deletedUser, err := storage.Delete(context.Background(), id, at, 0, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if deletedUser.Id != id {
 		t.Errorf("expected id %v, got %v", id, deletedUser.Id)
 	}
+	if !deletedUser.DeletedAt.Equal(at) {
+		t.Errorf("expected deleted_at %v, got %v", at, deletedUser.DeletedAt)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -252,12 +531,38 @@ func TestDelete_NotFound(t *testing.T) {
 	defer cleanup()
 
 	id := uuid.New()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err := storage.Delete(context.Background(), id, at, 0, nil)
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRestore_NotDeleted(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id=$1;")).
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(selectUser + " WHERE id=$1 AND deleted_at IS NOT NULL FOR UPDATE;")).
 		WithArgs(id).
-		WillReturnError(storageerror.ErrNotFound)
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	_, err := storage.Delete(context.Background(), id)
+	_, err := storage.Restore(context.Background(), id, at, nil)
 	if !errors.Is(err, storageerror.ErrNotFound) {
 		t.Errorf("expected ErrNotFound, got %v", err)
 	}
@@ -266,3 +571,236 @@ func TestDelete_NotFound(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestPurge_Success(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+	user := models.User{Id: id, Login: "user1", Password: "pass1", Role: "user"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM users WHERE id=$1 RETURNING " + returningUser)).
+		WithArgs(id).
+		WillReturnRows(userRows(user))
+	mock.ExpectCommit()
+
+	purgedUser, err := storage.Purge(context.Background(), id, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purgedUser.Id != id {
+		t.Errorf("expected id %v, got %v", id, purgedUser.Id)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	before := time.Date(2026, 9, 16, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1;")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	purged, err := storage.PurgeDeleted(context.Background(), before)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("expected 2 purged users, got %d", purged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdate_ReturnsStoredRow(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	// the caller knows nothing about created_at, the row keeps its value
+	user := models.User{Login: "user1", Password: "pass1", Role: "admin", UpdatedAt: updatedAt}
+	stored := user
+	stored.Id = id
+	stored.CreatedAt = createdAt
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(id).
+		WillReturnRows(userRows(stored))
+	mock.ExpectQuery(regexp.QuoteMeta(updateUser)).
+		WithArgs(user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone, updatedAt, id, int64(0)).
+		WillReturnRows(userRows(stored))
+	mock.ExpectCommit()
+
+	updatedUser, err := storage.Update(context.Background(), id, user, models.UpdatableFields, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updatedUser.Id != id {
+		t.Errorf("expected id %v, got %v", id, updatedUser.Id)
+	}
+	if !updatedUser.CreatedAt.Equal(createdAt) || !updatedUser.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("unexpected timestamps: created %v, updated %v", updatedUser.CreatedAt, updatedUser.UpdatedAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetLastLogin(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET last_login_at=$1 WHERE id=$2 AND deleted_at IS NULL;")).
+		WithArgs(at, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET last_login_at=$1 WHERE id=$2 AND deleted_at IS NULL;")).
+		WithArgs(at, id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := storage.SetLastLogin(context.Background(), id, at); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.SetLastLogin(context.Background(), id, at); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	id := uuid.New()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET password=$1 WHERE id=$2 AND password=$3 AND deleted_at IS NULL;")).
+		WithArgs("new", id, "old").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET password=$1 WHERE id=$2 AND password=$3 AND deleted_at IS NULL;")).
+		WithArgs("newer", id, "old").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := storage.SetPassword(context.Background(), id, "old", "new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.SetPassword(context.Background(), id, "old", "newer"); !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdate_RecordsHistory(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	before := models.User{Id: uuid.New(), Login: "user1", Role: "user", Version: 1}
+	after := before
+	after.Role, after.Version = "admin", 2
+	entry := models.HistoryEntry{
+		Id:        uuid.Must(uuid.NewV7()),
+		UserId:    before.Id,
+		Actor:     "admin-id",
+		Operation: models.OperationUpdate,
+		ChangedAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+	}
+	audit := func(b models.User, a models.User) models.HistoryEntry {
+		entry.Changes = []models.FieldChange{{Field: models.FieldRole, Before: b.Role, After: a.Role}}
+		return entry
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockUser)).
+		WithArgs(before.Id).
+		WillReturnRows(userRows(before))
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE users SET role=$1, updated_at=$2, version=version+1 WHERE id=$3 AND deleted_at IS NULL AND ($4::BIGINT = 0 OR version=$4) RETURNING "+returningUser)).
+		WithArgs("admin", time.Time{}, before.Id, int64(0)).
+		WillReturnRows(userRows(after))
+	mock.ExpectExec(regexp.QuoteMeta(insertHistory)).
+		WithArgs(entry.Id, entry.UserId, entry.Actor, entry.Operation, entry.ChangedAt, []byte(`[{"field":"role","before":"user","after":"admin"}]`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := storage.Update(context.Background(), before.Id, models.User{Role: "admin"}, []string{models.FieldRole}, 0, audit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInsert_HistoryFailureRollsBack(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	user := models.User{Id: uuid.New(), Login: "user1", Password: "pass1", Role: "user", Version: 1}
+	audit := func(before models.User, after models.User) models.HistoryEntry {
+		return models.HistoryEntry{Id: uuid.Must(uuid.NewV7()), UserId: after.Id, Operation: models.OperationInsert}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(insertUser)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertHistory)).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	if _, err := storage.Insert(context.Background(), user, audit); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetUserHistory_AfterCursor(t *testing.T) {
+	storage, mock, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	userId := uuid.New()
+	after := uuid.Must(uuid.NewV7())
+	id := uuid.Must(uuid.NewV7())
+	changedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, actor, operation, changed_at, changes FROM user_history WHERE user_id=$1 AND ($2::UUID IS NULL OR id < $2) ORDER BY id DESC LIMIT $3;")).
+		WithArgs(userId, after, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "actor", "operation", "changed_at", "changes"}).
+			AddRow(id, userId, "", models.OperationDelete, changedAt, []byte(`[{"field":"deleted_at","before":"","after":"2026-10-16T12:00:00Z"}]`)))
+
+	entries, err := storage.GetUserHistory(context.Background(), models.HistoryQuery{UserId: userId, After: after, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Id != id || entries[0].Operation != models.OperationDelete {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if len(entries[0].Changes) != 1 || entries[0].Changes[0].After != "2026-10-16T12:00:00Z" {
+		t.Errorf("unexpected changes: %+v", entries[0].Changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,641 @@
+package userssqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"usersservice/internal/domain/models"
+	storageerror "usersservice/internal/storage"
+	"usersservice/pkg/lib/logger/sl"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const tableName = "users"
+
+// userColumns is the column order scanUser expects.
+const userColumns = "id, login, password, role, email, display_name, locale, timezone, created_at, updated_at, last_login_at, deleted_at, version"
+
+type UsersSqliteStorage struct {
+	Log       *slog.Logger
+	DB        *sql.DB
+	TableName string
+}
+
+// New opens the database file at path, creating it if needed. WAL lets
+// readers proceed while a write is in progress, busy_timeout makes
+// concurrent writers wait instead of failing. Transactions take the write
+// lock when they begin, so one that reads before writing cannot fail to
+// upgrade its lock.
+func New(log *slog.Logger, path string) *UsersSqliteStorage {
+	db, err := sql.Open("sqlite", DSN(path))
+	if err != nil {
+		log.Error("Error opening database", sl.Err(err))
+		panic(err)
+	}
+
+	return &UsersSqliteStorage{
+		Log:       log,
+		DB:        db,
+		TableName: tableName,
+	}
+}
+
+func DSN(path string) string {
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+}
+
+func (u *UsersSqliteStorage) Close() {
+	if err := u.DB.Close(); err != nil {
+		panic(err)
+	}
+}
+
+// GetUsers implements app.IUsersStorage.
+// Pages are read with keyset pagination on (sort column, id).
+func (u *UsersSqliteStorage) GetUsers(ctx context.Context, query models.UsersQuery) ([]models.User, error) {
+	const op = "storage.sqlite.users.GetUsers"
+	log := u.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	statement, args := buildListQuery(u.TableName, query)
+
+	rows, err := u.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		log.Error("Error retrieving users", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0, query.Limit)
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Warn("Error scanning row", sl.Err(err))
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func buildListQuery(tableName string, query models.UsersQuery) (string, []any) {
+	var (
+		conditions = []string{"deleted_at IS NULL"}
+		args       []any
+	)
+
+	if query.Filter.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, query.Filter.Role)
+	}
+	if query.Filter.LoginPrefix != "" {
+		conditions = append(conditions, `login LIKE ? || '%' ESCAPE '\'`)
+		args = append(args, escapeLike(query.Filter.LoginPrefix))
+	}
+
+	cmp, direction := ">", "ASC"
+	if query.Sort.Desc {
+		cmp, direction = "<", "DESC"
+	}
+
+	orderBy := "id " + direction
+	if query.Sort.By == models.SortByLogin {
+		orderBy = "login " + direction + ", id " + direction
+	}
+
+	if query.After != nil {
+		if query.Sort.By == models.SortByLogin {
+			conditions = append(conditions, "(login, id) "+cmp+" (?, ?)")
+			args = append(args, query.After.Login, query.After.Id)
+		} else {
+			conditions = append(conditions, "id "+cmp+" ?")
+			args = append(args, query.After.Id)
+		}
+	}
+
+	statement := "SELECT " + userColumns + " FROM " + tableName
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY " + orderBy + " LIMIT ?;"
+	args = append(args, query.Limit)
+
+	return statement, args
+}
+
+// buildUpdateQuery sets the given fields in the order of
+// models.UpdatableFields, anything else in fields is ignored.
+func buildUpdateQuery(tableName string, uid uuid.UUID, user models.User, fields []string, expectedVersion int64) (string, []any) {
+	var (
+		set  []string
+		args []any
+	)
+
+	for _, field := range models.UpdatableFields {
+		if slices.Contains(fields, field) {
+			set = append(set, field+"=?")
+			args = append(args, user.FieldValue(field))
+		}
+	}
+	set = append(set, "updated_at=?", "version=version+1")
+	args = append(args, user.UpdatedAt, uid, expectedVersion, expectedVersion)
+
+	statement := "UPDATE " + tableName + " SET " + strings.Join(set, ", ") +
+		" WHERE id=? AND deleted_at IS NULL AND (? = 0 OR version=?)" +
+		" RETURNING " + userColumns + ";"
+
+	return statement, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetUserById implements app.IUsersStorage.
+func (u *UsersSqliteStorage) GetUserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
+	const op = "storage.sqlite.users.GetUserById"
+	log := u.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	user, err := scanUser(u.DB.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=? AND deleted_at IS NULL;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User with current id not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error scaning row", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// GetUserByLogin implements app.IUsersStorage.
+// Logins are compared case-insensitively, matching the unique index on lower(login).
+func (u *UsersSqliteStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const op = "storage.sqlite.users.GetUserByLogin"
+	log := u.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	user, err := scanUser(u.DB.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE lower(login)=lower(?) AND deleted_at IS NULL;
+	`, login))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User with current login not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error scaning row", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Insert implements app.IUsersStorage.
+func (u *UsersSqliteStorage) Insert(ctx context.Context, user models.User, audit models.Audit) (models.User, error) {
+	const op = "storage.sqlite.users.Insert"
+	log := u.Log.With(
+		"op", op,
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO `+u.TableName+` (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, user.Id, user.Login, user.Password, user.Role, user.Email, user.DisplayName, user.Locale, user.Timezone,
+		user.CreatedAt, user.UpdatedAt, nullTime(user.LastLoginAt), nullTime(user.DeletedAt), user.Version)
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Error("User with current id or login already exists", sl.Err(storageerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+		}
+
+		log.Error("Error inserting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, models.User{}, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Update implements app.IUsersStorage.
+// Only the given fields and updated_at are written, the stored row is returned.
+// A non-zero expectedVersion must match the stored one, otherwise ErrConflict
+// is returned.
+func (u *UsersSqliteStorage) Update(ctx context.Context, uid uuid.UUID, user models.User, fields []string, expectedVersion int64, audit models.Audit) (models.User, error) {
+	const op = "storage.sqlite.users.Update"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := u.readActive(ctx, tx, uid, expectedVersion)
+	if err != nil {
+		log.Warn("User not updated", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statement, args := buildUpdateQuery(u.TableName, uid, user, fields, expectedVersion)
+	updatedUser, err := scanUser(tx.QueryRowContext(ctx, statement, args...))
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Error("User with current login already exists", sl.Err(storageerror.ErrAlreadyExists))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrAlreadyExists)
+		}
+
+		log.Error("Error updating user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, before, updatedUser); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return updatedUser, nil
+}
+
+// SetLastLogin implements app.IUsersStorage.
+func (u *UsersSqliteStorage) SetLastLogin(ctx context.Context, uid uuid.UUID, at time.Time) error {
+	const op = "storage.sqlite.users.SetLastLogin"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET last_login_at=?
+		WHERE id=? AND deleted_at IS NULL;
+	`, at, uid)
+	if err != nil {
+		log.Error("Error updating last login", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error get rows affected", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		log.Error("Zero rows affected")
+		return fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetPassword implements app.IUsersStorage.
+// Only the hash changes, the version and updated_at are left alone. The
+// write happens only while the stored hash is still current, otherwise it
+// returns ErrConflict.
+func (u *UsersSqliteStorage) SetPassword(ctx context.Context, uid uuid.UUID, current string, hashed string) error {
+	const op = "storage.sqlite.users.SetPassword"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET password=?
+		WHERE id=? AND password=? AND deleted_at IS NULL;
+	`, hashed, uid, current)
+	if err != nil {
+		log.Error("Error updating password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error get rows affected", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		log.Warn("Password has changed since it was read")
+		return fmt.Errorf("%s: %w", op, storageerror.ErrConflict)
+	}
+
+	return nil
+}
+
+// Delete implements app.IUsersStorage.
+// The user is only marked as deleted, Restore brings it back until it is purged.
+// A non-zero expectedVersion must match the stored one.
+func (u *UsersSqliteStorage) Delete(ctx context.Context, uid uuid.UUID, at time.Time, expectedVersion int64, audit models.Audit) (models.User, error) {
+	const op = "storage.sqlite.users.Delete"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := u.readActive(ctx, tx, uid, expectedVersion)
+	if err != nil {
+		log.Warn("User not deleted", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET deleted_at=?, updated_at=?, version=version+1
+		WHERE id=?
+		RETURNING `+userColumns+`;
+	`, at, at, uid))
+	if err != nil {
+		log.Error("Error deleting user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, before, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Restore implements app.IUsersStorage.
+// Only soft-deleted users are matched, any other id is reported as not found.
+func (u *UsersSqliteStorage) Restore(ctx context.Context, uid uuid.UUID, at time.Time, audit models.Audit) (models.User, error) {
+	const op = "storage.sqlite.users.Restore"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanUser(tx.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=? AND deleted_at IS NOT NULL;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Deleted user not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error reading user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		UPDATE `+u.TableName+`
+		SET deleted_at=NULL, updated_at=?, version=version+1
+		WHERE id=?
+		RETURNING `+userColumns+`;
+	`, at, uid))
+	if err != nil {
+		log.Error("Error restoring user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, before, user); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Purge implements app.IUsersStorage.
+// The row is removed for good, whether it was soft-deleted or not.
+func (u *UsersSqliteStorage) Purge(ctx context.Context, uid uuid.UUID, audit models.Audit) (models.User, error) {
+	const op = "storage.sqlite.users.Purge"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return models.User{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error starting transaction", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		DELETE FROM `+u.TableName+`
+		WHERE id=?
+		RETURNING `+userColumns+`;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", sl.Err(storageerror.ErrNotFound))
+			return models.User{}, fmt.Errorf("%s: %w", op, storageerror.ErrNotFound)
+		}
+
+		log.Error("Error purging user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.commitWithHistory(ctx, tx, audit, user, models.User{}); err != nil {
+		log.Error("Error committing user and history", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// PurgeDeleted implements app.IUsersStorage.
+// It removes users soft-deleted before the given time and returns their number.
+func (u *UsersSqliteStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.users.PurgeDeleted"
+	log := u.Log.With(
+		slog.String("op", op),
+	)
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("%s: %w", op, ctx.Err())
+	default:
+	}
+
+	result, err := u.DB.ExecContext(ctx, `
+		DELETE FROM `+u.TableName+`
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?;
+	`, before)
+	if err != nil {
+		log.Error("Error purging deleted users", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error get rows affected", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// readActive reads the user a write is about to change inside its
+// transaction, which holds the database write lock already. A non-zero
+// expectedVersion must match the stored one.
+func (u *UsersSqliteStorage) readActive(ctx context.Context, tx *sql.Tx, uid uuid.UUID, expectedVersion int64) (models.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM `+u.TableName+`
+		WHERE id=? AND deleted_at IS NULL;
+	`, uid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storageerror.ErrNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return models.User{}, storageerror.ErrConflict
+	}
+
+	return user, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var (
+		user        models.User
+		lastLoginAt sql.NullTime
+		deletedAt   sql.NullTime
+	)
+
+	err := row.Scan(&user.Id, &user.Login, &user.Password, &user.Role, &user.Email, &user.DisplayName,
+		&user.Locale, &user.Timezone, &user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &deletedAt, &user.Version)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.LastLoginAt = lastLoginAt.Time
+	user.DeletedAt = deletedAt.Time
+
+	return user, nil
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
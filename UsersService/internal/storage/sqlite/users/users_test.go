@@ -0,0 +1,513 @@
+package userssqlitestorage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"usersservice/internal/domain/models"
+	"usersservice/internal/lib/migrator"
+	storageerror "usersservice/internal/storage"
+	userssqlitestorage "usersservice/internal/storage/sqlite/users"
+	"usersservice/pkg/lib/logger"
+
+	"github.com/google/uuid"
+)
+
+func newTestStorage(t *testing.T) *userssqlitestorage.UsersSqliteStorage {
+	db, err := sql.Open("sqlite", userssqlitestorage.DSN(filepath.Join(t.TempDir(), "users.db")))
+	if err != nil {
+		t.Fatalf("failed to open database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	log := logger.SetupLogger("local")
+	m, err := migrator.New(log, db, migrator.DriverSqlite)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %s", err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %s", err)
+	}
+
+	return &userssqlitestorage.UsersSqliteStorage{
+		Log:       log,
+		DB:        db,
+		TableName: "users",
+	}
+}
+
+// equalUsers compares timestamps by instant, the driver may return them in
+// another location.
+func equalUsers(a, b models.User) bool {
+	return a.Id == b.Id && a.Login == b.Login && a.Password == b.Password && a.Role == b.Role &&
+		a.Email == b.Email && a.DisplayName == b.DisplayName && a.Locale == b.Locale && a.Timezone == b.Timezone &&
+		a.CreatedAt.Equal(b.CreatedAt) && a.UpdatedAt.Equal(b.UpdatedAt) && a.LastLoginAt.Equal(b.LastLoginAt) &&
+		a.DeletedAt.Equal(b.DeletedAt) && a.Version == b.Version
+}
+
+func TestInsertAndGet(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 123000000, time.UTC)
+	user := models.User{
+		Id:          uuid.New(),
+		Login:       "Alice",
+		Password:    "hash",
+		Role:        "admin",
+		Email:       "alice@example.com",
+		DisplayName: "Alice",
+		Locale:      "en-GB",
+		Timezone:    "Europe/London",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := storage.GetUserById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !equalUsers(got, user) {
+		t.Errorf("expected %v, got %v", user, got)
+	}
+	if !got.LastLoginAt.IsZero() {
+		t.Errorf("expected no last login, got %v", got.LastLoginAt)
+	}
+
+	got, err = storage.GetUserByLogin(context.Background(), "aLiCe")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Id != user.Id {
+		t.Errorf("expected %v, got %v", user.Id, got.Id)
+	}
+
+	if _, err := storage.GetUserById(context.Background(), uuid.New()); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInsert_AlreadyExists(t *testing.T) {
+	storage := newTestStorage(t)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user"}
+
+	if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := storage.Insert(context.Background(), models.User{Id: user.Id, Login: "bob", Password: "hash", Role: "user"}, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for taken id, got %v", err)
+	}
+
+	_, err = storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "ALICE", Password: "hash", Role: "user"}, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for taken login, got %v", err)
+	}
+}
+
+func TestGetUsers_KeysetPages(t *testing.T) {
+	storage := newTestStorage(t)
+	for _, login := range []string{"carol", "al_ice", "alfred", "bob"} {
+		user := models.User{Id: uuid.New(), Login: login, Password: "hash", Role: "user"}
+		if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	sortByLogin := models.UsersSort{By: models.SortByLogin}
+	page, err := storage.GetUsers(context.Background(), models.UsersQuery{Sort: sortByLogin, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 2 || page[0].Login != "al_ice" || page[1].Login != "alfred" {
+		t.Fatalf("unexpected first page: %v", page)
+	}
+
+	last := page[1]
+	page, err = storage.GetUsers(context.Background(), models.UsersQuery{
+		Sort:  sortByLogin,
+		After: &models.UsersCursor{Id: last.Id, Login: last.Login, Sort: sortByLogin},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 2 || page[0].Login != "bob" || page[1].Login != "carol" {
+		t.Errorf("unexpected second page: %v", page)
+	}
+
+	// "_" must match literally, not as a LIKE wildcard
+	page, err = storage.GetUsers(context.Background(), models.UsersQuery{
+		Filter: models.UsersFilter{LoginPrefix: "AL_"},
+		Sort:   models.UsersSort{By: models.SortById},
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 1 || page[0].Login != "al_ice" {
+		t.Errorf("unexpected filtered page: %v", page)
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	storage := newTestStorage(t)
+	createdAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	alice := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", CreatedAt: createdAt, UpdatedAt: createdAt}
+	bob := models.User{Id: uuid.New(), Login: "bob", Password: "hash", Role: "user", CreatedAt: createdAt, UpdatedAt: createdAt}
+	for _, user := range []models.User{alice, bob} {
+		if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	_, err := storage.Update(context.Background(), bob.Id, models.User{Login: "Alice", Password: "hash", Role: "user"}, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	updatedAt := bob.CreatedAt.Add(time.Hour)
+	updated, err := storage.Update(context.Background(), bob.Id, models.User{Login: "robert", Password: "hash", Role: "admin", Email: "bob@example.com", UpdatedAt: updatedAt}, models.UpdatableFields, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Id != bob.Id || updated.Login != "robert" || updated.Email != "bob@example.com" {
+		t.Errorf("unexpected updated user: %v", updated)
+	}
+	if !updated.CreatedAt.Equal(bob.CreatedAt) || !updated.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("unexpected timestamps: created %v, updated %v", updated.CreatedAt, updated.UpdatedAt)
+	}
+
+	_, err = storage.Update(context.Background(), uuid.New(), models.User{Login: "carol", Password: "hash", Role: "user"}, models.UpdatableFields, 0, nil)
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	deletedAt := createdAt.Add(2 * time.Hour)
+	deleted, err := storage.Delete(context.Background(), alice.Id, deletedAt, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	alice.UpdatedAt, alice.DeletedAt, alice.Version = deletedAt, deletedAt, alice.Version+1
+	if !equalUsers(deleted, alice) {
+		t.Errorf("expected %v, got %v", alice, deleted)
+	}
+
+	if _, err := storage.Delete(context.Background(), alice.Id, deletedAt, 0, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUpdate_Fields(t *testing.T) {
+	storage := newTestStorage(t)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Timezone: "UTC", Version: 1}
+	if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := storage.Update(context.Background(), user.Id, models.User{Role: "admin", Email: "alice@example.com"},
+		[]string{models.FieldEmail, models.FieldRole}, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Role != "admin" || updated.Email != "alice@example.com" {
+		t.Errorf("role and email must change, got %v", updated)
+	}
+	if updated.Login != "alice" || updated.Password != "hash" || updated.Timezone != "UTC" {
+		t.Errorf("other fields must be kept, got %v", updated)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	storage := newTestStorage(t)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Version: 1}
+	if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := storage.Update(context.Background(), user.Id, models.User{Login: "alice", Password: "hash", Role: "admin"}, models.UpdatableFields, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("expected version 2, got %d", updated.Version)
+	}
+
+	// a writer that read version 1 lost the race
+	_, err = storage.Update(context.Background(), user.Id, models.User{Login: "alice", Password: "hash", Role: "user"}, models.UpdatableFields, 1, nil)
+	if !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if _, err := storage.Delete(context.Background(), user.Id, time.Now(), 1, nil); !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	_, err = storage.Update(context.Background(), uuid.New(), models.User{Login: "bob", Password: "hash", Role: "user"}, models.UpdatableFields, 1, nil)
+	if !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing user, got %v", err)
+	}
+
+	// logins do not change the version
+	if err := storage.SetLastLogin(context.Background(), user.Id, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleted, err := storage.Delete(context.Background(), user.Id, time.Now(), 2, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted.Version != 3 {
+		t.Errorf("expected version 3, got %d", deleted.Version)
+	}
+}
+
+func TestSoftDelete_RestoreAndPurge(t *testing.T) {
+	storage := newTestStorage(t)
+	alice := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user"}
+	bob := models.User{Id: uuid.New(), Login: "bob", Password: "hash", Role: "user"}
+	for _, user := range []models.User{alice, bob} {
+		if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	deletedAt := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for _, user := range []models.User{alice, bob} {
+		if _, err := storage.Delete(context.Background(), user.Id, deletedAt, 0, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// deleted users are hidden from every read, but keep their login
+	if _, err := storage.GetUserById(context.Background(), alice.Id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound by id, got %v", err)
+	}
+	if _, err := storage.GetUserByLogin(context.Background(), "alice"); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound by login, got %v", err)
+	}
+	if users, err := storage.GetUsers(context.Background(), models.UsersQuery{Limit: 10}); err != nil || len(users) != 0 {
+		t.Errorf("expected no users, got %v, %v", users, err)
+	}
+	if _, err := storage.Update(context.Background(), alice.Id, models.User{Login: "alice", Password: "hash", Role: "user"}, models.UpdatableFields, 0, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on update, got %v", err)
+	}
+	if _, err := storage.Insert(context.Background(), models.User{Id: uuid.New(), Login: "Alice", Password: "hash", Role: "user"}, nil); !errors.Is(err, storageerror.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	restoredAt := deletedAt.Add(time.Hour)
+	restored, err := storage.Restore(context.Background(), alice.Id, restoredAt, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !restored.DeletedAt.IsZero() || !restored.UpdatedAt.Equal(restoredAt) {
+		t.Errorf("unexpected restored user: %v", restored)
+	}
+	if _, err := storage.GetUserByLogin(context.Background(), "alice"); err != nil {
+		t.Errorf("restored user must be readable, got %v", err)
+	}
+	if _, err := storage.Restore(context.Background(), alice.Id, restoredAt, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an active user, got %v", err)
+	}
+
+	purged, err := storage.PurgeDeleted(context.Background(), deletedAt.Add(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged user, got %d", purged)
+	}
+	if _, err := storage.Restore(context.Background(), bob.Id, restoredAt, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("purged user must be gone, got %v", err)
+	}
+
+	if _, err := storage.Purge(context.Background(), alice.Id, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Purge(context.Background(), alice.Id, nil); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetLastLogin(t *testing.T) {
+	storage := newTestStorage(t)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user"}
+	if _, err := storage.Insert(context.Background(), user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	at := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	if err := storage.SetLastLogin(context.Background(), user.Id, at); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := storage.GetUserById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.LastLoginAt.Equal(at) {
+		t.Errorf("expected last login %v, got %v", at, got.LastLoginAt)
+	}
+
+	if err := storage.SetLastLogin(context.Background(), uuid.New(), at); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetPassword_KeepsVersion(t *testing.T) {
+	storage := newTestStorage(t)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "old", Role: "user", Version: 1}
+	inserted, err := storage.Insert(context.Background(), user, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := storage.SetPassword(context.Background(), user.Id, "old", "new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := storage.GetUserById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Password != "new" || got.Version != inserted.Version || !got.UpdatedAt.Equal(inserted.UpdatedAt) {
+		t.Errorf("expected only the password to change, got %+v", got)
+	}
+
+	if err := storage.SetPassword(context.Background(), user.Id, "old", "newer"); !errors.Is(err, storageerror.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+// audit builds entries that keep the role before and after a write.
+func audit(uid uuid.UUID, operation string, changedAt time.Time) models.Audit {
+	id := uuid.Must(uuid.NewV7())
+	return func(before models.User, after models.User) models.HistoryEntry {
+		return models.HistoryEntry{
+			Id:        id,
+			UserId:    uid,
+			Actor:     "admin-id",
+			Operation: operation,
+			ChangedAt: changedAt,
+			Changes:   []models.FieldChange{{Field: models.FieldRole, Before: before.Role, After: after.Role}},
+		}
+	}
+}
+
+func TestHistory_PagesAndAppendOnly(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	changedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Version: 1}
+	userId := user.Id
+
+	if _, err := storage.Insert(ctx, user, audit(userId, models.OperationInsert, changedAt)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Update(ctx, userId, models.User{Role: "admin"}, []string{models.FieldRole}, 0, audit(userId, models.OperationUpdate, changedAt)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Delete(ctx, userId, changedAt, 0, audit(userId, models.OperationDelete, changedAt)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// another user's entry stays out of the page
+	other := models.User{Id: uuid.New(), Login: "bob", Password: "hash", Role: "user", Version: 1}
+	if _, err := storage.Insert(ctx, other, audit(other.Id, models.OperationInsert, changedAt)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, err := storage.GetUserHistory(ctx, models.HistoryQuery{UserId: userId, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 2 || first[0].Operation != models.OperationDelete || first[1].Operation != models.OperationUpdate {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	if !first[0].ChangedAt.Equal(changedAt) || first[1].Changes[0] != (models.FieldChange{Field: models.FieldRole, Before: "user", After: "admin"}) {
+		t.Errorf("unexpected entries: %+v", first)
+	}
+
+	second, err := storage.GetUserHistory(ctx, models.HistoryQuery{UserId: userId, After: first[1].Id, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(second) != 1 || second[0].Operation != models.OperationInsert {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	if _, err := storage.DB.ExecContext(ctx, "UPDATE user_history SET actor = 'someone'"); err == nil {
+		t.Error("expected history updates to be rejected")
+	}
+	if _, err := storage.DB.ExecContext(ctx, "DELETE FROM user_history"); err == nil {
+		t.Error("expected history deletes to be rejected")
+	}
+}
+
+func TestHistory_FailureRollsBackWrite(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	changedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	alice := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Version: 1}
+	taken := audit(alice.Id, models.OperationInsert, changedAt)
+	if _, err := storage.Insert(ctx, alice, taken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// an entry id that is already taken makes the history insert fail
+	bob := models.User{Id: uuid.New(), Login: "bob", Password: "hash", Role: "user", Version: 1}
+	if _, err := storage.Insert(ctx, bob, taken); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := storage.GetUserById(ctx, bob.Id); !errors.Is(err, storageerror.ErrNotFound) {
+		t.Errorf("expected the insert to be rolled back, got %v", err)
+	}
+
+	if _, err := storage.Update(ctx, alice.Id, models.User{Role: "admin"}, []string{models.FieldRole}, 0, taken); err == nil {
+		t.Fatal("expected an error")
+	}
+	stored, err := storage.GetUserById(ctx, alice.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Role != "user" || stored.Version != 1 {
+		t.Errorf("expected the update to be rolled back, got %+v", stored)
+	}
+}
+
+func TestHistory_RestoreSeesDeletion(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	deletedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := models.User{Id: uuid.New(), Login: "alice", Password: "hash", Role: "user", Version: 1}
+	if _, err := storage.Insert(ctx, user, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var deleteBefore, restoreBefore models.User
+	_, err := storage.Delete(ctx, user.Id, deletedAt, 0, func(before models.User, after models.User) models.HistoryEntry {
+		deleteBefore = before
+		return models.HistoryEntry{Id: uuid.Must(uuid.NewV7()), UserId: user.Id, Operation: models.OperationDelete}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = storage.Restore(ctx, user.Id, deletedAt.Add(time.Hour), func(before models.User, after models.User) models.HistoryEntry {
+		restoreBefore = before
+		return models.HistoryEntry{Id: uuid.Must(uuid.NewV7()), UserId: user.Id, Operation: models.OperationRestore}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !deleteBefore.DeletedAt.IsZero() || deleteBefore.Version != 1 {
+		t.Errorf("unexpected state before delete: %+v", deleteBefore)
+	}
+	if !restoreBefore.DeletedAt.Equal(deletedAt) || restoreBefore.Version != 2 {
+		t.Errorf("unexpected state before restore: %+v", restoreBefore)
+	}
+}
@@ -5,4 +5,5 @@ import "errors"
 var (
 	ErrNotFound      = errors.New("resourse not found")
 	ErrAlreadyExists = errors.New("resourse already exists")
+	ErrConflict      = errors.New("resourse version conflict")
 )
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Описание: Эта миграция расширяет колонку password под хэши bcrypt/argon2id
+-- SQLite не ограничивает длину VARCHAR, ему расширять нечего
+{{if .Postgres -}}
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+{{- else -}}
+SELECT 1;
+{{- end}}
+
+-- +goose Down
+-- Описание: Откат ничего не делает: хэши bcrypt (60 символов) и argon2id не
+-- помещаются в прежние VARCHAR(50), поэтому колонка остаётся широкой
+SELECT 1;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет уникальный регистронезависимый индекс на login
+-- Перед применением необходимо устранить дубликаты логинов, отличающиеся только регистром
+-- lower() в SQLite понимает только ASCII, поэтому UsersService хранит логины
+-- уже в нижнем регистре, и индекс одинаково строг в обеих СУБД
+CREATE UNIQUE INDEX users_login_lower_idx ON users (lower(login));
+
+-- +goose Down
+-- Описание: Эта миграция удаляет индекс на login
+DROP INDEX users_login_lower_idx;
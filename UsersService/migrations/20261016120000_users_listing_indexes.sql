@@ -0,0 +1,10 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет индексы для постраничной выборки пользователей
+-- (keyset-пагинация по login и фильтрация по роли)
+CREATE INDEX users_login_id_idx ON users (login, id);
+CREATE INDEX users_role_id_idx ON users (role, id);
+
+-- +goose Down
+-- Описание: Эта миграция удаляет индексы постраничной выборки
+DROP INDEX users_role_id_idx;
+DROP INDEX users_login_id_idx;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет контактные поля профиля и временные метки
+-- Существующим пользователям created_at и updated_at проставляются временем миграции
+-- SQLite не допускает now() в ADD COLUMN и добавляет колонки по одной, поэтому
+-- время в нем проставляется отдельным UPDATE
+ALTER TABLE users ADD COLUMN email VARCHAR(254) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+{{- if .Postgres}}
+ALTER TABLE users ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+{{- else}}
+ALTER TABLE users ADD COLUMN created_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE users ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE users SET
+    created_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+{{- end}}
+ALTER TABLE users ADD COLUMN last_login_at {{.Timestamp}};
+
+-- +goose Down
+-- Описание: Эта миграция удаляет поля профиля
+ALTER TABLE users DROP COLUMN last_login_at;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN display_name;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет мягкое удаление пользователей
+-- Удаленный пользователь сохраняет свой логин до окончательной очистки, поэтому
+-- уникальный индекс по lower(login) не меняется и Restore не может столкнуться
+-- с занятым логином
+ALTER TABLE users ADD COLUMN deleted_at {{.Timestamp}};
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+-- Описание: Эта миграция удаляет мягкое удаление
+DROP INDEX users_deleted_at_idx;
+ALTER TABLE users DROP COLUMN deleted_at;
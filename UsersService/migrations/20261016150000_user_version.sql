@@ -0,0 +1,7 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет версию пользователя для оптимистичных блокировок
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+-- Описание: Эта миграция удаляет версию пользователя
+ALTER TABLE users DROP COLUMN version;
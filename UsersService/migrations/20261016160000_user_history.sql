@@ -0,0 +1,47 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет журнал изменений пользователей
+-- Записи только добавляются: id — UUIDv7, поэтому порядок id совпадает с
+-- порядком записи. Внешнего ключа на users нет, журнал переживает очистку
+CREATE TABLE user_history (
+    id {{.UUID}} PRIMARY KEY,
+    user_id {{.UUID}} NOT NULL,
+    actor TEXT NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    changed_at {{.Timestamp}} NOT NULL,
+    changes {{.JSON}} NOT NULL
+);
+CREATE INDEX user_history_user_id_idx ON user_history (user_id, id DESC);
+{{if .Postgres}}
+-- +goose StatementBegin
+CREATE FUNCTION user_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'user_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER user_history_append_only
+    BEFORE UPDATE OR DELETE ON user_history
+    FOR EACH ROW EXECUTE FUNCTION user_history_append_only();
+{{- else}}
+-- +goose StatementBegin
+CREATE TRIGGER user_history_no_update BEFORE UPDATE ON user_history
+BEGIN
+    SELECT RAISE(ABORT, 'user_history is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER user_history_no_delete BEFORE DELETE ON user_history
+BEGIN
+    SELECT RAISE(ABORT, 'user_history is append-only');
+END;
+-- +goose StatementEnd
+{{- end}}
+
+-- +goose Down
+-- Описание: Эта миграция удаляет журнал изменений пользователей
+DROP TABLE user_history;
+{{- if .Postgres}}
+DROP FUNCTION user_history_append_only();
+{{- end}}
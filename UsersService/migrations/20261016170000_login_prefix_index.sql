@@ -0,0 +1,19 @@
+-- +goose Up
+-- Описание: Эта миграция добавляет индекс для фильтра по префиксу login
+-- Логины хранятся в нижнем регистре, поэтому фильтр login LIKE 'префикс%'
+-- обходится без lower(); text_pattern_ops нужен, чтобы LIKE использовал
+-- индекс при любой collation базы
+-- В SQLite LIKE регистронезависим и такой индекс не использует
+{{if .Postgres -}}
+CREATE INDEX users_login_pattern_idx ON users (login text_pattern_ops);
+{{- else -}}
+SELECT 1;
+{{- end}}
+
+-- +goose Down
+-- Описание: Эта миграция удаляет индекс для фильтра по префиксу login
+{{if .Postgres -}}
+DROP INDEX users_login_pattern_idx;
+{{- else -}}
+SELECT 1;
+{{- end}}
@@ -0,0 +1,90 @@
+// Package migrations embeds the SQL migrations into the binary, so they no
+// longer have to be shipped next to it.
+//
+// PostgreSQL and SQLite share one set of migrations. Files are text
+// templates: types that differ are written as {{.UUID}}, {{.Timestamp}}
+// and {{.JSON}}, statements that differ sit in {{if .Postgres}} blocks.
+// Migrations that were applied before templates came in stay untouched,
+// a file of the same name in sqlite/ replaces them for SQLite.
+package migrations
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"path"
+	"testing/fstest"
+	"text/template"
+)
+
+//go:embed *.sql sqlite/*.sql
+var files embed.FS
+
+type dialect struct {
+	// Overrides is the directory of the files replacing migrations of the
+	// same name, empty for none.
+	Overrides string
+	Postgres  bool
+	UUID      string
+	Timestamp string
+	JSON      string
+}
+
+var (
+	postgres = dialect{
+		Postgres:  true,
+		UUID:      "UUID",
+		Timestamp: "TIMESTAMPTZ",
+		JSON:      "JSONB",
+	}
+	// the SQLite driver scans DATETIME columns into time.Time, ids and JSON
+	// are kept as text
+	sqlite = dialect{
+		Overrides: "sqlite",
+		UUID:      "TEXT",
+		Timestamp: "DATETIME",
+		JSON:      "TEXT",
+	}
+)
+
+// Postgres returns the PostgreSQL migrations.
+func Postgres() fs.FS {
+	return render(postgres)
+}
+
+// SQLite returns the SQLite flavour of the same migrations.
+func SQLite() fs.FS {
+	return render(sqlite)
+}
+
+// render executes every migration for d. The migrations are embedded, so
+// a broken template is a programming error.
+func render(d dialect) fs.FS {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	rendered := make(fstest.MapFS, len(names))
+	for _, name := range names {
+		file := name
+		if d.Overrides != "" {
+			if _, err := fs.Stat(files, path.Join(d.Overrides, name)); err == nil {
+				file = path.Join(d.Overrides, name)
+			}
+		}
+
+		tmpl, err := template.ParseFS(files, file)
+		if err != nil {
+			panic(err)
+		}
+
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, d); err != nil {
+			panic(err)
+		}
+		rendered[name] = &fstest.MapFile{Data: b.Bytes(), Mode: 0o444}
+	}
+
+	return rendered
+}
@@ -0,0 +1,74 @@
+package migrations_test
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"usersservice/migrations"
+)
+
+func TestDialects_RenderEveryMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		fsys     fs.FS
+		contains string
+		excludes string
+	}{
+		{name: "postgres", fsys: migrations.Postgres(), contains: "TIMESTAMPTZ", excludes: "DATETIME"},
+		{name: "sqlite", fsys: migrations.SQLite(), contains: "DATETIME", excludes: "TIMESTAMPTZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := fs.Glob(tt.fsys, "*.sql")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(names) == 0 {
+				t.Fatalf("no migrations rendered")
+			}
+
+			var all strings.Builder
+			for _, name := range names {
+				data, err := fs.ReadFile(tt.fsys, name)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !strings.Contains(string(data), "-- +goose Up") || strings.Contains(string(data), "{{") {
+					t.Errorf("%s is not a rendered goose migration:\n%s", name, data)
+				}
+				all.Write(data)
+			}
+
+			if !strings.Contains(all.String(), tt.contains) || strings.Contains(all.String(), tt.excludes) {
+				t.Errorf("expected %s types only", tt.name)
+			}
+		})
+	}
+}
+
+func TestDialects_BaselineMigration(t *testing.T) {
+	const name = "20250530144824_new_user_table.sql"
+
+	original, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	postgres, err := fs.ReadFile(migrations.Postgres(), name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(postgres) != string(original) {
+		t.Errorf("expected the applied migration to be rendered as it is")
+	}
+
+	sqlite, err := fs.ReadFile(migrations.SQLite(), name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(sqlite), "id TEXT PRIMARY KEY") {
+		t.Errorf("expected the SQLite override, got:\n%s", sqlite)
+	}
+}
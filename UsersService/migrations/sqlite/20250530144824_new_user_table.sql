@@ -0,0 +1,14 @@
+-- +goose Up
+-- Описание: Эта миграция создает таблицу users (SQLite)
+-- Заменяет одноименную миграцию PostgreSQL, которую нельзя менять после применения
+-- id хранится текстом в каноническом виде, порядок строк совпадает с порядком UUID
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    login VARCHAR(50) NOT NULL,
+    password VARCHAR(50) NOT NULL,
+    role VARCHAR(100) NOT NULL
+);
+
+-- +goose Down
+-- Описание: Эта миграция удаляет таблицу users
+DROP TABLE users;
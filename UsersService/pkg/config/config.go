@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -13,12 +14,29 @@ import (
 type Config struct {
 	Env                    string `yaml:"env" env-default:"local"`
 	Port                   int    `yaml:"port" env:"PORT" env-default:"8080"`
+	StorageDriver          string `yaml:"storage_driver" env:"STORAGE_DRIVER" env-default:"postgres"`
+	MongoDBURI             string `yaml:"mongodb_uri" env:"MONGODB_URI" json:"-"`
 	MongoDBHost            string `yaml:"mongodb_host" env:"MONGODB_HOST" env-default:"mongo_cont"`
 	MongoDBPort            int    `yaml:"mongodb_port" env:"MONGODB_PORT" env-default:"27017"`
 	MongoDBDBName          string `yaml:"mongodb_db_name" env:"MONGODB_DB_NAME" env-default:"users"`
 	MongoDBUsersCollection string `yaml:"mongodb_users_collection_name" env:"MONGODB_USERS_COLLECTION_NAME" env-default:"users"`
+	MongoDBUsername        string `yaml:"mongodb_username" env:"MONGODB_USERNAME"`
+	MongoDBPassword        string `yaml:"mongodb_password" env:"MONGODB_PASSWORD" json:"-"`
+	MongoDBAuthSource      string `yaml:"mongodb_auth_source" env:"MONGODB_AUTH_SOURCE" env-default:"admin"`
 	PsqlConnStr            string `yaml:"psql_conn_str" env:"PSQL_CONN_STR"`
 	PsqlUsersTableName     string `yaml:"psql_users_table_name" env:"PSQL_USERS_TABLE_NAME"`
+	SqlitePath             string `yaml:"sqlite_path" env:"SQLITE_PATH" env-default:"users.db"`
+	AutoMigrate            bool   `yaml:"auto_migrate" env:"AUTO_MIGRATE" env-default:"false"`
+
+	PurgeInterval    time.Duration `yaml:"purge_interval" env:"PURGE_INTERVAL" env-default:"1h"`
+	DeletedRetention time.Duration `yaml:"deleted_retention" env:"DELETED_RETENTION" env-default:"720h"`
+
+	PasswordHashAlgorithm string `yaml:"password_hash_algorithm" env:"PASSWORD_HASH_ALGORITHM" env-default:"bcrypt"`
+	BcryptCost            int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" env-default:"10"`
+	Argon2Time            uint32 `yaml:"argon2_time" env:"ARGON2_TIME" env-default:"1"`
+	Argon2Memory          uint32 `yaml:"argon2_memory" env:"ARGON2_MEMORY" env-default:"65536"`
+	Argon2Threads         uint8  `yaml:"argon2_threads" env:"ARGON2_THREADS" env-default:"4"`
+	PasswordPepper        string `yaml:"password_pepper" env:"PASSWORD_PEPPER" json:"-"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,154 @@
+// Package apierror carries the errors of the catalogue in
+// gen/go/errors between the services. An Error travels as a gRPC status
+// with google.rpc.ErrorInfo and google.rpc.BadRequest details, so that
+// every hop can pass it on unchanged instead of flattening it to a code.
+package apierror
+
+import (
+	"errors"
+
+	errorsv1 "github.com/chas3air/protos/gen/go/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain tells the errors of the catalogue from others in ErrorInfo.
+const Domain = "usersconnector.chas3air"
+
+type Reason = errorsv1.ErrorReason
+
+const (
+	MalformedRequest   = errorsv1.ErrorReason_MALFORMED_REQUEST
+	ValidationFailed   = errorsv1.ErrorReason_VALIDATION_FAILED
+	UserNotFound       = errorsv1.ErrorReason_USER_NOT_FOUND
+	UserAlreadyExists  = errorsv1.ErrorReason_USER_ALREADY_EXISTS
+	VersionConflict    = errorsv1.ErrorReason_VERSION_CONFLICT
+	InvalidCredentials = errorsv1.ErrorReason_INVALID_CREDENTIALS
+	InvalidToken       = errorsv1.ErrorReason_INVALID_TOKEN
+	PermissionDenied   = errorsv1.ErrorReason_PERMISSION_DENIED
+	DeadlineExceeded   = errorsv1.ErrorReason_DEADLINE_EXCEEDED
+	Unavailable        = errorsv1.ErrorReason_UNAVAILABLE
+	Internal           = errorsv1.ErrorReason_INTERNAL
+)
+
+var codesByReason = map[Reason]codes.Code{
+	MalformedRequest:   codes.InvalidArgument,
+	ValidationFailed:   codes.InvalidArgument,
+	UserNotFound:       codes.NotFound,
+	UserAlreadyExists:  codes.AlreadyExists,
+	VersionConflict:    codes.Aborted,
+	InvalidCredentials: codes.Unauthenticated,
+	InvalidToken:       codes.Unauthenticated,
+	PermissionDenied:   codes.PermissionDenied,
+	DeadlineExceeded:   codes.DeadlineExceeded,
+	Unavailable:        codes.Unavailable,
+	Internal:           codes.Internal,
+}
+
+// FieldViolation names an invalid field of the request.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is an error of the catalogue. Message is meant for the client.
+type Error struct {
+	Reason     Reason
+	Message    string
+	Violations []FieldViolation
+}
+
+func New(reason Reason, message string, violations ...FieldViolation) *Error {
+	return &Error{
+		Reason:     reason,
+		Message:    message,
+		Violations: violations,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Reason.String() + ": " + e.Message
+}
+
+// Code is the gRPC code of the reason, codes.Unknown for reasons outside
+// the catalogue.
+func (e *Error) Code() codes.Code {
+	if code, ok := codesByReason[e.Reason]; ok {
+		return code
+	}
+
+	return codes.Unknown
+}
+
+// GRPCStatus lets a gRPC server return an Error as is.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code(), e.Message)
+
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason: e.Reason.String(),
+			Domain: Domain,
+		},
+	}
+	if len(e.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	// details are well-formed messages, WithDetails can not fail on them
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+
+	return withDetails
+}
+
+// FromError finds the Error in err, either an Error wrapped in it or a
+// gRPC status carrying one. Statuses without an ErrorInfo of Domain are
+// not errors of the catalogue, ok is false for them.
+func FromError(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+
+	// the status of a wrapped error, not the one status.FromError builds
+	// with the message of the whole chain
+	var gs interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &gs) || gs.GRPCStatus() == nil {
+		return nil, false
+	}
+	st := gs.GRPCStatus()
+
+	var info *errdetails.ErrorInfo
+	var violations []FieldViolation
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() == Domain {
+				info = d
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				violations = append(violations, FieldViolation{
+					Field:       v.GetField(),
+					Description: v.GetDescription(),
+				})
+			}
+		}
+	}
+	if info == nil {
+		return nil, false
+	}
+
+	return New(Reason(errorsv1.ErrorReason_value[info.GetReason()]), st.Message(), violations...), true
+}
@@ -0,0 +1,49 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFromError_RoundTrip(t *testing.T) {
+	sent := New(ValidationFailed, "invalid user", FieldViolation{Field: "email", Description: "malformed email"})
+
+	// what a client gets back from the wire
+	received := status.ErrorProto(sent.GRPCStatus().Proto())
+	if status.Code(received) != codes.InvalidArgument {
+		t.Fatalf("code = %v, want %v", status.Code(received), codes.InvalidArgument)
+	}
+
+	got, ok := FromError(fmt.Errorf("storage: %w", received))
+	if !ok {
+		t.Fatal("FromError did not find the error")
+	}
+	if !reflect.DeepEqual(got, sent) {
+		t.Fatalf("FromError = %+v, want %+v", got, sent)
+	}
+}
+
+func TestFromError_Wrapped(t *testing.T) {
+	sent := New(UserNotFound, "user not found")
+
+	got, ok := FromError(fmt.Errorf("service: %w", sent))
+	if !ok || got != sent {
+		t.Fatalf("FromError = %v, %v, want %v", got, ok, sent)
+	}
+}
+
+func TestFromError_NotCatalogued(t *testing.T) {
+	for _, err := range []error{
+		errors.New("plain"),
+		status.Error(codes.Unavailable, "connection refused"),
+	} {
+		if got, ok := FromError(err); ok {
+			t.Fatalf("FromError(%v) = %v, want none", err, got)
+		}
+	}
+}
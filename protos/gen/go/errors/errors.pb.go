@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        v5.29.3
+// source: errors/errors.proto
+
+package errorsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ErrorReason is the catalogue of errors shared by the services. A failed
+// call carries its reason in google.rpc.ErrorInfo (the enum value name,
+// domain "usersconnector.chas3air") and the invalid fields, if any, in
+// google.rpc.BadRequest.
+type ErrorReason int32
+
+const (
+	ErrorReason_ERROR_REASON_UNSPECIFIED ErrorReason = 0
+	// The request could not be read, e.g. a malformed id or cursor.
+	ErrorReason_MALFORMED_REQUEST ErrorReason = 1
+	// The request was read but some fields are invalid.
+	ErrorReason_VALIDATION_FAILED   ErrorReason = 2
+	ErrorReason_USER_NOT_FOUND      ErrorReason = 3
+	ErrorReason_USER_ALREADY_EXISTS ErrorReason = 4
+	// The user changed since the version the write was conditional on.
+	ErrorReason_VERSION_CONFLICT    ErrorReason = 5
+	ErrorReason_INVALID_CREDENTIALS ErrorReason = 6
+	// The token is malformed, expired, revoked or was reused.
+	ErrorReason_INVALID_TOKEN     ErrorReason = 7
+	ErrorReason_PERMISSION_DENIED ErrorReason = 8
+	ErrorReason_DEADLINE_EXCEEDED ErrorReason = 9
+	ErrorReason_UNAVAILABLE       ErrorReason = 10
+	ErrorReason_INTERNAL          ErrorReason = 11
+)
+
+// Enum value maps for ErrorReason.
+var (
+	ErrorReason_name = map[int32]string{
+		0:  "ERROR_REASON_UNSPECIFIED",
+		1:  "MALFORMED_REQUEST",
+		2:  "VALIDATION_FAILED",
+		3:  "USER_NOT_FOUND",
+		4:  "USER_ALREADY_EXISTS",
+		5:  "VERSION_CONFLICT",
+		6:  "INVALID_CREDENTIALS",
+		7:  "INVALID_TOKEN",
+		8:  "PERMISSION_DENIED",
+		9:  "DEADLINE_EXCEEDED",
+		10: "UNAVAILABLE",
+		11: "INTERNAL",
+	}
+	ErrorReason_value = map[string]int32{
+		"ERROR_REASON_UNSPECIFIED": 0,
+		"MALFORMED_REQUEST":        1,
+		"VALIDATION_FAILED":        2,
+		"USER_NOT_FOUND":           3,
+		"USER_ALREADY_EXISTS":      4,
+		"VERSION_CONFLICT":         5,
+		"INVALID_CREDENTIALS":      6,
+		"INVALID_TOKEN":            7,
+		"PERMISSION_DENIED":        8,
+		"DEADLINE_EXCEEDED":        9,
+		"UNAVAILABLE":              10,
+		"INTERNAL":                 11,
+	}
+)
+
+func (x ErrorReason) Enum() *ErrorReason {
+	p := new(ErrorReason)
+	*p = x
+	return p
+}
+
+func (x ErrorReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_errors_errors_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorReason) Type() protoreflect.EnumType {
+	return &file_errors_errors_proto_enumTypes[0]
+}
+
+func (x ErrorReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorReason.Descriptor instead.
+func (ErrorReason) EnumDescriptor() ([]byte, []int) {
+	return file_errors_errors_proto_rawDescGZIP(), []int{0}
+}
+
+var File_errors_errors_proto protoreflect.FileDescriptor
+
+var file_errors_errors_proto_rawDesc = string([]byte{
+	0x0a, 0x13, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x2f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x68,
+	0x61, 0x73, 0x33, 0x61, 0x69, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x73, 0x2a, 0x95, 0x02, 0x0a, 0x0b, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x18, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x52, 0x45,
+	0x41, 0x53, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
+	0x10, 0x00, 0x12, 0x15, 0x0a, 0x11, 0x4d, 0x41, 0x4c, 0x46, 0x4f, 0x52, 0x4d, 0x45, 0x44, 0x5f,
+	0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x01, 0x12, 0x15, 0x0a, 0x11, 0x56, 0x41, 0x4c,
+	0x49, 0x44, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x12, 0x0a, 0x0e, 0x55, 0x53, 0x45, 0x52, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55,
+	0x4e, 0x44, 0x10, 0x03, 0x12, 0x17, 0x0a, 0x13, 0x55, 0x53, 0x45, 0x52, 0x5f, 0x41, 0x4c, 0x52,
+	0x45, 0x41, 0x44, 0x59, 0x5f, 0x45, 0x58, 0x49, 0x53, 0x54, 0x53, 0x10, 0x04, 0x12, 0x14, 0x0a,
+	0x10, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43,
+	0x54, 0x10, 0x05, 0x12, 0x17, 0x0a, 0x13, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x43,
+	0x52, 0x45, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x41, 0x4c, 0x53, 0x10, 0x06, 0x12, 0x11, 0x0a, 0x0d,
+	0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x10, 0x07, 0x12,
+	0x15, 0x0a, 0x11, 0x50, 0x45, 0x52, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x44, 0x45,
+	0x4e, 0x49, 0x45, 0x44, 0x10, 0x08, 0x12, 0x15, 0x0a, 0x11, 0x44, 0x45, 0x41, 0x44, 0x4c, 0x49,
+	0x4e, 0x45, 0x5f, 0x45, 0x58, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x09, 0x12, 0x0f, 0x0a,
+	0x0b, 0x55, 0x4e, 0x41, 0x56, 0x41, 0x49, 0x4c, 0x41, 0x42, 0x4c, 0x45, 0x10, 0x0a, 0x12, 0x0c,
+	0x0a, 0x08, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x10, 0x0b, 0x42, 0x1d, 0x5a, 0x1b,
+	0x63, 0x68, 0x61, 0x73, 0x33, 0x61, 0x69, 0x72, 0x2e, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x2e,
+	0x76, 0x31, 0x3b, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+})
+
+var (
+	file_errors_errors_proto_rawDescOnce sync.Once
+	file_errors_errors_proto_rawDescData []byte
+)
+
+func file_errors_errors_proto_rawDescGZIP() []byte {
+	file_errors_errors_proto_rawDescOnce.Do(func() {
+		file_errors_errors_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_errors_errors_proto_rawDesc), len(file_errors_errors_proto_rawDesc)))
+	})
+	return file_errors_errors_proto_rawDescData
+}
+
+var file_errors_errors_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_errors_errors_proto_goTypes = []any{
+	(ErrorReason)(0), // 0: github.chas3air.protos.errors.ErrorReason
+}
+var file_errors_errors_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_errors_errors_proto_init() }
+func file_errors_errors_proto_init() {
+	if File_errors_errors_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_errors_errors_proto_rawDesc), len(file_errors_errors_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   0,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_errors_errors_proto_goTypes,
+		DependencyIndexes: file_errors_errors_proto_depIdxs,
+		EnumInfos:         file_errors_errors_proto_enumTypes,
+	}.Build()
+	File_errors_errors_proto = out.File
+	file_errors_errors_proto_goTypes = nil
+	file_errors_errors_proto_depIdxs = nil
+}